@@ -1,10 +1,123 @@
 package main
 
 import (
-	"log"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/auth"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/store"
 )
 
+func (a *apiConfig) middlewareRequirePermission(perm auth.Permission, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			slog.WarnContext(r.Context(), "middlewareRequirePermission: failed to get bearer token", "err", err)
+			http.Error(w, "Unauthorized, no user token provided.", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := auth.ParseJWT(token, a.jwtAuthSecret)
+		if err != nil || claims.UserID == uuid.Nil {
+			slog.WarnContext(r.Context(), "middlewareRequirePermission: failed to validate JWT", "err", err)
+			http.Error(w, "Unauthorized. Invalid user token.", http.StatusUnauthorized)
+			return
+		}
+
+		if !auth.HasPermission(claims.Role, perm) {
+			slog.WarnContext(r.Context(), "middlewareRequirePermission: permission denied", "user_id", claims.UserID, "role", claims.Role, "permission", perm)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+var errLastAdmin = errors.New("cannot demote the last admin")
+
+func (a *apiConfig) handlerSetUserRole(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		slog.InfoContext(r.Context(), "handlerSetUserRole: invalid user ID parameter", "err", err)
+		http.Error(w, "Invalid user ID parameter", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		slog.InfoContext(r.Context(), "handlerSetUserRole: failed to decode request body", "err", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if !auth.ValidRole(payload.Role) {
+		slog.InfoContext(r.Context(), "handlerSetUserRole: unknown role", "role", payload.Role)
+		http.Error(w, fmt.Sprintf("Unknown role %q", payload.Role), http.StatusBadRequest)
+		return
+	}
+
+	// read, count and update together, so two concurrent demotions can't
+	// both see a second admin
+	var current, userRaw database.User
+	err = a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		var err error
+		if current, err = tx.GetUserById(r.Context(), id); err != nil {
+			return err
+		}
+		if current.Role == auth.RoleAdmin && payload.Role != auth.RoleAdmin {
+			admins, err := tx.CountUsersWithRole(r.Context(), auth.RoleAdmin)
+			if err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return errLastAdmin
+			}
+		}
+		userRaw, err = tx.SetUserRole(r.Context(), database.SetUserRoleParams{
+			ID:   id,
+			Role: payload.Role,
+		})
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			slog.InfoContext(r.Context(), "handlerSetUserRole: user not found", "user_id", id)
+			http.Error(w, "User not found", http.StatusNotFound)
+		case errors.Is(err, errLastAdmin):
+			slog.WarnContext(r.Context(), "handlerSetUserRole: refusing to demote the last admin", "user_id", id)
+			http.Error(w, "Cannot demote the last admin", http.StatusConflict)
+		default:
+			slog.ErrorContext(r.Context(), "handlerSetUserRole: failed to set role", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	slog.InfoContext(r.Context(), "handlerSetUserRole: role changed", "user_id", id, "from", current.Role, "to", userRaw.Role)
+
+	user := userInfoResponse{
+		ID:          userRaw.ID,
+		CreatedAt:   userRaw.CreatedAt,
+		UpdatedAt:   userRaw.UpdatedAt,
+		Email:       userRaw.Email,
+		Username:    userRaw.Username.String,
+		IsChirpyRed: userRaw.IsChirpyRed,
+		Role:        userRaw.Role,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
 func (a *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -12,20 +125,26 @@ func (a *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	if a.platform != "dev" {
-		log.Printf("handlerReset: forbidden reset attempt on platform: %s", a.platform)
+		slog.WarnContext(r.Context(), "handlerReset: forbidden reset attempt", "platform", a.platform)
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
 	err := a.dbQueries.ResetUsers(r.Context())
 	if err != nil {
-		log.Printf("handlerReset: failed to reset users: %v", err)
+		slog.ErrorContext(r.Context(), "handlerReset: failed to reset users", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	a.pageViews.Discard()
+	err = a.dbQueries.ResetPageViews(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerReset: failed to reset page views", "err", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	a.fileserverHits.Store(0)
-	
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Application data reset\n"))
 }
\ No newline at end of file
@@ -0,0 +1,1216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/auth"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/linkpreview"
+	"github.com/jonvanw/chirpy/internal/media"
+	"github.com/jonvanw/chirpy/internal/metrics"
+	"github.com/jonvanw/chirpy/internal/notify"
+	"github.com/jonvanw/chirpy/internal/pageviews"
+	"github.com/jonvanw/chirpy/internal/ratelimit"
+	"github.com/jonvanw/chirpy/internal/static"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+const (
+	testJWTSecret = "0123456789abcdefghijklmnopqrstuvwxyz"
+	testPolkaKey  = "f271c81ff7084ee5b99a5091b42d486e"
+)
+
+type testAPI struct {
+	*apiConfig
+	store   *store.Memory
+	handler http.Handler
+}
+
+// newTestAPI wires the real routes to an in-memory store, so every handler
+// runs without Postgres.
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+
+	memory := store.NewMemory()
+	mediaStorage, err := media.NewLocalStorage(t.TempDir(), "/media/")
+	if err != nil {
+		t.Fatalf("Error creating media storage: %v", err)
+	}
+	a := &apiConfig{
+		dbQueries:          memory,
+		platform:           "dev",
+		jwtAuthSecret:      testJWTSecret,
+		jwtDuration:        time.Hour,
+		refreshTokenTTL:    24 * time.Hour,
+		chirpRestoreWindow: time.Hour,
+		scheduledLimit:     2,
+		pokaApiKey:         testPolkaKey,
+		mediaStorage:       mediaStorage,
+		mediaLimits:        media.Limits{MaxPixels: 1_000_000, MaxFrames: 10, MaxTotalPixels: 2_000_000, ThumbnailSize: 16},
+		maxUploadBytes:     1 << 20,
+		rateLimiter:        ratelimit.NewMemoryStore(),
+		readiness: &readinessChecker{
+			ping:          func(ctx context.Context) error { return nil },
+			schemaVersion: func(ctx context.Context) (int64, error) { return 10, nil },
+			expected:      10,
+		},
+		metrics:   metrics.New(),
+		pageViews: pageviews.NewCounter(memory),
+
+		notifications: notify.NewWorker(memory),
+	}
+	web := fstest.MapFS{
+		"index.html": {Data: []byte("<h1>Welcome to Chirpy</h1>")},
+	}
+	return &testAPI{
+		apiConfig: a,
+		store:     memory,
+		handler:   a.routes(static.New(web, static.Options{})),
+	}
+}
+
+func (api *testAPI) do(t *testing.T, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body == nil {
+		reader = bytes.NewReader(nil)
+	} else {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Error encoding request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	api.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("Expected status %d, got %d: %s", want, rec.Code, rec.Body.String())
+	}
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(rec.Body).Decode(&v); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	return v
+}
+
+// signUp creates a user through the API and logs them in.
+func (api *testAPI) signUp(t *testing.T, email string) userInfoResponse {
+	t.Helper()
+	creds := userInfoRequest{Email: email, Password: "hunter2"}
+	expectStatus(t, api.do(t, "POST", "/api/users", "", creds), http.StatusCreated)
+	rec := api.do(t, "POST", "/api/login", "", creds)
+	expectStatus(t, rec, http.StatusOK)
+	return decode[userInfoResponse](t, rec)
+}
+
+// tokenWithRole gives user a role and returns an access token carrying it.
+func (api *testAPI) tokenWithRole(t *testing.T, user userInfoResponse, role string) string {
+	t.Helper()
+	if _, err := api.store.SetUserRole(context.Background(), database.SetUserRoleParams{ID: user.ID, Role: role}); err != nil {
+		t.Fatalf("Error setting role: %v", err)
+	}
+	token, err := auth.MakeJWT(user.ID, role, testJWTSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating JWT: %v", err)
+	}
+	return token
+}
+
+func TestAppServesFilesAndCountsViews(t *testing.T) {
+	api := newTestAPI(t)
+
+	rec := api.do(t, "GET", "/app/", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "Welcome to Chirpy") {
+		t.Fatalf("Expected index.html, got %q", rec.Body.String())
+	}
+	expectStatus(t, api.do(t, "GET", "/app/missing.js", "", nil), http.StatusNotFound)
+
+	if got := api.pageViews.Pending(); got != 1 {
+		t.Fatalf("Expected only the successful request to count as a view, got %d", got)
+	}
+
+	admin := api.tokenWithRole(t, api.signUp(t, "admin@example.com"), auth.RoleAdmin)
+	rec = api.do(t, "GET", "/admin/metrics", admin, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "visited 1 times") {
+		t.Fatalf("Expected 1 visit in the metrics page, got %q", rec.Body.String())
+	}
+}
+
+func TestAdminRoutesRequirePermission(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.signUp(t, "user@example.com")
+	admin := api.tokenWithRole(t, api.signUp(t, "admin@example.com"), auth.RoleAdmin)
+
+	for _, path := range []string{"/admin/", "/admin/api/stats", "/admin/api/pageviews", "/admin/metrics", "/metrics"} {
+		expectStatus(t, api.do(t, "GET", path, "", nil), http.StatusUnauthorized)
+		expectStatus(t, api.do(t, "GET", path, user.Token, nil), http.StatusForbidden)
+		expectStatus(t, api.do(t, "GET", path, admin, nil), http.StatusOK)
+	}
+
+	stats := decode[dashboardStats](t, api.do(t, "GET", "/admin/api/stats", admin, nil))
+	if stats.Users != 2 || stats.ActiveSessions != 2 {
+		t.Fatalf("Expected 2 users with 2 sessions, got %d users and %d sessions", stats.Users, stats.ActiveSessions)
+	}
+
+	expectStatus(t, api.do(t, "GET", "/admin/api/pageviews?from=yesterday", admin, nil), http.StatusBadRequest)
+	expectStatus(t, api.do(t, "GET", "/admin/api/pageviews?from=2026-03-02&to=2026-03-01", admin, nil), http.StatusBadRequest)
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.tokenWithRole(t, api.signUp(t, "admin@example.com"), auth.RoleAdmin)
+	api.metrics.ChirpsCreated.Inc()
+
+	rec := api.do(t, "GET", "/metrics", admin, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "chirps_created_total 1") {
+		t.Fatalf("Expected the chirps counter in the exposition, got %q", rec.Body.String())
+	}
+}
+
+func TestReset(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.tokenWithRole(t, api.signUp(t, "admin@example.com"), auth.RoleAdmin)
+	api.do(t, "GET", "/app/", "", nil)
+
+	api.platform = "prod"
+	expectStatus(t, api.do(t, "POST", "/admin/reset", admin, nil), http.StatusForbidden)
+
+	api.platform = "dev"
+	expectStatus(t, api.do(t, "POST", "/admin/reset", admin, nil), http.StatusOK)
+	if n, _ := api.store.CountUsers(context.Background()); n != 0 {
+		t.Fatalf("Expected no users after reset, got %d", n)
+	}
+	if n := api.pageViews.Pending(); n != 0 {
+		t.Fatalf("Expected buffered page views to be discarded, got %d", n)
+	}
+}
+
+func TestSetUserRole(t *testing.T) {
+	api := newTestAPI(t)
+	adminUser := api.signUp(t, "admin@example.com")
+	admin := api.tokenWithRole(t, adminUser, auth.RoleAdmin)
+	user := api.signUp(t, "user@example.com")
+
+	path := "/admin/users/" + user.ID.String() + "/role"
+	expectStatus(t, api.do(t, "PUT", path, user.Token, map[string]string{"role": auth.RoleAdmin}), http.StatusForbidden)
+	expectStatus(t, api.do(t, "PUT", path, admin, map[string]string{"role": "root"}), http.StatusBadRequest)
+	expectStatus(t, api.do(t, "PUT", "/admin/users/"+uuid.NewString()+"/role", admin, map[string]string{"role": auth.RoleUser}), http.StatusNotFound)
+
+	rec := api.do(t, "PUT", path, admin, map[string]string{"role": auth.RoleModerator})
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[userInfoResponse](t, rec); got.Role != auth.RoleModerator {
+		t.Fatalf("Expected role %s, got %s", auth.RoleModerator, got.Role)
+	}
+
+	lastAdmin := "/admin/users/" + adminUser.ID.String() + "/role"
+	expectStatus(t, api.do(t, "PUT", lastAdmin, admin, map[string]string{"role": auth.RoleUser}), http.StatusConflict)
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	api := newTestAPI(t)
+
+	expectStatus(t, api.do(t, "GET", "/api/livez", "", nil), http.StatusOK)
+	expectStatus(t, api.do(t, "GET", "/api/healthz", "", nil), http.StatusOK)
+
+	rec := api.do(t, "GET", "/api/readyz", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if report := decode[readinessReport](t, rec); !report.ok() {
+		t.Fatalf("Expected a ready report, got %+v", report)
+	}
+
+	api.readiness = &readinessChecker{
+		ping:          func(ctx context.Context) error { return errors.New("connection refused") },
+		schemaVersion: func(ctx context.Context) (int64, error) { return 9, nil },
+		expected:      10,
+	}
+	rec = api.do(t, "GET", "/api/readyz", "", nil)
+	expectStatus(t, rec, http.StatusServiceUnavailable)
+	report := decode[readinessReport](t, rec)
+	if report.Checks["database"].Status == "ok" || report.Checks["migrations"].Status == "ok" {
+		t.Fatalf("Expected both checks to fail, got %+v", report.Checks)
+	}
+
+	api.draining.Store(true)
+	expectStatus(t, api.do(t, "GET", "/api/healthz", "", nil), http.StatusServiceUnavailable)
+	expectStatus(t, api.do(t, "GET", "/api/readyz", "", nil), http.StatusServiceUnavailable)
+	expectStatus(t, api.do(t, "GET", "/api/livez", "", nil), http.StatusOK)
+}
+
+func TestDrain(t *testing.T) {
+	api := newTestAPI(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	server := &http.Server{Handler: api.handler}
+	go server.Serve(listener)
+	healthz := "http://" + listener.Addr().String() + "/api/healthz"
+
+	done := make(chan error, 1)
+	go func() { done <- api.drain(server, 300*time.Millisecond, time.Second) }()
+	for !api.draining.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// still serving, so probes can see it is going away
+	res, err := http.Get(healthz)
+	if err != nil {
+		t.Fatalf("Expected the server to still be listening, got %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable || string(body) != "draining" {
+		t.Fatalf("Expected 503 draining, got %d %q", res.StatusCode, body)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("Error shutting down: %v", err)
+	}
+	if _, err := http.Get(healthz); err == nil {
+		t.Fatalf("Expected the listener to be closed after draining")
+	}
+}
+
+func TestChirps(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp(t, "alice@example.com")
+	bob := api.signUp(t, "bob@example.com")
+
+	expectStatus(t, api.do(t, "POST", "/api/chirps", "", map[string]string{"body": "hi"}), http.StatusUnauthorized)
+	expectStatus(t, api.do(t, "POST", "/api/chirps", alice.Token, map[string]string{"body": strings.Repeat("a", 141)}), http.StatusBadRequest)
+
+	rec := api.do(t, "POST", "/api/chirps", alice.Token, map[string]string{"body": "first"})
+	expectStatus(t, rec, http.StatusCreated)
+	first := decode[database.Chirp](t, rec)
+	if first.UserID != alice.ID {
+		t.Fatalf("Expected chirp by %s, got %s", alice.ID, first.UserID)
+	}
+	expectStatus(t, api.do(t, "POST", "/api/chirps", bob.Token, map[string]string{"body": "second"}), http.StatusCreated)
+
+	rec = api.do(t, "GET", "/api/chirps?sort=desc", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	lastModified := rec.Header().Get("Last-Modified")
+	if chirps := decode[[]database.Chirp](t, rec); len(chirps) != 2 || chirps[0].Body != "second" {
+		t.Fatalf("Expected 2 chirps newest first, got %+v", chirps)
+	}
+	rec = api.do(t, "GET", "/api/chirps?author_id="+alice.ID.String(), "", nil)
+	if chirps := decode[[]database.Chirp](t, rec); len(chirps) != 1 || chirps[0].ID != first.ID {
+		t.Fatalf("Expected only alice's chirp, got %+v", chirps)
+	}
+	expectStatus(t, api.do(t, "GET", "/api/chirps?author_id=alice", "", nil), http.StatusBadRequest)
+
+	req := httptest.NewRequest("GET", "/api/chirps?sort=desc", nil)
+	req.Header.Set("If-None-Match", etag)
+	cached := httptest.NewRecorder()
+	api.handler.ServeHTTP(cached, req)
+	expectStatus(t, cached, http.StatusNotModified)
+
+	chirpPath := "/api/chirps/" + first.ID.String()
+	expectStatus(t, api.do(t, "GET", chirpPath, "", nil), http.StatusOK)
+	expectStatus(t, api.do(t, "GET", "/api/chirps/"+uuid.NewString(), "", nil), http.StatusNotFound)
+	expectStatus(t, api.do(t, "GET", "/api/chirps/first", "", nil), http.StatusBadRequest)
+
+	expectStatus(t, api.do(t, "DELETE", chirpPath, "", nil), http.StatusUnauthorized)
+	expectStatus(t, api.do(t, "DELETE", chirpPath, bob.Token, nil), http.StatusForbidden)
+	api.store.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	expectStatus(t, api.do(t, "DELETE", chirpPath, alice.Token, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "GET", chirpPath, "", nil), http.StatusNotFound)
+
+	// the list changed, so neither the old ETag nor Last-Modified must match
+	cached = httptest.NewRecorder()
+	api.handler.ServeHTTP(cached, req)
+	expectStatus(t, cached, http.StatusOK)
+	req = httptest.NewRequest("GET", "/api/chirps?sort=desc", nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	cached = httptest.NewRecorder()
+	api.handler.ServeHTTP(cached, req)
+	expectStatus(t, cached, http.StatusOK)
+}
+
+func TestModeratorDeletesAnyChirp(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp(t, "alice@example.com")
+	moderator := api.tokenWithRole(t, api.signUp(t, "mod@example.com"), auth.RoleModerator)
+
+	chirp := decode[database.Chirp](t, api.do(t, "POST", "/api/chirps", alice.Token, map[string]string{"body": "hello"}))
+	chirpPath := "/api/chirps/" + chirp.ID.String()
+	expectStatus(t, api.do(t, "DELETE", chirpPath+"?reason=spam", moderator, nil), http.StatusNoContent)
+
+	deleted, _ := api.store.GetChirpByIdWithDeleted(context.Background(), chirp.ID)
+	if deleted.DeletionReason.String != "spam" {
+		t.Fatalf("Expected the deletion reason to be kept, got %+v", deleted.DeletionReason)
+	}
+	expectStatus(t, api.do(t, "POST", chirpPath+"/restore", alice.Token, nil), http.StatusForbidden)
+}
+
+func TestRestoreChirp(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp(t, "alice@example.com")
+	bob := api.signUp(t, "bob@example.com")
+
+	chirp := decode[database.Chirp](t, api.do(t, "POST", "/api/chirps", alice.Token, map[string]string{"body": "oops"}))
+	chirpPath := "/api/chirps/" + chirp.ID.String()
+	expectStatus(t, api.do(t, "POST", chirpPath+"/restore", alice.Token, nil), http.StatusConflict)
+	expectStatus(t, api.do(t, "DELETE", chirpPath, alice.Token, nil), http.StatusNoContent)
+
+	if chirps := decode[[]database.Chirp](t, api.do(t, "GET", "/api/chirps", "", nil)); len(chirps) != 0 {
+		t.Fatalf("Expected deleted chirps to be hidden, got %+v", chirps)
+	}
+	expectStatus(t, api.do(t, "POST", chirpPath+"/restore", "", nil), http.StatusUnauthorized)
+	expectStatus(t, api.do(t, "POST", chirpPath+"/restore", bob.Token, nil), http.StatusNotFound)
+	expectStatus(t, api.do(t, "POST", chirpPath+"/restore", alice.Token, nil), http.StatusOK)
+	expectStatus(t, api.do(t, "GET", chirpPath, "", nil), http.StatusOK)
+
+	expectStatus(t, api.do(t, "DELETE", chirpPath, alice.Token, nil), http.StatusNoContent)
+	api.chirpRestoreWindow = 0
+	expectStatus(t, api.do(t, "POST", chirpPath+"/restore", alice.Token, nil), http.StatusGone)
+}
+
+func TestTakeDownChirp(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp(t, "alice@example.com")
+	moderator := api.tokenWithRole(t, api.signUp(t, "mod@example.com"), auth.RoleModerator)
+
+	chirp := decode[database.Chirp](t, api.do(t, "POST", "/api/chirps", alice.Token, map[string]string{"body": "something rude"}))
+	takedownPath := "/admin/chirps/" + chirp.ID.String() + "/takedown"
+	expectStatus(t, api.do(t, "POST", takedownPath, alice.Token, map[string]string{"reason": "abuse"}), http.StatusForbidden)
+	expectStatus(t, api.do(t, "POST", "/admin/chirps/"+uuid.NewString()+"/takedown", moderator, nil), http.StatusNotFound)
+	expectStatus(t, api.do(t, "POST", takedownPath, moderator, map[string]string{"reason": "abuse"}), http.StatusOK)
+
+	rec := api.do(t, "GET", "/api/chirps/"+chirp.ID.String(), "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	got := decode[chirpResponse](t, rec)
+	if !got.TakenDown || got.Body != takedownPlaceholder {
+		t.Fatalf("Expected the placeholder for a taken down chirp, got %+v", got)
+	}
+
+	expectStatus(t, api.do(t, "DELETE", takedownPath, moderator, nil), http.StatusOK)
+	got = decode[chirpResponse](t, api.do(t, "GET", "/api/chirps/"+chirp.ID.String(), "", nil))
+	if got.TakenDown || got.Body != "something rude" {
+		t.Fatalf("Expected the original body after reinstating, got %+v", got)
+	}
+
+	actions := decode[[]moderationActionResponse](t, api.do(t, "GET", "/admin/moderation/log", moderator, nil))
+	if len(actions) != 2 || actions[0].Action != "reinstate" || actions[1].Action != "takedown" || actions[1].Note != "abuse" {
+		t.Fatalf("Expected the takedown and reinstatement to be logged, got %+v", actions)
+	}
+}
+
+// addUser creates a user straight in the store, skipping the sign-up rate
+// limit, and returns their ID and an access token.
+func (api *testAPI) addUser(t *testing.T, email string) (uuid.UUID, string) {
+	t.Helper()
+	user, err := api.store.CreateUser(context.Background(), database.CreateUserParams{Email: email})
+	if err != nil {
+		t.Fatalf("Error creating user: %v", err)
+	}
+	token, err := auth.MakeJWT(user.ID, user.Role, testJWTSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating JWT: %v", err)
+	}
+	return user.ID, token
+}
+
+func TestReportChirp(t *testing.T) {
+	api := newTestAPI(t)
+	api.autoHideThreshold = 2
+	alice := api.signUp(t, "alice@example.com")
+	_, bob := api.addUser(t, "bob@example.com")
+	_, carol := api.addUser(t, "carol@example.com")
+
+	chirp := decode[chirpResponse](t, api.do(t, "POST", "/api/chirps", alice.Token, map[string]string{"body": "buy my stuff"}))
+	reportsPath := "/api/chirps/" + chirp.ID.String() + "/reports"
+	spam := map[string]string{"reason": "spam"}
+	expectStatus(t, api.do(t, "POST", reportsPath, "", spam), http.StatusUnauthorized)
+	expectStatus(t, api.do(t, "POST", reportsPath, bob, map[string]string{"reason": "boring"}), http.StatusBadRequest)
+	expectStatus(t, api.do(t, "POST", reportsPath, alice.Token, spam), http.StatusBadRequest)
+	expectStatus(t, api.do(t, "POST", "/api/chirps/"+uuid.NewString()+"/reports", bob, spam), http.StatusNotFound)
+
+	rec := api.do(t, "POST", reportsPath, bob, map[string]string{"reason": "spam", "details": "third time today"})
+	expectStatus(t, rec, http.StatusCreated)
+	if got := decode[reportResponse](t, rec); got.Reason != "spam" || got.ChirpID != chirp.ID {
+		t.Fatalf("Expected the report to be returned, got %+v", got)
+	}
+	expectStatus(t, api.do(t, "POST", reportsPath, bob, spam), http.StatusConflict)
+	if got := decode[chirpResponse](t, api.do(t, "GET", "/api/chirps/"+chirp.ID.String(), "", nil)); got.TakenDown {
+		t.Fatalf("Expected the chirp to stay visible below the threshold, got %+v", got)
+	}
+
+	expectStatus(t, api.do(t, "POST", reportsPath, carol, map[string]string{"reason": "other"}), http.StatusCreated)
+	got := decode[chirpResponse](t, api.do(t, "GET", "/api/chirps/"+chirp.ID.String(), "", nil))
+	if !got.TakenDown || got.Body != autoHidePlaceholder {
+		t.Fatalf("Expected the chirp to be hidden at the threshold, got %+v", got)
+	}
+}
+
+func TestModerationQueue(t *testing.T) {
+	api := newTestAPI(t)
+	api.autoHideThreshold = 1
+	alice := api.signUp(t, "alice@example.com")
+	moderator := api.signUp(t, "mod@example.com")
+	modToken := api.tokenWithRole(t, moderator, auth.RoleModerator)
+	otherMod := api.tokenWithRole(t, api.signUp(t, "mod2@example.com"), auth.RoleModerator)
+	_, bob := api.addUser(t, "bob@example.com")
+
+	rude := decode[chirpResponse](t, api.do(t, "POST", "/api/chirps", alice.Token, map[string]string{"body": "something rude"}))
+	fine := decode[chirpResponse](t, api.do(t, "POST", "/api/chirps", alice.Token, map[string]string{"body": "something fine"}))
+	expectStatus(t, api.do(t, "POST", "/api/chirps/"+rude.ID.String()+"/reports", bob, map[string]string{"reason": "harassment"}), http.StatusCreated)
+	expectStatus(t, api.do(t, "POST", "/api/chirps/"+fine.ID.String()+"/reports", bob, map[string]string{"reason": "spam"}), http.StatusCreated)
+
+	expectStatus(t, api.do(t, "GET", "/admin/moderation/cases", alice.Token, nil), http.StatusForbidden)
+	rec := api.do(t, "GET", "/admin/moderation/cases", modToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	cases := decode[[]moderationCaseResponse](t, rec)
+	if len(cases) != 2 || cases[0].ChirpBody != "something rude" || cases[0].ReportCount != 1 {
+		t.Fatalf("Expected both open cases oldest first, got %+v", cases)
+	}
+	rudeCase, fineCase := "/admin/moderation/cases/"+cases[0].ID.String(), "/admin/moderation/cases/"+cases[1].ID.String()
+
+	rec = api.do(t, "GET", rudeCase, modToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if detail := decode[moderationCaseDetail](t, rec); detail.Chirp.Body != "something rude" || len(detail.Reports) != 1 || detail.Chirp.TakenDownAt == nil {
+		t.Fatalf("Expected the hidden chirp's original body and its report, got %+v", detail)
+	}
+	expectStatus(t, api.do(t, "GET", "/admin/moderation/cases/"+uuid.NewString(), modToken, nil), http.StatusNotFound)
+
+	remove := map[string]string{"resolution": "remove", "note": "targeted abuse"}
+	expectStatus(t, api.do(t, "POST", rudeCase+"/resolve", modToken, remove), http.StatusConflict)
+	expectStatus(t, api.do(t, "POST", rudeCase+"/claim", modToken, nil), http.StatusOK)
+	expectStatus(t, api.do(t, "POST", rudeCase+"/claim", modToken, nil), http.StatusOK)
+	expectStatus(t, api.do(t, "POST", rudeCase+"/claim", otherMod, nil), http.StatusConflict)
+	expectStatus(t, api.do(t, "POST", rudeCase+"/resolve", otherMod, remove), http.StatusConflict)
+	expectStatus(t, api.do(t, "POST", rudeCase+"/resolve", modToken, map[string]string{"resolution": "ban"}), http.StatusBadRequest)
+	rec = api.do(t, "POST", rudeCase+"/resolve", modToken, remove)
+	expectStatus(t, rec, http.StatusOK)
+	if resolved := decode[moderationCaseResponse](t, rec); resolved.Status != "resolved" || resolved.Resolution != "remove" {
+		t.Fatalf("Expected the case to be resolved, got %+v", resolved)
+	}
+	expectStatus(t, api.do(t, "GET", "/api/chirps/"+rude.ID.String(), "", nil), http.StatusNotFound)
+	expectStatus(t, api.do(t, "POST", "/api/chirps/"+rude.ID.String()+"/restore", alice.Token, nil), http.StatusForbidden)
+
+	expectStatus(t, api.do(t, "POST", fineCase+"/claim", otherMod, nil), http.StatusOK)
+	expectStatus(t, api.do(t, "POST", fineCase+"/resolve", otherMod, map[string]string{"resolution": "dismiss"}), http.StatusOK)
+	if got := decode[chirpResponse](t, api.do(t, "GET", "/api/chirps/"+fine.ID.String(), "", nil)); got.TakenDown {
+		t.Fatalf("Expected a dismissed report to reinstate the hidden chirp, got %+v", got)
+	}
+	expectStatus(t, api.do(t, "POST", fineCase+"/claim", modToken, nil), http.StatusConflict)
+
+	rec = api.do(t, "GET", "/admin/moderation/log", modToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	var actions []string
+	for _, action := range decode[[]moderationActionResponse](t, rec) {
+		actions = append(actions, action.Action)
+	}
+	want := []string{"dismiss", "claim", "remove", "claim", "auto_hide", "auto_hide"}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Fatalf("Expected moderation log %v, got %v", want, actions)
+	}
+}
+
+func TestBlocksAndMutes(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp(t, "alice@example.com")
+	bobId, bob := api.addUser(t, "bob@example.com")
+	carolId, carol := api.addUser(t, "carol@example.com")
+	for _, token := range []string{alice.Token, bob, carol} {
+		expectStatus(t, api.do(t, "POST", "/api/chirps", token, map[string]string{"body": "hello"}), http.StatusCreated)
+	}
+
+	authors := func(token string) map[uuid.UUID]bool {
+		t.Helper()
+		rec := api.do(t, "GET", "/api/chirps", token, nil)
+		expectStatus(t, rec, http.StatusOK)
+		seen := make(map[uuid.UUID]bool)
+		for _, c := range decode[[]chirpResponse](t, rec) {
+			seen[c.UserID] = true
+		}
+		return seen
+	}
+
+	expectStatus(t, api.do(t, "PUT", "/api/blocks/"+bobId.String(), "", nil), http.StatusUnauthorized)
+	expectStatus(t, api.do(t, "PUT", "/api/blocks/"+alice.ID.String(), alice.Token, nil), http.StatusBadRequest)
+	expectStatus(t, api.do(t, "PUT", "/api/blocks/"+uuid.NewString(), alice.Token, nil), http.StatusNotFound)
+	expectStatus(t, api.do(t, "PUT", "/api/blocks/"+bobId.String(), alice.Token, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "PUT", "/api/blocks/"+bobId.String(), alice.Token, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "PUT", "/api/mutes/"+carolId.String(), alice.Token, nil), http.StatusNoContent)
+
+	if seen := authors(alice.Token); len(seen) != 1 || !seen[alice.ID] {
+		t.Fatalf("Expected only Alice's own chirps, got authors %v", seen)
+	}
+	if seen := authors(""); len(seen) != 3 {
+		t.Fatalf("Expected anonymous callers to see every author, got %v", seen)
+	}
+	if seen := authors(bob); len(seen) != 3 {
+		t.Fatalf("Expected blocks to be one-way, got %v", seen)
+	}
+	if blocked, _ := isBlockedBy(context.Background(), api.store, bobId, alice.ID); !blocked {
+		t.Fatalf("Expected Bob to be blocked by Alice")
+	}
+
+	blocks := decode[[]relationshipResponse](t, api.do(t, "GET", "/api/blocks", alice.Token, nil))
+	if len(blocks) != 1 || blocks[0].UserID != bobId {
+		t.Fatalf("Expected Bob in Alice's blocks, got %+v", blocks)
+	}
+
+	rec := api.do(t, "GET", "/api/chirps", alice.Token, nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Header().Get("Last-Modified") != "" {
+		t.Fatalf("Expected no Last-Modified for a signed-in caller")
+	}
+	expectStatus(t, api.do(t, "DELETE", "/api/mutes/"+carolId.String(), alice.Token, nil), http.StatusNoContent)
+	req := httptest.NewRequest("GET", "/api/chirps", nil)
+	req.Header.Set("Authorization", "Bearer "+alice.Token)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	api.handler.ServeHTTP(rec, req)
+	expectStatus(t, rec, http.StatusOK)
+	if seen := authors(alice.Token); !seen[carolId] || seen[bobId] {
+		t.Fatalf("Expected Carol back after unmuting and Bob still blocked, got %v", seen)
+	}
+}
+
+// uploadImage posts a w×h PNG to /api/media.
+func (api *testAPI) uploadImage(t *testing.T, token string, w, h int) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, _ := form.CreateFormFile("file", "photo.png")
+	png.Encode(file, image.NewGray(image.Rect(0, 0, w, h)))
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/api/media", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	api.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMediaAttachments(t *testing.T) {
+	api := newTestAPI(t)
+	_, alice := api.addUser(t, "alice@example.com")
+	_, bob := api.addUser(t, "bob@example.com")
+
+	expectStatus(t, api.uploadImage(t, "", 10, 10), http.StatusUnauthorized)
+	expectStatus(t, api.uploadImage(t, alice, 2000, 1000), http.StatusRequestEntityTooLarge)
+	rec := api.uploadImage(t, alice, 40, 20)
+	expectStatus(t, rec, http.StatusCreated)
+	upload := decode[mediaResponse](t, rec)
+	if upload.ContentType != "image/png" || upload.Width != 40 || upload.Height != 20 {
+		t.Fatalf("Expected a 40x20 PNG, got %+v", upload)
+	}
+
+	rec = api.do(t, "GET", upload.ThumbnailURL, "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if thumb, err := png.DecodeConfig(rec.Body); err != nil || thumb.Width != 16 || thumb.Height != 8 {
+		t.Fatalf("Expected a 16x8 thumbnail, got %+v, %v", thumb, err)
+	}
+
+	// only your own, unattached uploads, at most four of them, each once
+	five := []uuid.UUID{upload.ID, uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	expectStatus(t, api.do(t, "POST", "/api/chirps", alice, map[string]any{"body": "look", "media_ids": five}), http.StatusBadRequest)
+	expectStatus(t, api.do(t, "POST", "/api/chirps", alice, map[string]any{"body": "look", "media_ids": []uuid.UUID{upload.ID, upload.ID}}), http.StatusBadRequest)
+	expectStatus(t, api.do(t, "POST", "/api/chirps", bob, map[string]any{"body": "mine now", "media_ids": []uuid.UUID{upload.ID}}), http.StatusBadRequest)
+
+	rec = api.do(t, "POST", "/api/chirps", alice, map[string]any{"body": "look", "media_ids": []uuid.UUID{upload.ID}})
+	expectStatus(t, rec, http.StatusCreated)
+	chirp := decode[chirpResponse](t, rec)
+	if len(chirp.Media) != 1 || chirp.Media[0].URL != upload.URL {
+		t.Fatalf("Expected the upload on the new chirp, got %+v", chirp.Media)
+	}
+	expectStatus(t, api.do(t, "POST", "/api/chirps", alice, map[string]any{"body": "again", "media_ids": []uuid.UUID{upload.ID}}), http.StatusBadRequest)
+
+	got := decode[chirpResponse](t, api.do(t, "GET", "/api/chirps/"+chirp.ID.String(), "", nil))
+	if len(got.Media) != 1 || got.Media[0].ID != upload.ID {
+		t.Fatalf("Expected the attachment when fetching the chirp, got %+v", got.Media)
+	}
+	if chirps := decode[[]chirpResponse](t, api.do(t, "GET", "/api/chirps", "", nil)); len(chirps) != 1 || len(chirps[0].Media) != 1 {
+		t.Fatalf("Expected the attachment in the chirp list, got %+v", chirps)
+	}
+
+	// uploads nobody attached are cleaned up along with their files
+	stray := decode[mediaResponse](t, api.uploadImage(t, bob, 10, 10))
+	api.purgeUnattachedMedia(context.Background(), -time.Minute)
+	expectStatus(t, api.do(t, "GET", stray.URL, "", nil), http.StatusNotFound)
+	expectStatus(t, api.do(t, "GET", upload.URL, "", nil), http.StatusOK)
+}
+
+func TestLinkPreviews(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><meta property="og:title" content="A page"><meta property="og:description" content="About things"></head></html>`))
+	}))
+	defer page.Close()
+
+	api := newTestAPI(t)
+	fetcher := linkpreview.NewFetcher(linkpreview.Options{Timeout: 5 * time.Second, MaxBytes: 64 << 10, AllowPrivateNetworks: true})
+	api.linkPreviews = linkpreview.NewWorker(api.store, fetcher, 1)
+	_, alice := api.addUser(t, "alice@example.com")
+
+	rec := api.do(t, "POST", "/api/chirps", alice, map[string]string{"body": "read this " + page.URL + "/post!"})
+	expectStatus(t, rec, http.StatusCreated)
+	chirp := decode[chirpResponse](t, rec)
+	if chirp.LinkPreview != nil {
+		t.Fatalf("Expected no preview before it is fetched, got %+v", chirp.LinkPreview)
+	}
+	rec = api.do(t, "GET", "/api/chirps/"+chirp.ID.String(), "", nil)
+	etag := rec.Header().Get("ETag")
+
+	if n, err := api.linkPreviews.Work(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Expected 1 preview fetched, got %d, %v", n, err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/chirps/"+chirp.ID.String(), nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	api.handler.ServeHTTP(rec, req)
+	expectStatus(t, rec, http.StatusOK)
+	got := decode[chirpResponse](t, rec)
+	want := linkPreviewResponse{URL: page.URL + "/post", Title: "A page", Description: "About things"}
+	if got.LinkPreview == nil || *got.LinkPreview != want {
+		t.Fatalf("Expected preview %+v, got %+v", want, got.LinkPreview)
+	}
+	if chirps := decode[[]chirpResponse](t, api.do(t, "GET", "/api/chirps", "", nil)); len(chirps) != 1 || chirps[0].LinkPreview == nil {
+		t.Fatalf("Expected the preview in the chirp list, got %+v", chirps)
+	}
+}
+
+func TestScheduledChirps(t *testing.T) {
+	api := newTestAPI(t)
+	aliceId, alice := api.addUser(t, "alice@example.com")
+	_, bob := api.addUser(t, "bob@example.com")
+	soon := time.Now().Add(time.Hour)
+
+	rec := api.do(t, "POST", "/api/chirps", alice, map[string]any{"body": "later", "publish_at": soon})
+	expectStatus(t, rec, http.StatusCreated)
+	scheduled := decode[database.ScheduledChirp](t, rec)
+	expectStatus(t, api.do(t, "POST", "/api/chirps", alice, map[string]any{"body": "much later", "publish_at": soon.Add(time.Hour)}), http.StatusCreated)
+	expectStatus(t, api.do(t, "POST", "/api/chirps", alice, map[string]any{"body": "too many", "publish_at": soon}), http.StatusForbidden)
+	expectStatus(t, api.do(t, "POST", "/api/chirps", alice, map[string]any{"body": "too far", "publish_at": time.Now().AddDate(2, 0, 0)}), http.StatusBadRequest)
+
+	if chirps := decode[[]chirpResponse](t, api.do(t, "GET", "/api/chirps", "", nil)); len(chirps) != 0 {
+		t.Fatalf("Expected scheduled chirps to be hidden, got %+v", chirps)
+	}
+	if pending := decode[[]database.ScheduledChirp](t, api.do(t, "GET", "/api/chirps/scheduled", alice, nil)); len(pending) != 2 || pending[0].ID != scheduled.ID {
+		t.Fatalf("Expected 2 scheduled chirps, soonest first, got %+v", pending)
+	}
+	if pending := decode[[]database.ScheduledChirp](t, api.do(t, "GET", "/api/chirps/scheduled", bob, nil)); len(pending) != 0 {
+		t.Fatalf("Expected no scheduled chirps for bob, got %+v", pending)
+	}
+
+	path := "/api/chirps/scheduled/" + scheduled.ID.String()
+	expectStatus(t, api.do(t, "PUT", path, bob, map[string]string{"body": "hijacked"}), http.StatusNotFound)
+	rec = api.do(t, "PUT", path, alice, map[string]string{"body": "later, edited"})
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[database.ScheduledChirp](t, rec); got.Body != "later, edited" || !got.PublishAt.Equal(scheduled.PublishAt) {
+		t.Fatalf("Expected only the body to change, got %+v", got)
+	}
+	expectStatus(t, api.do(t, "PUT", path, alice, map[string]any{"publish_at": time.Now().Add(-time.Minute)}), http.StatusBadRequest)
+
+	// chirpy red lifts the limit
+	api.store.UpdateUserIsChirpyRed(context.Background(), database.UpdateUserIsChirpyRedParams{ID: aliceId, IsChirpyRed: true})
+	rec = api.do(t, "POST", "/api/chirps", alice, map[string]any{"body": "cancel me", "publish_at": soon})
+	expectStatus(t, rec, http.StatusCreated)
+	cancelPath := "/api/chirps/scheduled/" + decode[database.ScheduledChirp](t, rec).ID.String()
+	expectStatus(t, api.do(t, "DELETE", cancelPath, bob, nil), http.StatusNotFound)
+	expectStatus(t, api.do(t, "DELETE", cancelPath, alice, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "DELETE", cancelPath, alice, nil), http.StatusNotFound)
+
+	api.store.Now = func() time.Time { return soon.Add(time.Minute) }
+	if n, err := api.publishDueChirps(context.Background(), soon.Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("Expected 1 chirp published, got %d, %v", n, err)
+	}
+	chirps := decode[[]chirpResponse](t, api.do(t, "GET", "/api/chirps", "", nil))
+	if len(chirps) != 1 || chirps[0].Body != "later, edited" || chirps[0].UserID != aliceId {
+		t.Fatalf("Expected the due chirp to be published, got %+v", chirps)
+	}
+	expectStatus(t, api.do(t, "PUT", path, alice, map[string]string{"body": "too late"}), http.StatusNotFound)
+	if pending := decode[[]database.ScheduledChirp](t, api.do(t, "GET", "/api/chirps/scheduled", alice, nil)); len(pending) != 1 {
+		t.Fatalf("Expected 1 chirp still scheduled, got %+v", pending)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	api := newTestAPI(t)
+	_, alice := api.addUser(t, "alice@example.com")
+	_, bob := api.addUser(t, "bob@example.com")
+	clock := time.Now()
+	api.store.Now = func() time.Time { return clock }
+	tick := func() { clock = clock.Add(time.Second) }
+
+	rec := api.do(t, "POST", "/api/drafts", alice, map[string]string{"body": "first try"})
+	expectStatus(t, rec, http.StatusCreated)
+	draft := decode[database.Draft](t, rec)
+	path := "/api/drafts/" + draft.ID.String()
+	expectStatus(t, api.do(t, "POST", "/api/drafts", alice, map[string]string{"body": strings.Repeat("x", maxDraftLength+1)}), http.StatusBadRequest)
+	expectStatus(t, api.do(t, "GET", path, bob, nil), http.StatusNotFound)
+	if drafts := decode[[]database.Draft](t, api.do(t, "GET", "/api/drafts", alice, nil)); len(drafts) != 1 || drafts[0].ID != draft.ID {
+		t.Fatalf("Expected alice's draft to be listed, got %+v", drafts)
+	}
+	if chirps := decode[[]chirpResponse](t, api.do(t, "GET", "/api/chirps", "", nil)); len(chirps) != 0 {
+		t.Fatalf("Expected drafts to stay private, got %+v", chirps)
+	}
+
+	// two devices edit the same version; the second save conflicts
+	tick()
+	expectStatus(t, api.do(t, "PUT", path, alice, map[string]string{"body": "second try"}), http.StatusBadRequest)
+	rec = api.do(t, "PUT", path, alice, map[string]any{"body": "from the phone", "updated_at": draft.UpdatedAt})
+	expectStatus(t, rec, http.StatusOK)
+	saved := decode[database.Draft](t, rec)
+	tick()
+	rec = api.do(t, "PUT", path, alice, map[string]any{"body": "from the laptop", "updated_at": draft.UpdatedAt})
+	expectStatus(t, rec, http.StatusConflict)
+	if got := decode[database.Draft](t, rec); got.Body != "from the phone" || !got.UpdatedAt.Equal(saved.UpdatedAt) {
+		t.Fatalf("Expected the current draft with the conflict, got %+v", got)
+	}
+	expectStatus(t, api.do(t, "PUT", "/api/drafts/"+uuid.NewString(), alice, map[string]any{"body": "x", "updated_at": draft.UpdatedAt}), http.StatusNotFound)
+
+	// publishing an invalid or stale draft keeps it
+	tick()
+	rec = api.do(t, "PUT", path, alice, map[string]any{"body": strings.Repeat("x", 141), "updated_at": saved.UpdatedAt})
+	expectStatus(t, rec, http.StatusOK)
+	saved = decode[database.Draft](t, rec)
+	expectStatus(t, api.do(t, "POST", path+"/publish", alice, nil), http.StatusBadRequest)
+	tick()
+	rec = api.do(t, "PUT", path, alice, map[string]any{"body": "ready, kerfuffle", "updated_at": saved.UpdatedAt})
+	expectStatus(t, rec, http.StatusOK)
+	expectStatus(t, api.do(t, "POST", path+"/publish", alice, map[string]any{"updated_at": saved.UpdatedAt}), http.StatusConflict)
+	saved = decode[database.Draft](t, rec)
+	expectStatus(t, api.do(t, "POST", path+"/publish", bob, nil), http.StatusNotFound)
+
+	rec = api.do(t, "POST", path+"/publish", alice, map[string]any{"updated_at": saved.UpdatedAt})
+	expectStatus(t, rec, http.StatusCreated)
+	if chirp := decode[chirpResponse](t, rec); chirp.Body != "ready, ****" {
+		t.Fatalf("Expected the draft to be cleaned and published, got %+v", chirp)
+	}
+	if chirps := decode[[]chirpResponse](t, api.do(t, "GET", "/api/chirps", "", nil)); len(chirps) != 1 {
+		t.Fatalf("Expected the published chirp to be listed, got %+v", chirps)
+	}
+	expectStatus(t, api.do(t, "GET", path, alice, nil), http.StatusNotFound)
+	expectStatus(t, api.do(t, "POST", path+"/publish", alice, nil), http.StatusNotFound)
+
+	rec = api.do(t, "POST", "/api/drafts", alice, map[string]string{"body": "never mind"})
+	path = "/api/drafts/" + decode[database.Draft](t, rec).ID.String()
+	expectStatus(t, api.do(t, "DELETE", path, bob, nil), http.StatusNotFound)
+	expectStatus(t, api.do(t, "DELETE", path, alice, nil), http.StatusNoContent)
+	if drafts := decode[[]database.Draft](t, api.do(t, "GET", "/api/drafts", alice, nil)); len(drafts) != 0 {
+		t.Fatalf("Expected no drafts left, got %+v", drafts)
+	}
+}
+
+func TestRechirpsAndQuotes(t *testing.T) {
+	api := newTestAPI(t)
+	aliceId, alice := api.addUser(t, "alice@example.com")
+	bobId, bob := api.addUser(t, "bob@example.com")
+	_, carol := api.addUser(t, "carol@example.com")
+
+	original := decode[chirpResponse](t, api.do(t, "POST", "/api/chirps", alice, map[string]string{"body": "worth sharing"}))
+	rechirpPath := "/api/chirps/" + original.ID.String() + "/rechirp"
+	undoPath := "/api/rechirps/" + original.ID.String()
+	expectStatus(t, api.do(t, "POST", rechirpPath, bob, nil), http.StatusCreated)
+	expectStatus(t, api.do(t, "POST", rechirpPath, bob, nil), http.StatusOK)
+	expectStatus(t, api.do(t, "POST", rechirpPath, carol, nil), http.StatusCreated)
+	expectStatus(t, api.do(t, "POST", "/api/chirps/"+uuid.NewString()+"/rechirp", bob, nil), http.StatusNotFound)
+
+	rec := api.do(t, "POST", "/api/chirps", carol, map[string]any{"body": "so true", "quoted_chirp_id": original.ID})
+	expectStatus(t, rec, http.StatusCreated)
+	quote := decode[chirpResponse](t, rec)
+	if quote.QuotedChirp == nil || quote.QuotedChirp.Body != "worth sharing" {
+		t.Fatalf("Expected the quoted chirp inside the quote, got %+v", quote)
+	}
+	expectStatus(t, api.do(t, "POST", "/api/chirps", carol, map[string]any{"body": "huh", "quoted_chirp_id": uuid.New()}), http.StatusBadRequest)
+
+	feed := decode[[]chirpResponse](t, api.do(t, "GET", "/api/chirps", "", nil))
+	if len(feed) != 4 || feed[1].RechirpedBy == nil || feed[1].RechirpedBy.UserID != bobId || feed[1].ID != original.ID {
+		t.Fatalf("Expected the chirp, two rechirps and the quote, got %+v", feed)
+	}
+	if feed[0].RechirpCount != 2 || feed[0].QuoteCount != 1 || feed[1].RechirpCount != 2 {
+		t.Fatalf("Expected 2 rechirps and 1 quote counted, got %+v", feed[:2])
+	}
+	if bobs := decode[[]chirpResponse](t, api.do(t, "GET", "/api/chirps?author_id="+bobId.String(), "", nil)); len(bobs) != 1 || bobs[0].UserID != aliceId {
+		t.Fatalf("Expected bob's feed to show his rechirp of alice, got %+v", bobs)
+	}
+
+	// a later rechirp moves Last-Modified forward
+	lastModified := api.do(t, "GET", "/api/chirps/"+original.ID.String(), "", nil).Header().Get("Last-Modified")
+	req := httptest.NewRequest("GET", "/api/chirps/"+original.ID.String(), nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	rec = httptest.NewRecorder()
+	api.handler.ServeHTTP(rec, req)
+	expectStatus(t, rec, http.StatusNotModified)
+	api.store.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	_, dave := api.addUser(t, "dave@example.com")
+	expectStatus(t, api.do(t, "POST", rechirpPath, dave, nil), http.StatusCreated)
+	for _, path := range []string{"/api/chirps/" + original.ID.String(), "/api/chirps"} {
+		req = httptest.NewRequest("GET", path, nil)
+		req.Header.Set("If-Modified-Since", lastModified)
+		rec = httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, req)
+		expectStatus(t, rec, http.StatusOK)
+	}
+	expectStatus(t, api.do(t, "DELETE", undoPath, dave, nil), http.StatusNoContent)
+
+	// so does undoing one, and it changes the ETag
+	rec = api.do(t, "GET", "/api/chirps/"+original.ID.String(), "", nil)
+	etag, lastModified := rec.Header().Get("ETag"), rec.Header().Get("Last-Modified")
+	api.store.Now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	expectStatus(t, api.do(t, "DELETE", undoPath, bob, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "DELETE", undoPath, bob, nil), http.StatusNotFound)
+	for _, path := range []string{"/api/chirps/" + original.ID.String(), "/api/chirps"} {
+		req = httptest.NewRequest("GET", path, nil)
+		req.Header.Set("If-Modified-Since", lastModified)
+		rec = httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, req)
+		expectStatus(t, rec, http.StatusOK)
+	}
+	req = httptest.NewRequest("GET", "/api/chirps/"+original.ID.String(), nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	api.handler.ServeHTTP(rec, req)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[chirpResponse](t, rec); got.RechirpCount != 1 {
+		t.Fatalf("Expected 1 rechirp left, got %d", got.RechirpCount)
+	}
+
+	expectStatus(t, api.do(t, "PUT", "/api/blocks/"+bobId.String(), alice, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "POST", rechirpPath, bob, nil), http.StatusForbidden)
+	expectStatus(t, api.do(t, "POST", "/api/chirps", bob, map[string]any{"body": "ha", "quoted_chirp_id": original.ID}), http.StatusForbidden)
+
+	// deleting the original drops its rechirps from feeds and leaves quotes
+	// pointing at nothing
+	expectStatus(t, api.do(t, "DELETE", "/api/chirps/"+original.ID.String(), alice, nil), http.StatusNoContent)
+	feed = decode[[]chirpResponse](t, api.do(t, "GET", "/api/chirps", "", nil))
+	if len(feed) != 1 || feed[0].ID != quote.ID || feed[0].QuotedChirpID == nil || feed[0].QuotedChirp != nil {
+		t.Fatalf("Expected only the quote, without the deleted chirp, got %+v", feed)
+	}
+	expectStatus(t, api.do(t, "POST", rechirpPath, carol, nil), http.StatusNotFound)
+	expectStatus(t, api.do(t, "DELETE", undoPath, carol, nil), http.StatusNoContent)
+}
+
+func TestNotifications(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	_, alice := api.addUser(t, "alice@example.com")
+	bobId, bob := api.addUser(t, "bob@example.com")
+	carolId, carol := api.addUser(t, "carol@example.com")
+
+	original := decode[chirpResponse](t, api.do(t, "POST", "/api/chirps", alice, map[string]string{"body": "notify me"}))
+	rechirpPath := "/api/chirps/" + original.ID.String() + "/rechirp"
+	undoPath := "/api/rechirps/" + original.ID.String()
+	expectStatus(t, api.do(t, "POST", rechirpPath, bob, nil), http.StatusCreated)
+	expectStatus(t, api.do(t, "POST", rechirpPath, bob, nil), http.StatusOK)
+	expectStatus(t, api.do(t, "POST", rechirpPath, alice, nil), http.StatusCreated)
+	expectStatus(t, api.do(t, "POST", "/api/chirps", carol, map[string]any{"body": "indeed", "quoted_chirp_id": original.ID}), http.StatusCreated)
+	// undone before it was fanned out
+	expectStatus(t, api.do(t, "POST", rechirpPath, carol, nil), http.StatusCreated)
+	expectStatus(t, api.do(t, "DELETE", undoPath, carol, nil), http.StatusNoContent)
+
+	// nothing arrives until the worker has run
+	if got := decode[notificationsResponse](t, api.do(t, "GET", "/api/notifications", alice, nil)); got.UnreadCount != 0 || len(got.Notifications) != 0 {
+		t.Fatalf("Expected no notifications before fan-out, got %+v", got)
+	}
+	if n, err := api.notifications.Work(ctx); err != nil || n != 4 {
+		t.Fatalf("Expected 4 events fanned out, got %d, %v", n, err)
+	}
+	got := decode[notificationsResponse](t, api.do(t, "GET", "/api/notifications", alice, nil))
+	if got.UnreadCount != 2 || len(got.Notifications) != 2 {
+		t.Fatalf("Expected 2 unread notifications, got %+v", got)
+	}
+	if n := got.Notifications[0]; n.Type != notify.Quote || n.ActorID != carolId || n.ReadAt != nil {
+		t.Fatalf("Expected carol's quote first, got %+v", n)
+	}
+	if n := got.Notifications[1]; n.Type != notify.Rechirp || n.ActorID != bobId || n.ChirpID == nil || *n.ChirpID != original.ID {
+		t.Fatalf("Expected bob's rechirp second, got %+v", n)
+	}
+	if bobs := decode[notificationsResponse](t, api.do(t, "GET", "/api/notifications", bob, nil)); bobs.UnreadCount != 0 {
+		t.Fatalf("Expected none for bob, got %+v", bobs)
+	}
+
+	readPath := "/api/notifications/read"
+	expectStatus(t, api.do(t, "POST", readPath, alice, map[string]any{}), http.StatusBadRequest)
+	rec := api.do(t, "POST", readPath, alice, map[string]any{"ids": []uuid.UUID{got.Notifications[0].ID}})
+	expectStatus(t, rec, http.StatusOK)
+	if unread := decode[notificationsResponse](t, rec).UnreadCount; unread != 1 {
+		t.Fatalf("Expected 1 unread, got %d", unread)
+	}
+	// someone else's notifications can't be marked
+	api.do(t, "POST", readPath, bob, map[string]any{"ids": []uuid.UUID{got.Notifications[1].ID}})
+	if unread := decode[notificationsResponse](t, api.do(t, "GET", "/api/notifications", alice, nil)).UnreadCount; unread != 1 {
+		t.Fatalf("Expected bob to leave alice's notification unread, got %d unread", unread)
+	}
+	if unread := decode[notificationsResponse](t, api.do(t, "POST", readPath, alice, map[string]any{"all": true})).UnreadCount; unread != 0 {
+		t.Fatalf("Expected none unread, got %d", unread)
+	}
+
+	prefsPath := "/api/notifications/preferences"
+	expectStatus(t, api.do(t, "PUT", prefsPath, alice, map[string]bool{"poke": false}), http.StatusBadRequest)
+	prefs := decode[map[string]bool](t, api.do(t, "PUT", prefsPath, alice, map[string]bool{notify.Quote: false}))
+	if !prefs[notify.Rechirp] || prefs[notify.Quote] {
+		t.Fatalf("Expected quotes off and rechirps on, got %v", prefs)
+	}
+
+	// turned off, and from someone alice has muted
+	expectStatus(t, api.do(t, "POST", "/api/chirps", bob, map[string]any{"body": "again", "quoted_chirp_id": original.ID}), http.StatusCreated)
+	expectStatus(t, api.do(t, "PUT", "/api/mutes/"+carolId.String(), alice, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "POST", rechirpPath, carol, nil), http.StatusCreated)
+	api.notifications.Work(ctx)
+	if got := decode[notificationsResponse](t, api.do(t, "GET", "/api/notifications?limit=1", alice, nil)); got.UnreadCount != 0 || len(got.Notifications) != 1 {
+		t.Fatalf("Expected no new notifications, got %+v", got)
+	}
+}
+
+func TestFollows(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	aliceId, alice := api.addUser(t, "alice@example.com")
+	bobId, bob := api.addUser(t, "bob@example.com")
+
+	followPath := "/api/follows/" + aliceId.String()
+	expectStatus(t, api.do(t, "PUT", followPath, bob, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "PUT", followPath, bob, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "PUT", "/api/follows/"+bobId.String(), bob, nil), http.StatusBadRequest)
+	expectStatus(t, api.do(t, "PUT", "/api/follows/"+uuid.NewString(), bob, nil), http.StatusNotFound)
+	if follows := decode[[]relationshipResponse](t, api.do(t, "GET", "/api/follows", bob, nil)); len(follows) != 1 || follows[0].UserID != aliceId {
+		t.Fatalf("Expected bob to follow alice, got %+v", follows)
+	}
+
+	if n, err := api.notifications.Work(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected 1 event fanned out, got %d, %v", n, err)
+	}
+	got := decode[notificationsResponse](t, api.do(t, "GET", "/api/notifications", alice, nil))
+	if len(got.Notifications) != 1 {
+		t.Fatalf("Expected 1 notification, got %+v", got)
+	}
+	if n := got.Notifications[0]; n.Type != notify.Follow || n.ActorID != bobId || n.ChirpID != nil {
+		t.Fatalf("Expected bob's follow without a chirp, got %+v", n)
+	}
+
+	// a block ends follows both ways and stops new ones
+	expectStatus(t, api.do(t, "PUT", "/api/follows/"+bobId.String(), alice, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "PUT", "/api/blocks/"+bobId.String(), alice, nil), http.StatusNoContent)
+	for _, token := range []string{alice, bob} {
+		if follows := decode[[]relationshipResponse](t, api.do(t, "GET", "/api/follows", token, nil)); len(follows) != 0 {
+			t.Fatalf("Expected the block to end follows, got %+v", follows)
+		}
+	}
+	expectStatus(t, api.do(t, "PUT", followPath, bob, nil), http.StatusForbidden)
+	expectStatus(t, api.do(t, "DELETE", followPath, bob, nil), http.StatusNoContent)
+}
+
+func TestReplies(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	_, alice := api.addUser(t, "alice@example.com")
+	bobId, bob := api.addUser(t, "bob@example.com")
+
+	original := decode[chirpResponse](t, api.do(t, "POST", "/api/chirps", alice, map[string]string{"body": "reply to me"}))
+	reply := decode[chirpResponse](t, api.do(t, "POST", "/api/chirps", bob, map[string]any{"body": "hello", "reply_to_chirp_id": original.ID}))
+	if reply.ReplyToChirpID == nil || *reply.ReplyToChirpID != original.ID {
+		t.Fatalf("Expected a reply to %s, got %+v", original.ID, reply)
+	}
+	expectStatus(t, api.do(t, "POST", "/api/chirps", bob, map[string]any{"body": "hm", "reply_to_chirp_id": uuid.New()}), http.StatusBadRequest)
+	publishAt := api.store.Now().Add(time.Hour)
+	expectStatus(t, api.do(t, "POST", "/api/chirps", bob, map[string]any{"body": "later", "reply_to_chirp_id": original.ID, "publish_at": publishAt}), http.StatusBadRequest)
+
+	if n, err := api.notifications.Work(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected 1 event fanned out, got %d, %v", n, err)
+	}
+	got := decode[notificationsResponse](t, api.do(t, "GET", "/api/notifications", alice, nil))
+	if len(got.Notifications) != 1 {
+		t.Fatalf("Expected 1 notification, got %+v", got)
+	}
+	if n := got.Notifications[0]; n.Type != notify.Reply || n.ActorID != bobId || n.ChirpID == nil || *n.ChirpID != reply.ID {
+		t.Fatalf("Expected bob's reply, got %+v", n)
+	}
+
+	expectStatus(t, api.do(t, "PUT", "/api/blocks/"+bobId.String(), alice, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "POST", "/api/chirps", bob, map[string]any{"body": "hm", "reply_to_chirp_id": original.ID}), http.StatusForbidden)
+}
+
+func TestLikes(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	_, alice := api.addUser(t, "alice@example.com")
+	bobId, bob := api.addUser(t, "bob@example.com")
+
+	original := decode[chirpResponse](t, api.do(t, "POST", "/api/chirps", alice, map[string]string{"body": "like me"}))
+	likePath := "/api/likes/" + original.ID.String()
+	expectStatus(t, api.do(t, "POST", likePath, bob, nil), http.StatusCreated)
+	expectStatus(t, api.do(t, "POST", likePath, bob, nil), http.StatusOK)
+	expectStatus(t, api.do(t, "POST", "/api/likes/"+uuid.NewString(), bob, nil), http.StatusNotFound)
+	if got := decode[chirpResponse](t, api.do(t, "GET", "/api/chirps/"+original.ID.String(), "", nil)); got.LikeCount != 1 {
+		t.Fatalf("Expected 1 like, got %d", got.LikeCount)
+	}
+
+	if n, err := api.notifications.Work(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected 1 event fanned out, got %d, %v", n, err)
+	}
+	got := decode[notificationsResponse](t, api.do(t, "GET", "/api/notifications", alice, nil))
+	if len(got.Notifications) != 1 {
+		t.Fatalf("Expected 1 notification, got %+v", got)
+	}
+	if n := got.Notifications[0]; n.Type != notify.Like || n.ActorID != bobId || n.ChirpID == nil || *n.ChirpID != original.ID {
+		t.Fatalf("Expected bob's like, got %+v", n)
+	}
+
+	// unliking moves Last-Modified forward
+	lastModified := api.do(t, "GET", "/api/chirps/"+original.ID.String(), "", nil).Header().Get("Last-Modified")
+	api.store.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	expectStatus(t, api.do(t, "DELETE", likePath, bob, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "DELETE", likePath, bob, nil), http.StatusNotFound)
+	req := httptest.NewRequest("GET", "/api/chirps/"+original.ID.String(), nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	rec := httptest.NewRecorder()
+	api.handler.ServeHTTP(rec, req)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[chirpResponse](t, rec); got.LikeCount != 0 {
+		t.Fatalf("Expected no likes, got %d", got.LikeCount)
+	}
+
+	expectStatus(t, api.do(t, "PUT", "/api/blocks/"+bobId.String(), alice, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "POST", likePath, bob, nil), http.StatusForbidden)
+}
+
+func TestUsernamesAndMentions(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	_, alice := api.addUser(t, "alice@example.com")
+	bobId, bob := api.addUser(t, "bob@example.com")
+
+	usernamePath := "/api/users/username"
+	expectStatus(t, api.do(t, "PUT", usernamePath, alice, map[string]string{"username": "not valid!"}), http.StatusBadRequest)
+	rec := api.do(t, "PUT", usernamePath, alice, map[string]string{"username": "Alice"})
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[userInfoResponse](t, rec); got.Username != "alice" {
+		t.Fatalf("Expected username alice, got %+v", got)
+	}
+	expectStatus(t, api.do(t, "PUT", usernamePath, alice, map[string]string{"username": "alice"}), http.StatusOK)
+	expectStatus(t, api.do(t, "PUT", usernamePath, bob, map[string]string{"username": "ALICE"}), http.StatusConflict)
+	if got := decode[userInfoResponse](t, api.do(t, "PUT", usernamePath, bob, map[string]string{"username": ""})); got.Username != "" {
+		t.Fatalf("Expected no username, got %+v", got)
+	}
+
+	mention := decode[chirpResponse](t, api.do(t, "POST", "/api/chirps", bob, map[string]string{"body": "hey @Alice and @nobody"}))
+	expectStatus(t, api.do(t, "POST", "/api/chirps", bob, map[string]string{"body": "mail alice@example.com"}), http.StatusCreated)
+
+	if n, err := api.notifications.Work(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected 1 event fanned out, got %d, %v", n, err)
+	}
+	got := decode[notificationsResponse](t, api.do(t, "GET", "/api/notifications", alice, nil))
+	if len(got.Notifications) != 1 {
+		t.Fatalf("Expected 1 notification, got %+v", got)
+	}
+	if n := got.Notifications[0]; n.Type != notify.Mention || n.ActorID != bobId || n.ChirpID == nil || *n.ChirpID != mention.ID {
+		t.Fatalf("Expected bob's mention, got %+v", n)
+	}
+}
+
+func TestUsersAndSessions(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp(t, "alice@example.com")
+	if alice.Token == "" || alice.RefreshToken == "" {
+		t.Fatalf("Expected login to return both tokens, got %+v", alice)
+	}
+
+	expectStatus(t, api.do(t, "POST", "/api/login", "", userInfoRequest{Email: "alice@example.com", Password: "wrong"}), http.StatusUnauthorized)
+	expectStatus(t, api.do(t, "POST", "/api/login", "", userInfoRequest{Email: "nobody@example.com", Password: "hunter2"}), http.StatusNotFound)
+
+	update := userInfoRequest{Email: "alice@example.org", Password: "correct horse"}
+	expectStatus(t, api.do(t, "PUT", "/api/users", "", update), http.StatusUnauthorized)
+	rec := api.do(t, "PUT", "/api/users", alice.Token, update)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[userInfoResponse](t, rec); got.Email != update.Email {
+		t.Fatalf("Expected email %s, got %s", update.Email, got.Email)
+	}
+	expectStatus(t, api.do(t, "POST", "/api/login", "", update), http.StatusOK)
+
+	expectStatus(t, api.do(t, "POST", "/api/refresh", "", nil), http.StatusUnauthorized)
+	expectStatus(t, api.do(t, "POST", "/api/refresh", "not-a-token", nil), http.StatusUnauthorized)
+	rec = api.do(t, "POST", "/api/refresh", alice.RefreshToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	refreshed := decode[struct {
+		Token string `json:"token"`
+	}](t, rec)
+	if id, err := auth.ValidateJWT(refreshed.Token, testJWTSecret); err != nil || id != alice.ID {
+		t.Fatalf("Expected a valid access token for %s, got %s, %v", alice.ID, id, err)
+	}
+
+	expectStatus(t, api.do(t, "POST", "/api/revoke", alice.RefreshToken, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "POST", "/api/revoke", alice.RefreshToken, nil), http.StatusNoContent)
+	expectStatus(t, api.do(t, "POST", "/api/refresh", alice.RefreshToken, nil), http.StatusUnauthorized)
+}
+
+func TestDisabledUserCannotLogIn(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp(t, "alice@example.com")
+
+	if _, err := api.store.SetUserDisabled(context.Background(), database.SetUserDisabledParams{ID: alice.ID, Disabled: true}); err != nil {
+		t.Fatalf("Error disabling user: %v", err)
+	}
+	expectStatus(t, api.do(t, "POST", "/api/login", "", userInfoRequest{Email: "alice@example.com", Password: "hunter2"}), http.StatusForbidden)
+	expectStatus(t, api.do(t, "POST", "/api/refresh", alice.RefreshToken, nil), http.StatusUnauthorized)
+}
+
+func TestPolkaWebhook(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp(t, "alice@example.com")
+
+	send := func(key string, event string, userId uuid.UUID) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]any{"event": event, "data": map[string]any{"user_id": userId}})
+		req := httptest.NewRequest("POST", "/api/polka/webhooks", bytes.NewReader(body))
+		if key != "" {
+			req.Header.Set("Authorization", "ApiKey "+key)
+		}
+		rec := httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	expectStatus(t, send("", "user.upgraded", alice.ID), http.StatusUnauthorized)
+	expectStatus(t, send("wrong", "user.upgraded", alice.ID), http.StatusUnauthorized)
+	expectStatus(t, send(testPolkaKey, "user.payment_failed", alice.ID), http.StatusNoContent)
+	expectStatus(t, send(testPolkaKey, "user.upgraded", uuid.New()), http.StatusNotFound)
+	expectStatus(t, send(testPolkaKey, "user.upgraded", alice.ID), http.StatusNoContent)
+
+	user, _ := api.store.GetUserById(context.Background(), alice.ID)
+	if !user.IsChirpyRed {
+		t.Fatalf("Expected the user to be upgraded to Chirpy Red")
+	}
+	events, _ := api.store.GetRecentWebhookEvents(context.Background(), 10)
+	if len(events) != 3 || events[0].Outcome != "upgraded" {
+		t.Fatalf("Expected 3 recorded events, newest upgraded, got %+v", events)
+	}
+}
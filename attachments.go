@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/media"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+const (
+	maxChirpMedia   = 4
+	mediaUploadPath = "/api/media"
+	// room for the multipart headers and boundaries around the file itself
+	multipartOverhead = 64 << 10
+)
+
+var errInvalidMedia = errors.New("media not found or already attached")
+
+type mediaResponse struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+}
+
+func (a *apiConfig) newMediaResponse(attachment database.MediaAttachment) mediaResponse {
+	return mediaResponse{
+		ID:           attachment.ID,
+		URL:          a.mediaStorage.URL(attachment.StorageKey),
+		ThumbnailURL: a.mediaStorage.URL(attachment.ThumbnailKey),
+		ContentType:  attachment.ContentType,
+		Width:        attachment.Width,
+		Height:       attachment.Height,
+	}
+}
+
+// handlerUploadMedia accepts one image in the "file" field of a multipart
+// form. The upload isn't visible anywhere until its ID is passed in
+// media_ids when posting a chirp.
+func (a *apiConfig) handlerUploadMedia(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerUploadMedia")
+	if !ok {
+		return
+	}
+
+	data, err := readUploadedFile(r, "file", a.maxUploadBytes)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge), errors.Is(err, errUploadTooLarge):
+			http.Error(w, fmt.Sprintf("Images must be at most %d bytes", a.maxUploadBytes), http.StatusRequestEntityTooLarge)
+		default:
+			slog.InfoContext(r.Context(), "handlerUploadMedia: failed to read upload", "err", err)
+			http.Error(w, "Expected a multipart form with the image in a \"file\" field", http.StatusBadRequest)
+		}
+		return
+	}
+
+	img, err := media.Process(data, a.mediaLimits)
+	if err != nil {
+		slog.InfoContext(r.Context(), "handlerUploadMedia: rejected image", "err", err)
+		switch {
+		case errors.Is(err, media.ErrUnsupportedType):
+			http.Error(w, "Only JPEG, PNG and GIF images are supported", http.StatusUnsupportedMediaType)
+		case errors.Is(err, media.ErrTooManyPixels):
+			http.Error(w, "Image dimensions are too large", http.StatusRequestEntityTooLarge)
+		case errors.Is(err, media.ErrTooManyFrames):
+			http.Error(w, "Animation has too many frames", http.StatusRequestEntityTooLarge)
+		default:
+			http.Error(w, "Could not read image", http.StatusBadRequest)
+		}
+		return
+	}
+
+	id := uuid.New()
+	key, thumbKey := id.String()+img.Ext, id.String()+"_thumb"+img.ThumbnailExt
+	attachment, err := a.storeMedia(r.Context(), database.CreateMediaAttachmentParams{
+		ID:           id,
+		UserID:       userId,
+		ContentType:  img.ContentType,
+		Width:        int32(img.Width),
+		Height:       int32(img.Height),
+		SizeBytes:    int64(len(img.Data)),
+		StorageKey:   key,
+		ThumbnailKey: thumbKey,
+	}, img)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerUploadMedia: failed to store upload", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "handlerUploadMedia: image uploaded", "media_id", id, "user_id", userId, "content_type", img.ContentType, "bytes", len(img.Data))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a.newMediaResponse(attachment))
+}
+
+var errUploadTooLarge = errors.New("upload too large")
+
+// readUploadedFile returns the contents of the named file field, refusing
+// anything over limit bytes.
+func readUploadedFile(r *http.Request, field string, limit int64) ([]byte, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("no %q field", field)
+		} else if err != nil {
+			return nil, err
+		}
+		if part.FormName() != field {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(part, limit+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > limit {
+			return nil, errUploadTooLarge
+		}
+		return data, nil
+	}
+}
+
+// storeMedia writes the image and its thumbnail, then records them. Files are
+// removed again if anything fails so storage never holds unrecorded files.
+func (a *apiConfig) storeMedia(ctx context.Context, arg database.CreateMediaAttachmentParams, img media.Image) (database.MediaAttachment, error) {
+	err := a.mediaStorage.Put(ctx, arg.StorageKey, img.ContentType, img.Data)
+	if err == nil {
+		err = a.mediaStorage.Put(ctx, arg.ThumbnailKey, img.ThumbnailType, img.Thumbnail)
+	}
+	var attachment database.MediaAttachment
+	if err == nil {
+		attachment, err = a.dbQueries.CreateMediaAttachment(ctx, arg)
+	}
+	if err != nil {
+		a.deleteMediaFiles(ctx, arg.StorageKey, arg.ThumbnailKey)
+		return database.MediaAttachment{}, err
+	}
+	return attachment, nil
+}
+
+func (a *apiConfig) deleteMediaFiles(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := a.mediaStorage.Delete(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "deleteMediaFiles: failed to delete file", "key", key, "err", err)
+		}
+	}
+}
+
+// attachMedia links the caller's own unattached uploads to a new chirp, in
+// the order given.
+func attachMedia(ctx context.Context, tx store.Media, chirpId, userId uuid.UUID, ids []uuid.UUID) ([]database.MediaAttachment, error) {
+	attachments := make([]database.MediaAttachment, len(ids))
+	for i, id := range ids {
+		var err error
+		attachments[i], err = tx.AttachMedia(ctx, database.AttachMediaParams{
+			ChirpID:  uuid.NullUUID{UUID: chirpId, Valid: true},
+			Position: sql.NullInt32{Int32: int32(i), Valid: true},
+			ID:       id,
+			UserID:   userId,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", errInvalidMedia, id)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return attachments, nil
+}
+
+// withMedia fills in the attachments of chirps. Taken down chirps keep
+// theirs hidden along with the body.
+func (a *apiConfig) withMedia(ctx context.Context, chirps []chirpResponse) error {
+	var ids []uuid.UUID
+	for _, c := range chirps {
+		if !c.TakenDown {
+			ids = append(ids, c.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	attachments, err := a.dbQueries.GetMediaByChirpIds(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byChirp := make(map[uuid.UUID][]mediaResponse)
+	for _, attachment := range attachments {
+		byChirp[attachment.ChirpID.UUID] = append(byChirp[attachment.ChirpID.UUID], a.newMediaResponse(attachment))
+	}
+	for i := range chirps {
+		chirps[i].Media = byChirp[chirps[i].ID]
+	}
+	return nil
+}
+
+// purgeUnattachedMedia deletes uploads that were never attached to a chirp,
+// or whose chirp has since been purged, along with their files.
+func (a *apiConfig) purgeUnattachedMedia(ctx context.Context, olderThan time.Duration) {
+	rows, err := a.dbQueries.DeleteUnattachedMedia(ctx, time.Now().Add(-olderThan))
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "purgeUnattachedMedia: failed to delete unattached media", "err", err)
+		}
+		return
+	}
+	for _, row := range rows {
+		a.deleteMediaFiles(ctx, row.StorageKey, row.ThumbnailKey)
+	}
+	if len(rows) > 0 {
+		slog.InfoContext(ctx, "purgeUnattachedMedia: deleted unattached media", "count", len(rows))
+	}
+}
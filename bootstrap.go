@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/jonvanw/chirpy/internal/auth"
+	"github.com/jonvanw/chirpy/internal/database"
+)
+
+// bootstrapAdmin makes sure at least one admin exists by creating, or
+// promoting, the user with the given email. It does nothing once an admin
+// exists, so the configured password is never used to overwrite anything.
+func (a *apiConfig) bootstrapAdmin(ctx context.Context, email, password string) error {
+	admins, err := a.dbQueries.CountUsersWithRole(ctx, auth.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	user, err := a.dbQueries.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		args, err := (&userInfoRequest{Email: email, Password: password}).ToInsertDbArgs()
+		if err != nil {
+			return err
+		}
+		if user, err = a.dbQueries.CreateUser(ctx, args); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	_, err = a.dbQueries.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:   user.ID,
+		Role: auth.RoleAdmin,
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("bootstrapped first admin user", "user_id", user.ID)
+	return nil
+}
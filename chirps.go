@@ -1,20 +1,81 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jonvanw/chirpy/internal/auth"
 	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/notify"
+	"github.com/jonvanw/chirpy/internal/store"
 )
 
+var (
+	errNotChirpAuthor      = errors.New("not the chirp's author")
+	errChirpNotDeleted     = errors.New("chirp is not deleted")
+	errRestoreWindowPassed = errors.New("restore window has passed")
+	errReplyParent         = errors.New("chirp replied to")
+)
+
+const (
+	takedownPlaceholder = "This chirp was removed by a moderator."
+	autoHidePlaceholder = "This chirp is hidden while moderators review reports about it."
+)
+
+type chirpResponse struct {
+	ID             uuid.UUID            `json:"id"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+	Body           string               `json:"body"`
+	UserID         uuid.UUID            `json:"user_id"`
+	TakenDown      bool                 `json:"taken_down,omitempty"`
+	Media          []mediaResponse      `json:"media,omitempty"`
+	LinkPreview    *linkPreviewResponse `json:"link_preview,omitempty"`
+	QuotedChirpID  *uuid.UUID           `json:"quoted_chirp_id,omitempty"`
+	QuotedChirp    *quotedChirpResponse `json:"quoted_chirp,omitempty"`
+	ReplyToChirpID *uuid.UUID           `json:"reply_to_chirp_id,omitempty"`
+	RechirpCount   int64                `json:"rechirp_count"`
+	QuoteCount     int64                `json:"quote_count"`
+	LikeCount      int64                `json:"like_count"`
+	// set on feed entries that are someone's rechirp of this chirp
+	RechirpedBy *rechirpResponse `json:"rechirped_by,omitempty"`
+}
+
+// newChirpResponse hides the body of chirps a moderator has taken down, so
+// they stay in threads and lists but no longer show the offending text.
+func newChirpResponse(chirp database.Chirp) chirpResponse {
+	res := chirpResponse{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+	}
+	if chirp.QuotedChirpID.Valid {
+		res.QuotedChirpID = &chirp.QuotedChirpID.UUID
+	}
+	if chirp.ReplyToChirpID.Valid {
+		res.ReplyToChirpID = &chirp.ReplyToChirpID.UUID
+	}
+	if chirp.TakenDownAt.Valid {
+		res.Body = takedownPlaceholder
+		if autoHidden(chirp) {
+			res.Body = autoHidePlaceholder
+		}
+		res.TakenDown = true
+	}
+	return res
+}
+
 func (a *apiConfig) handleAddChirp(w http.ResponseWriter, r *http.Request) {	
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -23,45 +84,176 @@ func (a *apiConfig) handleAddChirp(w http.ResponseWriter, r *http.Request) {
 
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		log.Printf("handleAddChirp: failed to get bearer token: %v", err)
+		slog.WarnContext(r.Context(), "handleAddChirp: failed to get bearer token", "err", err)
 		http.Error(w, "Unauthorized, no user token provided.", http.StatusUnauthorized)
 		return
 	}
 
 	userId, err := auth.ValidateJWT(token, a.jwtAuthSecret)
 	if err != nil || userId == uuid.Nil {
-		log.Printf("handleAddChirp: failed to validate JWT: %v", err)
+		slog.WarnContext(r.Context(), "handleAddChirp: failed to validate JWT", "err", err)
 		http.Error(w, "Unauthorized. Invalid user token.", http.StatusUnauthorized)
 		return
 	}
 
-	var payload database.CreateChirpParams
+	var payload struct {
+		Body     string      `json:"body"`
+		MediaIDs []uuid.UUID `json:"media_ids"`
+		// PublishAt in the future schedules the chirp instead of posting it
+		PublishAt *time.Time `json:"publish_at"`
+		// QuotedChirpID makes this a quote of another chirp
+		QuotedChirpID *uuid.UUID `json:"quoted_chirp_id"`
+		// ReplyToChirpID makes this a reply to another chirp
+		ReplyToChirpID *uuid.UUID `json:"reply_to_chirp_id"`
+	}
 	err = json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
-		log.Printf("handleAddChirp: failed to decode request body: %v", err)
+		slog.InfoContext(r.Context(), "handleAddChirp: failed to decode request body", "err", err)
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
-	cleanedBody, err := ValidateChirp(payload.Body)
+	cleanedBody, links, err := ValidateChirp(payload.Body)
 	if err != nil {
-		log.Printf("handleAddChirp: chirp validation failed: %v", err)
+		slog.InfoContext(r.Context(), "handleAddChirp: chirp validation failed", "err", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	payload.Body = cleanedBody
-	payload.UserID = userId
+	if len(payload.MediaIDs) > maxChirpMedia {
+		http.Error(w, fmt.Sprintf("A chirp can have at most %d attachments", maxChirpMedia), http.StatusBadRequest)
+		return
+	}
+	for i, id := range payload.MediaIDs {
+		if slices.Contains(payload.MediaIDs[:i], id) {
+			http.Error(w, "The same attachment can't be used twice", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if payload.PublishAt != nil && payload.PublishAt.After(time.Now()) {
+		if len(payload.MediaIDs) > 0 {
+			http.Error(w, "Scheduled chirps can't have attachments", http.StatusBadRequest)
+			return
+		}
+		if payload.QuotedChirpID != nil || payload.ReplyToChirpID != nil {
+			http.Error(w, "Scheduled chirps can't quote or reply to other chirps", http.StatusBadRequest)
+			return
+		}
+		a.scheduleChirp(w, r, userId, cleanedBody, *payload.PublishAt)
+		return
+	}
 
-	chirp, err := a.dbQueries.CreateChirp(r.Context(), payload)
+	// the chirp and its attachments appear together or not at all
+	params := database.CreateChirpParams{Body: cleanedBody, UserID: userId}
+	var chirp database.Chirp
+	var attachments []database.MediaAttachment
+	err = a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		if payload.QuotedChirpID != nil {
+			if _, err := checkShareable(r.Context(), tx, userId, *payload.QuotedChirpID); err != nil {
+				return fmt.Errorf("quoted chirp: %w", err)
+			}
+			params.QuotedChirpID = uuid.NullUUID{UUID: *payload.QuotedChirpID, Valid: true}
+		}
+		if payload.ReplyToChirpID != nil {
+			if _, err := checkShareable(r.Context(), tx, userId, *payload.ReplyToChirpID); err != nil {
+				return fmt.Errorf("%w: %w", errReplyParent, err)
+			}
+			params.ReplyToChirpID = uuid.NullUUID{UUID: *payload.ReplyToChirpID, Valid: true}
+		}
+		var err error
+		chirp, attachments, err = a.createChirp(r.Context(), tx, params, links, payload.MediaIDs)
+		return err
+	})
 	if err != nil {
-		log.Printf("handleAddChirp: failed to create chirp: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, errInvalidMedia):
+			slog.InfoContext(r.Context(), "handleAddChirp: invalid attachment", "err", err)
+			http.Error(w, "Attachments must be your own uploads that aren't on another chirp", http.StatusBadRequest)
+		case errors.Is(err, errReplyParent) && errors.Is(err, errBlockedByAuthor):
+			http.Error(w, "Forbidden: you can't reply to this user's chirps", http.StatusForbidden)
+		case errors.Is(err, errReplyParent) && (errors.Is(err, sql.ErrNoRows) || errors.Is(err, errChirpUnavailable)):
+			slog.InfoContext(r.Context(), "handleAddChirp: can't reply to chirp", "err", err)
+			http.Error(w, "Chirp replied to not found", http.StatusBadRequest)
+		case errors.Is(err, sql.ErrNoRows), errors.Is(err, errChirpUnavailable):
+			slog.InfoContext(r.Context(), "handleAddChirp: can't quote chirp", "err", err)
+			http.Error(w, "Quoted chirp not found", http.StatusBadRequest)
+		case errors.Is(err, errBlockedByAuthor):
+			http.Error(w, "Forbidden: you can't quote this user's chirps", http.StatusForbidden)
+		default:
+			slog.ErrorContext(r.Context(), "handleAddChirp: failed to create chirp", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 		return
 	}
+	a.chirpCreated(chirp, links)
 
+	res := []chirpResponse{newChirpResponse(chirp)}
+	for _, attachment := range attachments {
+		res[0].Media = append(res[0].Media, a.newMediaResponse(attachment))
+	}
+	if err := a.withShares(r.Context(), res); err != nil {
+		// the chirp is posted; it just goes out without its quote filled in
+		slog.ErrorContext(r.Context(), "handleAddChirp: failed to get quoted chirp", "err", err)
+	}
 	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(chirp)
+	json.NewEncoder(w).Encode(res[0])
+}
+
+// createChirp posts an already validated body with the given uploads
+// attached, and queues a preview of its first link and the notifications
+// it causes. Call chirpCreated once tx has committed.
+func (a *apiConfig) createChirp(ctx context.Context, tx store.Store, params database.CreateChirpParams, links []string, mediaIds []uuid.UUID) (database.Chirp, []database.MediaAttachment, error) {
+	chirp, err := tx.CreateChirp(ctx, params)
+	if err != nil {
+		return database.Chirp{}, nil, err
+	}
+	attachments, err := attachMedia(ctx, tx, chirp.ID, params.UserID, mediaIds)
+	if err != nil {
+		return database.Chirp{}, nil, err
+	}
+	if a.linkPreviews != nil && len(links) > 0 {
+		if err := tx.EnqueueLinkPreview(ctx, links[0]); err != nil {
+			return database.Chirp{}, nil, err
+		}
+	}
+	for _, typ := range chirpNotifications(chirp) {
+		err := tx.EnqueueNotificationEvent(ctx, database.EnqueueNotificationEventParams{
+			Type:    typ,
+			ActorID: chirp.UserID,
+			ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		})
+		if err != nil {
+			return database.Chirp{}, nil, err
+		}
+	}
+	return chirp, attachments, nil
+}
+
+// chirpNotifications lists the types of notification posting chirp causes.
+// The worker works out who they go to.
+func chirpNotifications(chirp database.Chirp) []string {
+	var types []string
+	if chirp.QuotedChirpID.Valid {
+		types = append(types, notify.Quote)
+	}
+	if chirp.ReplyToChirpID.Valid {
+		types = append(types, notify.Reply)
+	}
+	if len(notify.FindMentions(chirp.Body)) > 0 {
+		types = append(types, notify.Mention)
+	}
+	return types
+}
+
+func (a *apiConfig) chirpCreated(chirp database.Chirp, links []string) {
+	a.metrics.ChirpsCreated.Inc()
+	if a.linkPreviews != nil && len(links) > 0 {
+		a.linkPreviews.Wake()
+	}
+	if len(chirpNotifications(chirp)) > 0 {
+		a.notifications.Wake()
+	}
 }
 
 func (a *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
@@ -70,50 +262,143 @@ func (a *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	var chirps []database.Chirp
-	var err error
-	if userIdText := r.URL.Query().Get("author_id"); userIdText != "" {
-		userId, err := uuid.Parse(userIdText)
+	var userId uuid.UUID
+	userIdText := r.URL.Query().Get("author_id")
+	if userIdText != "" {
+		var err error
+		userId, err = uuid.Parse(userIdText)
 		if err != nil {
-			log.Printf("handlerGetChirps: invalid author_id parameter: %v", err)
+			slog.InfoContext(r.Context(), "handlerGetChirps: invalid author_id parameter", "err", err)
 			http.Error(w, "Invalid author_id parameter", http.StatusBadRequest)
 			return
 		}
+	}
+	sort := strings.ToLower(r.URL.Query().Get("sort"))
+
+	// signed-in callers don't see chirps from people they block or mute
+	viewerId, signedIn := a.optionalUserId(r)
+	var hidden []uuid.UUID
+	if signedIn {
+		var err error
+		hidden, err = a.dbQueries.GetHiddenUserIds(r.Context(), viewerId)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "handlerGetChirps: failed to get blocked and muted users", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		slices.SortFunc(hidden, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+	}
+	w.Header().Add("Vary", "Authorization")
+
+	// count and newest updated_at change on every create, edit and delete, so
+	// they version the whole list without loading it. A user's feed shows
+	// chirps they rechirped and quote counts from anyone, so it is versioned
+	// by every chirp too.
+	version, err := a.dbQueries.GetChirpsVersion(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerGetChirps: failed to get chirps version", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	rechirpsVersion, err := a.dbQueries.GetRechirpsVersion(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerGetChirps: failed to get rechirps version", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	likesVersion, err := a.dbQueries.GetLikesVersion(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerGetChirps: failed to get likes version", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// link previews arrive after the chirp, without touching it
+	previewsUpdated, err := a.dbQueries.GetLinkPreviewsVersion(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerGetChirps: failed to get link previews version", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// unblocking or unmuting changes the list without touching any chirp, so
+	// signed-in callers get only the ETag, which covers who they hide. Adding
+	// or undoing a rechirp or like moves counts_updated_at on its chirp.
+	var lastModified time.Time
+	if version.ChirpCount > 0 && !signedIn {
+		lastModified = latest(version.LastUpdated, version.CountsUpdated, previewsUpdated)
+	}
+	etag := versionETag("chirps", userIdText, sort == "desc", version.ChirpCount, version.LastUpdated.UnixNano(), previewsUpdated.UnixNano(),
+		rechirpsVersion.RechirpCount, rechirpsVersion.LastCreated.UnixNano(), likesVersion.LikeCount, likesVersion.LastCreated.UnixNano(), hidden)
+	if checkNotModified(w, r, etag, lastModified) {
+		return
+	}
+
+	var chirps []database.Chirp
+	var rechirps []database.Rechirp
+	if userIdText != "" {
 		chirps, err = a.dbQueries.GetChirpsByUserId(r.Context(), userId)
 		if err != nil {
-			log.Printf("handlerGetChirps: failed to get chirps by user ID: %v", err)
+			slog.ErrorContext(r.Context(), "handlerGetChirps: failed to get chirps by user ID", "err", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+		rechirps, err = a.dbQueries.GetRechirpsByUserId(r.Context(), userId)
 	} else {
 		chirps, err = a.dbQueries.GetChirpsByCreation(r.Context())
 		if err != nil {
-			log.Printf("handlerGetChirps: failed to get chirps: %v", err)
+			slog.ErrorContext(r.Context(), "handlerGetChirps: failed to get chirps", "err", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+		rechirps, err = a.dbQueries.GetRechirps(r.Context())
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerGetChirps: failed to get rechirps", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	sort := strings.ToLower(r.URL.Query().Get("sort"))
+	rechirped, err := a.rechirpEntries(r.Context(), rechirps, chirps, hidden)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerGetChirps: failed to get rechirped chirps", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	chirps = slices.DeleteFunc(chirps, func(c database.Chirp) bool { return slices.Contains(hidden, c.UserID) })
+	res := make([]chirpResponse, len(chirps), len(chirps)+len(rechirped))
+	for i, chirp := range chirps {
+		res[i] = newChirpResponse(chirp)
+	}
+	res = append(res, rechirped...)
+	slices.SortStableFunc(res, func(a, b chirpResponse) int { return feedTime(a).Compare(feedTime(b)) })
 	if sort == "desc" {
-		slices.Reverse(chirps)
+		slices.Reverse(res)
+	}
+	if err := a.withAttachments(r.Context(), res); err != nil {
+		slog.ErrorContext(r.Context(), "handlerGetChirps: failed to get attachments", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	for i, c := range res {
+		if c.QuotedChirp != nil && slices.Contains(hidden, c.QuotedChirp.UserID) {
+			res[i].QuotedChirp = nil
+		}
 	}
 
-	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(chirps)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
 }
 
 func (a *apiConfig) handlerGetChirpById(w http.ResponseWriter, r *http.Request) {
 	idText := r.PathValue("chirpId")
 	if idText == "" {
-		log.Printf("handlerGetChirpById: missing ID parameter")
+		slog.InfoContext(r.Context(), "handlerGetChirpById: missing ID parameter")
 		http.Error(w, "Missing ID parameter", http.StatusBadRequest)
 		return
 	}
 	id, err := uuid.Parse(idText)
 	if err != nil {
-		log.Printf("handlerGetChirpById: invalid ID parameter: %v", err)
+		slog.InfoContext(r.Context(), "handlerGetChirpById: invalid ID parameter", "err", err)
 		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
 		return
 	}
@@ -121,18 +406,57 @@ func (a *apiConfig) handlerGetChirpById(w http.ResponseWriter, r *http.Request)
 	chirp, err := a.dbQueries.GetChirpById(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("handlerGetChirpById: chirp not found: %s", id.String())
+			slog.InfoContext(r.Context(), "handlerGetChirpById: chirp not found", "chirp_id", id)
 			http.Error(w, fmt.Sprintf("Chirp with ID %s not found", id.String()), http.StatusNotFound)
 			return
 		}
-		log.Printf("handlerGetChirpById: failed to get chirp: %v", err)
+		slog.ErrorContext(r.Context(), "handlerGetChirpById: failed to get chirp", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	previewsUpdated, err := a.dbQueries.GetLinkPreviewsVersion(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerGetChirpById: failed to get link previews version", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// a new quote is a new chirp
+	chirpsVersion, err := a.dbQueries.GetChirpsVersion(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerGetChirpById: failed to get chirps version", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// counts and the quoted chirp change without touching this one, so they
+	// are looked up first and go into the ETag. Rechirps and likes, added or
+	// undone, move counts_updated_at instead of updated_at.
+	res := []chirpResponse{newChirpResponse(chirp)}
+	if err := a.withShares(r.Context(), res); err != nil {
+		slog.ErrorContext(r.Context(), "handlerGetChirpById: failed to get rechirps and quotes", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	var quoted any
+	if res[0].QuotedChirp != nil {
+		quoted = res[0].QuotedChirp.UpdatedAt.UnixNano()
+	}
+	// the quoted chirp's updated_at is covered by the newest chirp's
+	lastModified := latest(chirp.UpdatedAt, chirp.CountsUpdatedAt.Time, chirpsVersion.LastUpdated, previewsUpdated)
+	etag := versionETag("chirp", chirp.ID, chirp.UpdatedAt.UnixNano(), previewsUpdated.UnixNano(), res[0].RechirpCount, res[0].QuoteCount, res[0].LikeCount, quoted)
+	if checkNotModified(w, r, etag, lastModified) {
+		return
+	}
+
+	if err := a.withMediaAndLinks(r.Context(), res); err != nil {
+		slog.ErrorContext(r.Context(), "handlerGetChirpById: failed to get attachments", "err", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(chirp)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res[0])
 }
 
 func (a *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
@@ -143,55 +467,151 @@ func (a *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
 
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		log.Printf("handleAddChirp: failed to get bearer token: %v", err)
+		slog.WarnContext(r.Context(), "handlerDeleteChirp: failed to get bearer token", "err", err)
 		http.Error(w, "Unauthorized, no user token provided.", http.StatusUnauthorized)
 		return
 	}
 
-	userId, err := auth.ValidateJWT(token, a.jwtAuthSecret)
-	if err != nil || userId == uuid.Nil {
-		log.Printf("handleAddChirp: failed to validate JWT: %v", err)
+	claims, err := auth.ParseJWT(token, a.jwtAuthSecret)
+	if err != nil || claims.UserID == uuid.Nil {
+		slog.WarnContext(r.Context(), "handlerDeleteChirp: failed to validate JWT", "err", err)
 		http.Error(w, "Unauthorized. Invalid user token.", http.StatusUnauthorized)
 		return
 	}
 	
 	idText := r.PathValue("chirpId")
 	if idText == "" {
-		log.Printf("handlerDeleteChirp: missing ID parameter")
+		slog.InfoContext(r.Context(), "handlerDeleteChirp: missing ID parameter")
 		http.Error(w, "Missing ID parameter", http.StatusBadRequest)
 		return
 	}
 	id, err := uuid.Parse(idText)
 	if err != nil {
-		log.Printf("handlerDeleteChirp: invalid ID parameter: %v", err)
+		slog.InfoContext(r.Context(), "handlerDeleteChirp: invalid ID parameter", "err", err)
 		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
 		return
 	}
 
-	chirp, err := a.dbQueries.GetChirpById(r.Context(), id)
+	// authors delete in one statement; anyone else needs the permission
+	// checked against the row in the same transaction as the delete. Either
+	// way the row is only marked deleted and purged later.
+	reason := r.URL.Query().Get("reason")
+	var chirp database.Chirp
+	err = a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		var err error
+		chirp, err = tx.DeleteChirpByAuthor(r.Context(), database.DeleteChirpByAuthorParams{ID: id, UserID: claims.UserID})
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		chirp, err = tx.GetChirpById(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if !auth.HasPermission(claims.Role, auth.PermDeleteAnyChirp) {
+			return errNotChirpAuthor
+		}
+		chirp, err = tx.DeleteChirp(r.Context(), database.DeleteChirpParams{
+			ID:             id,
+			DeletedBy:      uuid.NullUUID{UUID: claims.UserID, Valid: true},
+			DeletionReason: sql.NullString{String: reason, Valid: reason != ""},
+		})
+		if err != nil {
+			return err
+		}
+		return logModerationAction(r.Context(), tx, claims.UserID, uuid.Nil, chirp, actionDelete, reason)
+	})
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("handlerGetChirpById: chirp not found: %s", id.String())
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			slog.InfoContext(r.Context(), "handlerDeleteChirp: chirp not found", "chirp_id", id)
 			http.Error(w, fmt.Sprintf("Chirp with ID %s not found", id.String()), http.StatusNotFound)
-			return
+		case errors.Is(err, errNotChirpAuthor):
+			slog.WarnContext(r.Context(), "handlerDeleteChirp: user unauthorized to delete chirp", "user_id", claims.UserID, "chirp_id", id)
+			http.Error(w, "Forbidden: you can only delete your own chirps", http.StatusForbidden)
+		default:
+			slog.ErrorContext(r.Context(), "handlerDeleteChirp: failed to delete chirp", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
-		log.Printf("handlerGetChirpById: failed to get chirp: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if chirp.UserID != claims.UserID {
+		slog.InfoContext(r.Context(), "handlerDeleteChirp: moderator deleted another user's chirp", "user_id", claims.UserID, "role", claims.Role, "chirp_id", id, "author_id", chirp.UserID)
+	}
 
-	if chirp.UserID != userId {
-		log.Printf("handlerDeleteChirp: user %s unauthorized to delete chirp %s", userId.String(), id.String())
-		http.Error(w, "Forbidden: you can only delete your own chirps", http.StatusForbidden)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerRestoreChirp undoes an author's own delete within the restore
+// window. Chirps removed by a moderator can't be restored by their author.
+func (a *apiConfig) handlerRestoreChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		slog.WarnContext(r.Context(), "handlerRestoreChirp: failed to get bearer token", "err", err)
+		http.Error(w, "Unauthorized, no user token provided.", http.StatusUnauthorized)
+		return
+	}
+	userId, err := auth.ValidateJWT(token, a.jwtAuthSecret)
+	if err != nil || userId == uuid.Nil {
+		slog.WarnContext(r.Context(), "handlerRestoreChirp: failed to validate JWT", "err", err)
+		http.Error(w, "Unauthorized. Invalid user token.", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		slog.InfoContext(r.Context(), "handlerRestoreChirp: invalid ID parameter", "err", err)
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
 		return
 	}
 
-	err = a.dbQueries.DeleteChirp(r.Context(), id)
+	var chirp database.Chirp
+	err = a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		var err error
+		chirp, err = tx.GetChirpByIdWithDeleted(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		switch {
+		case chirp.UserID != userId:
+			// deleted chirps are hidden, so don't tell others they exist
+			return sql.ErrNoRows
+		case !chirp.DeletedAt.Valid:
+			return errChirpNotDeleted
+		case chirp.DeletedBy.UUID != userId:
+			return errNotChirpAuthor
+		case time.Since(chirp.DeletedAt.Time) > a.chirpRestoreWindow:
+			return errRestoreWindowPassed
+		}
+		chirp, err = tx.RestoreChirp(r.Context(), id)
+		return err
+	})
 	if err != nil {
-		log.Printf("handlerDeleteChirp: failed to delete chirp: %v", err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			slog.InfoContext(r.Context(), "handlerRestoreChirp: chirp not found", "chirp_id", id)
+			http.Error(w, fmt.Sprintf("Chirp with ID %s not found", id.String()), http.StatusNotFound)
+		case errors.Is(err, errChirpNotDeleted):
+			http.Error(w, "Chirp is not deleted", http.StatusConflict)
+		case errors.Is(err, errNotChirpAuthor):
+			slog.WarnContext(r.Context(), "handlerRestoreChirp: chirp was removed by someone else", "user_id", userId, "chirp_id", id)
+			http.Error(w, "Forbidden: this chirp was removed by a moderator", http.StatusForbidden)
+		case errors.Is(err, errRestoreWindowPassed):
+			http.Error(w, "Chirp was deleted too long ago to restore", http.StatusGone)
+		default:
+			slog.ErrorContext(r.Context(), "handlerRestoreChirp: failed to restore chirp", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res := []chirpResponse{newChirpResponse(chirp)}
+	if err := a.withAttachments(r.Context(), res); err != nil {
+		slog.ErrorContext(r.Context(), "handlerRestoreChirp: failed to get attachments", "err", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
\ No newline at end of file
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res[0])
+}
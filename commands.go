@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/auth"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+const userUsage = `usage: chirpy user <action> [flags]
+
+actions:
+  create   -email EMAIL [-role ROLE] [-red]   password is read from stdin
+  disable  -user EMAIL|ID                     blocks login and revokes refresh tokens
+  enable   -user EMAIL|ID
+  promote  -user EMAIL|ID -role ROLE          role is user, moderator or admin
+  set-red  -user EMAIL|ID [-red=false]`
+
+func runUser(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+	action, args := args[0], args[1:]
+	switch action {
+	case "create", "disable", "enable", "promote", "set-red":
+	default:
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+
+	var email, ref, role string
+	red := action == "set-red"
+	q, db, _, _, err := openCommandDB(args, func(fs *flag.FlagSet) {
+		fs.StringVar(&email, "email", "", "email of the new user")
+		fs.StringVar(&ref, "user", "", "email or ID of an existing user")
+		fs.StringVar(&role, "role", "", "role: user, moderator or admin")
+		fs.BoolVar(&red, "red", red, "whether the user has Chirpy Red")
+	})
+	if err != nil {
+		return commandFailed("user "+action, err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	var user database.User
+	switch action {
+	case "create":
+		user, err = createUser(ctx, q, email, role, red)
+	case "disable", "enable":
+		user, err = setUserDisabled(ctx, q, ref, action == "disable")
+	case "promote":
+		user, err = promoteUser(ctx, q, ref, role)
+	case "set-red":
+		if user, err = findUser(ctx, q, ref); err == nil {
+			user, err = q.UpdateUserIsChirpyRed(ctx, database.UpdateUserIsChirpyRedParams{ID: user.ID, IsChirpyRed: red})
+		}
+	}
+	if err != nil {
+		return commandFailed("user "+action, err)
+	}
+	printUser(user)
+	return 0
+}
+
+func createUser(ctx context.Context, q store.Store, email, role string, red bool) (database.User, error) {
+	if email == "" {
+		return database.User{}, errors.New("-email is required")
+	}
+	if role == "" {
+		role = auth.RoleUser
+	}
+	if !auth.ValidRole(role) {
+		return database.User{}, fmt.Errorf("unknown role %q", role)
+	}
+	password, err := readPassword()
+	if err != nil {
+		return database.User{}, err
+	}
+
+	args, err := (&userInfoRequest{Email: email, Password: password}).ToInsertDbArgs()
+	if err != nil {
+		return database.User{}, err
+	}
+	// a failed role or Chirpy Red update must not leave a half-created user
+	var user database.User
+	err = q.InTx(ctx, func(tx store.Store) error {
+		var err error
+		if user, err = tx.CreateUser(ctx, args); err != nil {
+			return err
+		}
+		if role != auth.RoleUser {
+			if user, err = tx.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: role}); err != nil {
+				return err
+			}
+		}
+		if red {
+			user, err = tx.UpdateUserIsChirpyRed(ctx, database.UpdateUserIsChirpyRedParams{ID: user.ID, IsChirpyRed: true})
+		}
+		return err
+	})
+	return user, err
+}
+
+// setUserDisabled also revokes refresh tokens when disabling, so the user is
+// locked out as soon as their current access token expires.
+func setUserDisabled(ctx context.Context, q store.Store, ref string, disabled bool) (database.User, error) {
+	user, err := findUser(ctx, q, ref)
+	if err != nil {
+		return user, err
+	}
+	var revoked int64
+	err = q.InTx(ctx, func(tx store.Store) error {
+		var err error
+		user, err = tx.SetUserDisabled(ctx, database.SetUserDisabledParams{ID: user.ID, Disabled: disabled})
+		if err != nil || !disabled {
+			return err
+		}
+		revoked, err = tx.RevokeUserRefreshTokens(ctx, user.ID)
+		return err
+	})
+	if err != nil {
+		return user, err
+	}
+	if disabled {
+		fmt.Printf("revoked %d refresh token(s)\n", revoked)
+	}
+	return user, nil
+}
+
+// promoteUser applies the same last-admin guard as PUT /admin/users/{id}/role.
+func promoteUser(ctx context.Context, q store.Store, ref, role string) (database.User, error) {
+	if !auth.ValidRole(role) {
+		return database.User{}, fmt.Errorf("-role must be user, moderator or admin, got %q", role)
+	}
+	// read, count and update together, so two demotions can't both see a
+	// second admin
+	var user database.User
+	err := q.InTx(ctx, func(tx store.Store) error {
+		var err error
+		if user, err = findUser(ctx, tx, ref); err != nil {
+			return err
+		}
+		if user.Role == auth.RoleAdmin && role != auth.RoleAdmin {
+			admins, err := tx.CountUsersWithRole(ctx, auth.RoleAdmin)
+			if err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return errors.New("refusing to demote the last admin")
+			}
+		}
+		user, err = tx.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: role})
+		return err
+	})
+	return user, err
+}
+
+const tokenUsage = "usage: chirpy token revoke -user EMAIL|ID"
+
+func runToken(args []string) int {
+	if len(args) == 0 || args[0] != "revoke" {
+		fmt.Fprintln(os.Stderr, tokenUsage)
+		return 2
+	}
+
+	var ref string
+	q, db, _, _, err := openCommandDB(args[1:], func(fs *flag.FlagSet) {
+		fs.StringVar(&ref, "user", "", "email or ID of the user whose refresh tokens to revoke")
+	})
+	if err != nil {
+		return commandFailed("token revoke", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	user, err := findUser(ctx, q, ref)
+	if err != nil {
+		return commandFailed("token revoke", err)
+	}
+	revoked, err := q.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return commandFailed("token revoke", err)
+	}
+	fmt.Printf("revoked %d refresh token(s) for %s\n", revoked, user.Email)
+	return 0
+}
+
+const chirpUsage = "usage: chirpy chirp delete -id CHIRP_ID [-reason TEXT]"
+
+func runChirp(args []string) int {
+	if len(args) == 0 || args[0] != "delete" {
+		fmt.Fprintln(os.Stderr, chirpUsage)
+		return 2
+	}
+
+	var idText, reason string
+	q, db, _, _, err := openCommandDB(args[1:], func(fs *flag.FlagSet) {
+		fs.StringVar(&idText, "id", "", "ID of the chirp to delete")
+		fs.StringVar(&reason, "reason", "", "why the chirp was deleted, kept until it is purged")
+	})
+	if err != nil {
+		return commandFailed("chirp delete", err)
+	}
+	defer db.Close()
+
+	id, err := uuid.Parse(idText)
+	if err != nil {
+		return commandFailed("chirp delete", fmt.Errorf("invalid -id %q: %w", idText, err))
+	}
+	ctx := context.Background()
+	var chirp database.Chirp
+	err = q.InTx(ctx, func(tx store.Store) error {
+		var err error
+		chirp, err = tx.DeleteChirp(ctx, database.DeleteChirpParams{
+			ID:             id,
+			DeletionReason: sql.NullString{String: reason, Valid: reason != ""},
+		})
+		if err != nil {
+			return err
+		}
+		return logModerationAction(ctx, tx, uuid.Nil, uuid.Nil, chirp, actionDelete, reason)
+	})
+	if err != nil {
+		return commandFailed("chirp delete", fmt.Errorf("chirp %s: %w", id, err))
+	}
+	fmt.Printf("deleted chirp %s by %s\n", chirp.ID, chirp.UserID)
+	return 0
+}
+
+var seedWords = strings.Fields(`
+	chirp tweet bird sky morning coffee code deploy gopher postgres
+	weekend garden river mountain music friends lunch sunset rain build
+	release bug fix test review coffee train city book idea`)
+
+// runSeed creates fake users and chirps for local development. It refuses to
+// run unless PLATFORM is dev, like POST /admin/reset.
+func runSeed(args []string) int {
+	var users, chirpsPerUser int
+	var password string
+	q, db, cfg, _, err := openCommandDB(args, func(fs *flag.FlagSet) {
+		fs.IntVar(&users, "users", 10, "number of users to create")
+		fs.IntVar(&chirpsPerUser, "chirps", 5, "chirps to create per user")
+		fs.StringVar(&password, "password", "password", "password for every seeded user")
+	})
+	if err != nil {
+		return commandFailed("seed", err)
+	}
+	defer db.Close()
+
+	if cfg.Platform != "dev" {
+		return commandFailed("seed", fmt.Errorf("refusing to seed with platform %q, set PLATFORM=dev", cfg.Platform))
+	}
+
+	// hashing is deliberately slow, so do it once for every seeded user
+	params, err := (&userInfoRequest{Password: password}).ToInsertDbArgs()
+	if err != nil {
+		return commandFailed("seed", err)
+	}
+
+	ctx := context.Background()
+	batch := uuid.NewString()[:8]
+	for i := range users {
+		params.Email = fmt.Sprintf("seed-%s-%d@example.com", batch, i+1)
+		user, err := q.CreateUser(ctx, params)
+		if err != nil {
+			return commandFailed("seed", err)
+		}
+		for range chirpsPerUser {
+			_, err := q.CreateChirp(ctx, database.CreateChirpParams{Body: fakeChirp(), UserID: user.ID})
+			if err != nil {
+				return commandFailed("seed", err)
+			}
+		}
+	}
+
+	fmt.Printf("created %d users (seed-%s-N@example.com, password %q) with %d chirps each\n", users, batch, password, chirpsPerUser)
+	return 0
+}
+
+func fakeChirp() string {
+	words := make([]string, 3+rand.IntN(12))
+	for i := range words {
+		words[i] = seedWords[rand.IntN(len(seedWords))]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jonvanw/chirpy/internal/database"
+)
+
+const (
+	dashboardDays         = 14
+	dashboardTopPosters   = 10
+	dashboardRecentEvents = 20
+)
+
+//go:embed templates/admin_dashboard.html
+var templateFS embed.FS
+
+var dashboardTemplate = template.Must(template.ParseFS(templateFS, "templates/admin_dashboard.html"))
+
+type dashboardStats struct {
+	GeneratedAt         time.Time                       `json:"generated_at"`
+	Days                int                             `json:"days"`
+	Users               int64                           `json:"users"`
+	ChirpyRedUsers      int64                           `json:"chirpy_red_users"`
+	ActiveSessions      int64                           `json:"active_sessions"`
+	FileserverHits      int64                           `json:"fileserver_hits"`
+	ChirpsPerDay        []database.CountChirpsPerDayRow `json:"chirps_per_day"`
+	TopPosters          []database.GetTopPostersRow     `json:"top_posters"`
+	RecentWebhookEvents []database.WebhookEvent         `json:"recent_webhook_events"`
+}
+
+func (a *apiConfig) loadDashboardStats(ctx context.Context) (dashboardStats, error) {
+	stats := dashboardStats{GeneratedAt: time.Now(), Days: dashboardDays}
+	var err error
+
+	if stats.Users, err = a.dbQueries.CountUsers(ctx); err != nil {
+		return stats, err
+	}
+	if stats.ChirpyRedUsers, err = a.dbQueries.CountChirpyRedUsers(ctx); err != nil {
+		return stats, err
+	}
+	if stats.ActiveSessions, err = a.dbQueries.CountActiveSessions(ctx); err != nil {
+		return stats, err
+	}
+	since := stats.GeneratedAt.AddDate(0, 0, -dashboardDays)
+	if stats.ChirpsPerDay, err = a.dbQueries.CountChirpsPerDay(ctx, since); err != nil {
+		return stats, err
+	}
+	if stats.TopPosters, err = a.dbQueries.GetTopPosters(ctx, dashboardTopPosters); err != nil {
+		return stats, err
+	}
+	if stats.RecentWebhookEvents, err = a.dbQueries.GetRecentWebhookEvents(ctx, dashboardRecentEvents); err != nil {
+		return stats, err
+	}
+
+	if stats.FileserverHits, err = a.totalPageViews(ctx); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+func (a *apiConfig) handlerAdminDashboard(w http.ResponseWriter, r *http.Request) {
+	stats, err := a.loadDashboardStats(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerAdminDashboard: failed to load stats", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if err := dashboardTemplate.Execute(w, stats); err != nil {
+		slog.ErrorContext(r.Context(), "handlerAdminDashboard: failed to render template", "err", err)
+	}
+}
+
+func (a *apiConfig) handlerAdminStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := a.loadDashboardStats(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerAdminStats: failed to load stats", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+// Drafts may run past the chirp limit while being worked on; publishing
+// checks the real one.
+const maxDraftLength = 2000
+
+var errDraftConflict = errors.New("draft was changed elsewhere")
+
+func checkDraftBody(body string) error {
+	if len(body) > maxDraftLength {
+		return errors.New("Draft is too long")
+	}
+	return nil
+}
+
+func draftId(w http.ResponseWriter, r *http.Request, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("draftId"))
+	if err != nil {
+		slog.InfoContext(r.Context(), name+": invalid ID parameter", "err", err)
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (a *apiConfig) handlerCreateDraft(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerCreateDraft")
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		slog.InfoContext(r.Context(), "handlerCreateDraft: failed to decode request body", "err", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if err := checkDraftBody(payload.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	draft, err := a.dbQueries.CreateDraft(r.Context(), database.CreateDraftParams{Body: payload.Body, UserID: userId})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerCreateDraft: failed to create draft", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(draft)
+}
+
+// handlerListDrafts lists the caller's drafts, most recently edited first.
+func (a *apiConfig) handlerListDrafts(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerListDrafts")
+	if !ok {
+		return
+	}
+
+	drafts, err := a.dbQueries.GetDraftsByUserId(r.Context(), userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerListDrafts: failed to list drafts", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if drafts == nil {
+		drafts = []database.Draft{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(drafts)
+}
+
+func (a *apiConfig) handlerGetDraft(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerGetDraft")
+	if !ok {
+		return
+	}
+	id, ok := draftId(w, r, "handlerGetDraft")
+	if !ok {
+		return
+	}
+
+	draft, err := a.dbQueries.GetDraft(r.Context(), database.GetDraftParams{ID: id, UserID: userId})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Draft not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "handlerGetDraft: failed to get draft", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(draft)
+}
+
+// handlerUpdateDraft saves a new body. The client sends the updated_at it
+// last saw; if the draft has been saved since, say from another device, it
+// gets 409 with the current draft to merge with instead.
+func (a *apiConfig) handlerUpdateDraft(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerUpdateDraft")
+	if !ok {
+		return
+	}
+	id, ok := draftId(w, r, "handlerUpdateDraft")
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Body      string     `json:"body"`
+		UpdatedAt *time.Time `json:"updated_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		slog.InfoContext(r.Context(), "handlerUpdateDraft: failed to decode request body", "err", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if payload.UpdatedAt == nil {
+		http.Error(w, "updated_at is required", http.StatusBadRequest)
+		return
+	}
+	if err := checkDraftBody(payload.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	draft, err := a.dbQueries.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		Body:      payload.Body,
+		ID:        id,
+		UserID:    userId,
+		UpdatedAt: payload.UpdatedAt.UTC(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// missing, or saved since the caller's copy
+		draft, err = a.dbQueries.GetDraft(r.Context(), database.GetDraftParams{ID: id, UserID: userId})
+		if err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(draft)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Draft not found", http.StatusNotFound)
+			return
+		}
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerUpdateDraft: failed to update draft", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(draft)
+}
+
+func (a *apiConfig) handlerDeleteDraft(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerDeleteDraft")
+	if !ok {
+		return
+	}
+	id, ok := draftId(w, r, "handlerDeleteDraft")
+	if !ok {
+		return
+	}
+
+	if _, err := a.dbQueries.DeleteDraft(r.Context(), database.DeleteDraftParams{ID: id, UserID: userId}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Draft not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "handlerDeleteDraft: failed to delete draft", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerPublishDraft posts a draft as a chirp and deletes it, in one
+// transaction. A draft that fails validation is left as it is. The request
+// body is optional; an updated_at in it refuses to publish a draft that has
+// been edited since.
+func (a *apiConfig) handlerPublishDraft(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerPublishDraft")
+	if !ok {
+		return
+	}
+	id, ok := draftId(w, r, "handlerPublishDraft")
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		UpdatedAt *time.Time `json:"updated_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		slog.InfoContext(r.Context(), "handlerPublishDraft: failed to decode request body", "err", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	var draft database.Draft
+	var chirp database.Chirp
+	var links []string
+	var validationErr error
+	err := a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		validationErr = nil
+		var err error
+		draft, err = tx.DeleteDraft(r.Context(), database.DeleteDraftParams{ID: id, UserID: userId})
+		if err != nil {
+			return err
+		}
+		if payload.UpdatedAt != nil && !draft.UpdatedAt.Equal(*payload.UpdatedAt) {
+			return errDraftConflict
+		}
+		var body string
+		body, links, validationErr = ValidateChirp(draft.Body)
+		if validationErr != nil {
+			return validationErr
+		}
+		chirp, _, err = a.createChirp(r.Context(), tx, database.CreateChirpParams{Body: body, UserID: userId}, links, nil)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "Draft not found", http.StatusNotFound)
+		case errors.Is(err, errDraftConflict):
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(draft)
+		case validationErr != nil:
+			slog.InfoContext(r.Context(), "handlerPublishDraft: chirp validation failed", "err", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			slog.ErrorContext(r.Context(), "handlerPublishDraft: failed to publish draft", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	a.chirpCreated(chirp, links)
+	slog.InfoContext(r.Context(), "handlerPublishDraft: draft published", "draft_id", id, "chirp_id", chirp.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newChirpResponse(chirp))
+}
@@ -0,0 +1,37 @@
+package auth
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+type Permission string
+
+const (
+	PermDeleteAnyChirp Permission = "chirps:delete_any"
+	PermModerate       Permission = "chirps:moderate"
+	PermViewAdmin      Permission = "admin:view"
+	PermResetData      Permission = "admin:reset"
+	PermManageRoles    Permission = "users:manage_roles"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleUser:      {},
+	RoleModerator: {PermDeleteAnyChirp, PermModerate},
+	RoleAdmin:     {PermDeleteAnyChirp, PermModerate, PermViewAdmin, PermResetData, PermManageRoles},
+}
+
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func HasPermission(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -9,27 +9,58 @@ import (
 	"github.com/google/uuid"
 )
 
-func MakeJWT(userId uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) { 
-	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer: "chirpy",
-		IssuedAt: jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject : userId.String(),
+type chirpyClaims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role,omitempty"`
+}
+
+type TokenClaims struct {
+	UserID uuid.UUID
+	Role   string
+}
+
+func MakeJWT(userId uuid.UUID, role string, tokenSecret string, expiresIn time.Duration) (string, error) { 
+	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, chirpyClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer: "chirpy",
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject : userId.String(),
+		},
+		Role: role,
 	})
 	
 	return claims.SignedString([]byte(tokenSecret))
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) { 
-	claims := jwt.RegisteredClaims{}
+	claims, err := ParseJWT(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return claims.UserID, nil
+}
+
+// ParseJWT validates the token and returns its claims. Tokens issued before
+// roles existed carry no role claim and are treated as RoleUser.
+func ParseJWT(tokenString, tokenSecret string) (TokenClaims, error) {
+	claims := chirpyClaims{}
 	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(tokenSecret), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 	if err != nil {
-		return uuid.Nil, err
+		return TokenClaims{}, err
 	}
-	
-	return uuid.Parse(claims.Subject)
+
+	userId, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return TokenClaims{}, err
+	}
+	role := claims.Role
+	if role == "" {
+		role = RoleUser
+	}
+	return TokenClaims{UserID: userId, Role: role}, nil
 }
 
 func MakeRefreshToken() (string, error) {
@@ -12,7 +12,7 @@ func TestJwtToken(t *testing.T) {
 	tokenSecret := "my_secret_key"
 	expiresIn := 2 * time.Hour
 
-	token, err := MakeJWT(userId, tokenSecret, expiresIn)
+	token, err := MakeJWT(userId, RoleUser, tokenSecret, expiresIn)
 	if err != nil {
 		t.Fatalf("Error creating JWT: %v", err)
 	}
@@ -26,3 +26,43 @@ func TestJwtToken(t *testing.T) {
 		t.Fatalf("Expected userId %s, got %s", userId, returnedUserId)
 	}
 }
+
+func TestJwtTokenCarriesRole(t *testing.T) {
+	userId := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	tokenSecret := "my_secret_key"
+
+	token, err := MakeJWT(userId, RoleModerator, tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating JWT: %v", err)
+	}
+
+	claims, err := ParseJWT(token, tokenSecret)
+	if err != nil {
+		t.Fatalf("Error parsing JWT: %v", err)
+	}
+	if claims.UserID != userId || claims.Role != RoleModerator {
+		t.Fatalf("Expected %s with role %s, got %s with role %s", userId, RoleModerator, claims.UserID, claims.Role)
+	}
+
+	if _, err := ParseJWT(token, "wrong_secret"); err == nil {
+		t.Fatalf("Expected token signed with another secret to be rejected")
+	}
+}
+
+func TestRolePermissions(t *testing.T) {
+	if HasPermission(RoleUser, PermDeleteAnyChirp) {
+		t.Fatalf("Expected users not to delete other users' chirps")
+	}
+	if !HasPermission(RoleModerator, PermDeleteAnyChirp) {
+		t.Fatalf("Expected moderators to delete any chirp")
+	}
+	if HasPermission(RoleModerator, PermViewAdmin) {
+		t.Fatalf("Expected moderators not to view the admin dashboard")
+	}
+	if !HasPermission(RoleAdmin, PermManageRoles) {
+		t.Fatalf("Expected admins to manage roles")
+	}
+	if ValidRole("superuser") {
+		t.Fatalf("Expected unknown role to be invalid")
+	}
+}
@@ -0,0 +1,226 @@
+package compression
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Responses smaller than this are sent as-is; compressing them costs more CPU
+// than the bytes it saves.
+const minSize = 1024
+
+var gzipPool = sync.Pool{New: func() any {
+	w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+	return w
+}}
+
+var zstdPool = sync.Pool{New: func() any {
+	w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true))
+	return w
+}}
+
+type encoder interface {
+	io.WriteCloser
+	Reset(io.Writer)
+	Flush() error
+}
+
+// Middleware compresses responses with zstd or gzip, whichever the client
+// prefers in Accept-Encoding (zstd on a tie). Responses that already carry a
+// Content-Encoding, such as precompressed static files, pass through.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiate picks the highest weighted encoding we support, or "" when the
+// client accepts neither.
+func negotiate(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "zstd" && name != "gzip" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ || (q == bestQ && q > 0 && name == "zstd") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml":
+		return true
+	}
+	return false
+}
+
+// compressWriter buffers the start of the body until it knows whether the
+// response is worth compressing: big enough, a compressible type, and not
+// already encoded.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.decided || c.status != 0 {
+		return
+	}
+	c.status = code
+	// bodiless and informational responses have nothing to compress
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		c.passThrough()
+	}
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if c.decided {
+		if c.enc != nil {
+			return c.enc.Write(b)
+		}
+		return c.ResponseWriter.Write(b)
+	}
+
+	c.buf = append(c.buf, b...)
+	if len(c.buf) >= minSize {
+		if err := c.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		if c.status == 0 {
+			c.status = http.StatusOK
+		}
+		c.decide()
+	}
+	if c.enc != nil {
+		c.enc.Flush()
+	}
+	http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Close sends whatever is still buffered and returns the encoder to its pool.
+func (c *compressWriter) Close() error {
+	if !c.decided {
+		if c.status == 0 {
+			// the handler wrote nothing at all; let net/http send its default
+			return nil
+		}
+		if len(c.buf) < minSize {
+			c.passThrough()
+		} else if err := c.decide(); err != nil {
+			return err
+		}
+	}
+	if c.enc == nil {
+		return nil
+	}
+	err := c.enc.Close()
+	c.enc.Reset(nil)
+	if c.encoding == "zstd" {
+		zstdPool.Put(c.enc)
+	} else {
+		gzipPool.Put(c.enc)
+	}
+	c.enc = nil
+	return err
+}
+
+func (c *compressWriter) decide() error {
+	h := c.Header()
+	if h.Get("Content-Type") == "" && len(c.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(c.buf))
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" || !compressible(h.Get("Content-Type")) {
+		return c.passThrough()
+	}
+
+	c.decided = true
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", c.encoding)
+	// the compressed bytes differ, so a strong validator would be a lie
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	if c.encoding == "zstd" {
+		c.enc = zstdPool.Get().(*zstd.Encoder)
+	} else {
+		c.enc = gzipPool.Get().(*gzip.Writer)
+	}
+	c.enc.Reset(c.ResponseWriter)
+
+	c.ResponseWriter.WriteHeader(c.status)
+	if len(c.buf) == 0 {
+		return nil
+	}
+	_, err := c.enc.Write(c.buf)
+	c.buf = nil
+	return err
+}
+
+func (c *compressWriter) passThrough() error {
+	c.decided = true
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.ResponseWriter.WriteHeader(c.status)
+	if len(c.buf) == 0 {
+		return nil
+	}
+	_, err := c.ResponseWriter.Write(c.buf)
+	c.buf = nil
+	return err
+}
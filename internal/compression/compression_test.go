@@ -0,0 +1,110 @@
+package compression
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+var largeJSON = `[` + strings.Repeat(`{"body":"hello chirpy"},`, 200) + `{}]`
+
+func serve(h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/chirps", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func jsonHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"abc"`)
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, body)
+	})
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                       "",
+		"br":                     "",
+		"gzip":                   "gzip",
+		"gzip, zstd":             "zstd",
+		"zstd;q=0.5, gzip":       "gzip",
+		"gzip;q=0, zstd;q=0":     "",
+		"GZIP;q=0.8, deflate":    "gzip",
+		"zstd;q=0.9, gzip;q=0.9": "zstd",
+	}
+	for header, want := range cases {
+		if got := negotiate(header); got != want {
+			t.Fatalf("Expected %q for Accept-Encoding %q, got %q", want, header, got)
+		}
+	}
+}
+
+func TestCompressesLargeJSON(t *testing.T) {
+	h := Middleware(jsonHandler(largeJSON))
+
+	rec := serve(h, "gzip")
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip encoding, got %q", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("ETag") != `W/"abc"` {
+		t.Fatalf("Expected ETag to be weakened, got %q", rec.Header().Get("ETag"))
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Error opening gzip body: %v", err)
+	}
+	body, _ := io.ReadAll(gz)
+	if string(body) != largeJSON {
+		t.Fatalf("Expected decompressed body to match original")
+	}
+
+	rec = serve(h, "zstd")
+	if rec.Header().Get("Content-Encoding") != "zstd" {
+		t.Fatalf("Expected zstd encoding, got %q", rec.Header().Get("Content-Encoding"))
+	}
+	zr, err := zstd.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Error opening zstd body: %v", err)
+	}
+	defer zr.Close()
+	body, _ = io.ReadAll(zr)
+	if string(body) != largeJSON {
+		t.Fatalf("Expected decompressed body to match original")
+	}
+}
+
+func TestSkipsSmallAndEncodedResponses(t *testing.T) {
+	rec := serve(Middleware(jsonHandler(`{"ok":true}`)), "gzip")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != `{"ok":true}` {
+		t.Fatalf("Expected small response to be sent uncompressed, got encoding %q", rec.Header().Get("Content-Encoding"))
+	}
+
+	precompressed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript")
+		w.Header().Set("Content-Encoding", "br")
+		io.WriteString(w, strings.Repeat("x", 4*minSize))
+	})
+	rec = serve(Middleware(precompressed), "gzip, br")
+	if rec.Header().Get("Content-Encoding") != "br" || rec.Body.Len() != 4*minSize {
+		t.Fatalf("Expected precompressed response to pass through, got encoding %q", rec.Header().Get("Content-Encoding"))
+	}
+
+	notModified := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+	rec = serve(Middleware(notModified), "gzip")
+	if rec.Code != http.StatusNotModified || rec.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("Expected bare 304, got %d with encoding %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+}
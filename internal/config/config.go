@@ -0,0 +1,673 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonvanw/chirpy/internal/logging"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	minSecretLength        = 32
+	minAdminPasswordLength = 12
+)
+
+type Config struct {
+	ListenAddr string           `yaml:"listen_addr"`
+	Platform   string           `yaml:"platform"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Auth       AuthConfig       `yaml:"auth"`
+	Polka      PolkaConfig      `yaml:"polka"`
+	Server     ServerConfig     `yaml:"server"`
+	Log        LogConfig        `yaml:"log"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Bootstrap  BootstrapConfig  `yaml:"bootstrap"`
+	PageViews  PageViewsConfig  `yaml:"page_views"`
+	Static     StaticConfig     `yaml:"static"`
+	Chirps     ChirpsConfig     `yaml:"chirps"`
+	Moderation ModerationConfig `yaml:"moderation"`
+	Media      MediaConfig      `yaml:"media"`
+	Links      LinksConfig      `yaml:"links"`
+	Notify     NotifyConfig     `yaml:"notifications"`
+}
+
+type DatabaseConfig struct {
+	URL             string        `yaml:"url"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	// MigrateOnStart applies pending migrations before serving.
+	MigrateOnStart bool `yaml:"migrate_on_start"`
+}
+
+type AuthConfig struct {
+	JWTSecret       string        `yaml:"jwt_secret"`
+	JWTDuration     time.Duration `yaml:"jwt_duration"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+}
+
+type PolkaConfig struct {
+	APIKey string `yaml:"api_key"`
+}
+
+// ServerConfig tunes the HTTP server. On shutdown, health checks report
+// draining for DrainDelay before the listeners close, so load balancers stop
+// sending new requests first; in-flight ones then get ShutdownTimeout.
+type ServerConfig struct {
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+	DrainDelay        time.Duration `yaml:"drain_delay"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	MaxBodyBytes      int64         `yaml:"max_body_bytes"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+	Redact bool   `yaml:"redact"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// BootstrapConfig creates or promotes the first admin at startup. It has no
+// effect once any admin exists.
+type BootstrapConfig struct {
+	AdminEmail    string `yaml:"admin_email"`
+	AdminPassword string `yaml:"admin_password"`
+}
+
+type PageViewsConfig struct {
+	FlushInterval time.Duration `yaml:"flush_interval"`
+}
+
+// StaticConfig controls the files served under /app/. With no Dir the
+// assets embedded in the binary are used.
+type StaticConfig struct {
+	Dir         string        `yaml:"dir"`
+	MaxAge      time.Duration `yaml:"max_age"`
+	SPAFallback bool          `yaml:"spa_fallback"`
+}
+
+// ChirpsConfig controls how long deleted chirps are kept. Authors can restore
+// their own deletions for RestoreWindow; rows deleted more than PurgeAfter ago
+// are removed for good every PurgeInterval. Scheduled chirps that are due are
+// published every PublishInterval; users without Chirpy Red may have at most
+// ScheduledLimit waiting.
+type ChirpsConfig struct {
+	RestoreWindow   time.Duration `yaml:"restore_window"`
+	PurgeAfter      time.Duration `yaml:"purge_after"`
+	PurgeInterval   time.Duration `yaml:"purge_interval"`
+	PublishInterval time.Duration `yaml:"publish_interval"`
+	ScheduledLimit  int           `yaml:"scheduled_limit"`
+}
+
+// ModerationConfig controls the report queue. A chirp is hidden pending review
+// once its open case reaches AutoHideThreshold reports; 0 turns that off.
+type ModerationConfig struct {
+	AutoHideThreshold int `yaml:"auto_hide_threshold"`
+}
+
+// MediaConfig controls image uploads. Storage picks the backend; only "local",
+// which keeps files in Dir and serves them under BaseURL, exists so far.
+// Uploads never attached to a chirp are deleted after UnattachedTTL.
+type MediaConfig struct {
+	Storage        string        `yaml:"storage"`
+	Dir            string        `yaml:"dir"`
+	BaseURL        string        `yaml:"base_url"`
+	MaxUploadBytes int64         `yaml:"max_upload_bytes"`
+	MaxPixels      int           `yaml:"max_pixels"`
+	MaxFrames      int           `yaml:"max_frames"`
+	MaxTotalPixels int           `yaml:"max_total_pixels"`
+	ThumbnailSize  int           `yaml:"thumbnail_size"`
+	UnattachedTTL  time.Duration `yaml:"unattached_ttl"`
+}
+
+// LinksConfig controls link previews. Pages are fetched in the background,
+// each request limited to FetchTimeout and MaxBytes, and pending previews are
+// polled for every PollInterval. A page failing MaxAttempts times gets no
+// preview.
+type LinksConfig struct {
+	PreviewsEnabled bool          `yaml:"previews_enabled"`
+	FetchTimeout    time.Duration `yaml:"fetch_timeout"`
+	MaxBytes        int64         `yaml:"max_bytes"`
+	PollInterval    time.Duration `yaml:"poll_interval"`
+	MaxAttempts     int           `yaml:"max_attempts"`
+}
+
+// NotifyConfig controls notifications. They are fanned out in the
+// background as soon as they are queued, and queued events are polled for
+// every PollInterval.
+type NotifyConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+func Default() Config {
+	return Config{
+		ListenAddr: ":8080",
+		Database: DatabaseConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Auth: AuthConfig{
+			JWTDuration:     time.Hour,
+			RefreshTokenTTL: 60 * 24 * time.Hour,
+		},
+		Server: ServerConfig{
+			ReadTimeout:       10 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      15 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+			DrainDelay:        5 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			MaxBodyBytes:      1 << 20,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+			Redact: true,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "chirpy",
+			SampleRatio: 1,
+		},
+		PageViews: PageViewsConfig{
+			FlushInterval: 30 * time.Second,
+		},
+		Static: StaticConfig{
+			MaxAge:      time.Hour,
+			SPAFallback: true,
+		},
+		Chirps: ChirpsConfig{
+			RestoreWindow:   24 * time.Hour,
+			PurgeAfter:      30 * 24 * time.Hour,
+			PurgeInterval:   time.Hour,
+			PublishInterval: 15 * time.Second,
+			ScheduledLimit:  5,
+		},
+		Moderation: ModerationConfig{
+			AutoHideThreshold: 5,
+		},
+		Media: MediaConfig{
+			Storage:        "local",
+			Dir:            "media",
+			BaseURL:        "/media/",
+			MaxUploadBytes: 10 << 20,
+			MaxPixels:      40_000_000,
+			MaxFrames:      300,
+			MaxTotalPixels: 100_000_000,
+			ThumbnailSize:  400,
+			UnattachedTTL:  24 * time.Hour,
+		},
+		Links: LinksConfig{
+			PreviewsEnabled: true,
+			FetchTimeout:    5 * time.Second,
+			MaxBytes:        512 << 10,
+			PollInterval:    30 * time.Second,
+			MaxAttempts:     3,
+		},
+		Notify: NotifyConfig{
+			PollInterval: 30 * time.Second,
+		},
+	}
+}
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, an optional YAML file (-config or CHIRPY_CONFIG), environment
+// variables and command-line flags. The result is validated before returning.
+func Load(args []string) (Config, error) {
+	cfg, err := Parse(args)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Parse is Load without validation, for commands that only need part of the
+// configuration and check that part themselves.
+func Parse(args []string) (Config, error) {
+	cfg, _, err := ParseCommand(args, nil)
+	return cfg, err
+}
+
+// ParseCommand is Parse for CLI subcommands: extra registers the command's own
+// flags alongside the config flags, and the positional arguments left after
+// the flags are returned.
+func ParseCommand(args []string, extra func(*flag.FlagSet)) (Config, []string, error) {
+	// first pass only to find the config file; flags are applied for real last
+	probe := Default()
+	path := os.Getenv("CHIRPY_CONFIG")
+	if err := newFlagSet(&probe, &path, extra).Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	cfg := Default()
+	if path != "" {
+		if err := loadFile(&cfg, path); err != nil {
+			return Config{}, nil, err
+		}
+	}
+	if err := loadEnv(&cfg); err != nil {
+		return Config{}, nil, err
+	}
+	fs := newFlagSet(&cfg, &path, extra)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// Secrets deliberately have no flags so they never show up in process listings.
+func newFlagSet(cfg *Config, path *string, extra func(*flag.FlagSet)) *flag.FlagSet {
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	if extra != nil {
+		extra(fs)
+	}
+	fs.StringVar(path, "config", *path, "path to a YAML config file")
+	fs.StringVar(&cfg.ListenAddr, "addr", cfg.ListenAddr, "address to listen on")
+	fs.StringVar(&cfg.Platform, "platform", cfg.Platform, "deployment platform, e.g. dev")
+	fs.StringVar(&cfg.Database.URL, "db-url", cfg.Database.URL, "postgres connection URL")
+	fs.IntVar(&cfg.Database.MaxOpenConns, "db-max-open-conns", cfg.Database.MaxOpenConns, "maximum open database connections")
+	fs.IntVar(&cfg.Database.MaxIdleConns, "db-max-idle-conns", cfg.Database.MaxIdleConns, "maximum idle database connections")
+	fs.DurationVar(&cfg.Database.ConnMaxLifetime, "db-conn-max-lifetime", cfg.Database.ConnMaxLifetime, "maximum lifetime of a database connection")
+	fs.BoolVar(&cfg.Database.MigrateOnStart, "migrate", cfg.Database.MigrateOnStart, "apply pending database migrations before serving")
+	fs.DurationVar(&cfg.Auth.JWTDuration, "jwt-duration", cfg.Auth.JWTDuration, "lifetime of issued access tokens")
+	fs.DurationVar(&cfg.Auth.RefreshTokenTTL, "refresh-token-ttl", cfg.Auth.RefreshTokenTTL, "lifetime of issued refresh tokens")
+	fs.DurationVar(&cfg.Server.ReadTimeout, "read-timeout", cfg.Server.ReadTimeout, "HTTP server read timeout")
+	fs.DurationVar(&cfg.Server.ReadHeaderTimeout, "read-header-timeout", cfg.Server.ReadHeaderTimeout, "HTTP server read header timeout")
+	fs.DurationVar(&cfg.Server.WriteTimeout, "write-timeout", cfg.Server.WriteTimeout, "HTTP server write timeout")
+	fs.DurationVar(&cfg.Server.IdleTimeout, "idle-timeout", cfg.Server.IdleTimeout, "HTTP server idle timeout")
+	fs.DurationVar(&cfg.Server.ShutdownTimeout, "shutdown-timeout", cfg.Server.ShutdownTimeout, "time allowed to drain requests on shutdown")
+	fs.DurationVar(&cfg.Server.DrainDelay, "drain-delay", cfg.Server.DrainDelay, "how long health checks report draining before shutdown starts")
+	fs.IntVar(&cfg.Server.MaxHeaderBytes, "max-header-bytes", cfg.Server.MaxHeaderBytes, "maximum size of request headers")
+	fs.Int64Var(&cfg.Server.MaxBodyBytes, "max-body-bytes", cfg.Server.MaxBodyBytes, "maximum size of request bodies")
+	fs.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "minimum log level: debug, info, warn or error")
+	fs.StringVar(&cfg.Log.Format, "log-format", cfg.Log.Format, "log output format: text or json")
+	fs.BoolVar(&cfg.Log.Redact, "log-redact", cfg.Log.Redact, "redact secrets and personal data from logs")
+	fs.DurationVar(&cfg.PageViews.FlushInterval, "pageview-flush-interval", cfg.PageViews.FlushInterval, "how often buffered page views are written to the database")
+	fs.StringVar(&cfg.Static.Dir, "static-dir", cfg.Static.Dir, "directory served under /app/ instead of the embedded assets")
+	fs.DurationVar(&cfg.Static.MaxAge, "static-max-age", cfg.Static.MaxAge, "browser cache lifetime for non-HTML static assets")
+	fs.BoolVar(&cfg.Static.SPAFallback, "static-spa-fallback", cfg.Static.SPAFallback, "serve index.html for unknown extensionless paths under /app/")
+	fs.DurationVar(&cfg.Chirps.RestoreWindow, "chirp-restore-window", cfg.Chirps.RestoreWindow, "how long authors can restore a chirp they deleted")
+	fs.DurationVar(&cfg.Chirps.PurgeAfter, "chirp-purge-after", cfg.Chirps.PurgeAfter, "how long deleted chirps are kept before being purged")
+	fs.DurationVar(&cfg.Chirps.PurgeInterval, "chirp-purge-interval", cfg.Chirps.PurgeInterval, "how often deleted chirps are purged")
+	fs.DurationVar(&cfg.Chirps.PublishInterval, "chirp-publish-interval", cfg.Chirps.PublishInterval, "how often due scheduled chirps are published")
+	fs.IntVar(&cfg.Chirps.ScheduledLimit, "chirp-scheduled-limit", cfg.Chirps.ScheduledLimit, "pending scheduled chirps allowed per user without Chirpy Red")
+	fs.IntVar(&cfg.Moderation.AutoHideThreshold, "moderation-auto-hide-threshold", cfg.Moderation.AutoHideThreshold, "reports after which a chirp is hidden pending review, 0 to disable")
+	fs.StringVar(&cfg.Media.Storage, "media-storage", cfg.Media.Storage, "where uploaded images are kept: local")
+	fs.StringVar(&cfg.Media.Dir, "media-dir", cfg.Media.Dir, "directory for uploaded images with local storage")
+	fs.StringVar(&cfg.Media.BaseURL, "media-base-url", cfg.Media.BaseURL, "path or URL uploaded images are served from")
+	fs.Int64Var(&cfg.Media.MaxUploadBytes, "media-max-upload-bytes", cfg.Media.MaxUploadBytes, "maximum size of an image upload")
+	fs.IntVar(&cfg.Media.MaxPixels, "media-max-pixels", cfg.Media.MaxPixels, "maximum width times height of an uploaded image")
+	fs.IntVar(&cfg.Media.MaxFrames, "media-max-frames", cfg.Media.MaxFrames, "maximum frames in an uploaded GIF")
+	fs.IntVar(&cfg.Media.MaxTotalPixels, "media-max-total-pixels", cfg.Media.MaxTotalPixels, "maximum width times height summed over the frames of an uploaded GIF")
+	fs.IntVar(&cfg.Media.ThumbnailSize, "media-thumbnail-size", cfg.Media.ThumbnailSize, "longest side of generated thumbnails, in pixels")
+	fs.DurationVar(&cfg.Media.UnattachedTTL, "media-unattached-ttl", cfg.Media.UnattachedTTL, "how long uploads not attached to a chirp are kept")
+	fs.BoolVar(&cfg.Links.PreviewsEnabled, "link-previews", cfg.Links.PreviewsEnabled, "fetch previews of links in chirps")
+	fs.DurationVar(&cfg.Links.FetchTimeout, "link-preview-timeout", cfg.Links.FetchTimeout, "time allowed to fetch a linked page")
+	fs.Int64Var(&cfg.Links.MaxBytes, "link-preview-max-bytes", cfg.Links.MaxBytes, "maximum bytes read from a linked page")
+	fs.DurationVar(&cfg.Links.PollInterval, "link-preview-poll-interval", cfg.Links.PollInterval, "how often pending link previews are fetched")
+	fs.IntVar(&cfg.Links.MaxAttempts, "link-preview-max-attempts", cfg.Links.MaxAttempts, "fetch attempts before a link is left without a preview")
+	fs.DurationVar(&cfg.Notify.PollInterval, "notification-poll-interval", cfg.Notify.PollInterval, "how often queued notifications are fanned out")
+	fs.StringVar(&cfg.Tracing.Exporter, "trace-exporter", cfg.Tracing.Exporter, "trace exporter: none, stdout or otlp")
+	fs.StringVar(&cfg.Tracing.Endpoint, "trace-endpoint", cfg.Tracing.Endpoint, "OTLP/HTTP collector endpoint, e.g. localhost:4318")
+	fs.Float64Var(&cfg.Tracing.SampleRatio, "trace-sample-ratio", cfg.Tracing.SampleRatio, "fraction of new traces to sample, between 0 and 1")
+	return fs
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: reading %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil {
+			return fmt.Errorf("config: parsing %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("config: unsupported config file type %q, expected .yaml or .yml", filepath.Ext(path))
+	}
+	return nil
+}
+
+func loadEnv(cfg *Config) error {
+	var errs []error
+	envString("LISTEN_ADDR", &cfg.ListenAddr)
+	envString("PLATFORM", &cfg.Platform)
+	envString("DB_URL", &cfg.Database.URL)
+	errs = append(errs, envInt("DB_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns))
+	errs = append(errs, envInt("DB_MAX_IDLE_CONNS", &cfg.Database.MaxIdleConns))
+	errs = append(errs, envDuration("DB_CONN_MAX_LIFETIME", &cfg.Database.ConnMaxLifetime))
+	errs = append(errs, envBool("DB_MIGRATE_ON_START", &cfg.Database.MigrateOnStart))
+	envString("JWT_AUTH_SECRET", &cfg.Auth.JWTSecret)
+	errs = append(errs, envDuration("JWT_DURATION", &cfg.Auth.JWTDuration))
+	errs = append(errs, envDuration("REFRESH_TOKEN_TTL", &cfg.Auth.RefreshTokenTTL))
+	envString("POLKA_KEY", &cfg.Polka.APIKey)
+	errs = append(errs, envDuration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout))
+	errs = append(errs, envDuration("SERVER_READ_HEADER_TIMEOUT", &cfg.Server.ReadHeaderTimeout))
+	errs = append(errs, envDuration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout))
+	errs = append(errs, envDuration("SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout))
+	errs = append(errs, envDuration("SERVER_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout))
+	errs = append(errs, envDuration("SERVER_DRAIN_DELAY", &cfg.Server.DrainDelay))
+	errs = append(errs, envInt("SERVER_MAX_HEADER_BYTES", &cfg.Server.MaxHeaderBytes))
+	errs = append(errs, envInt64("SERVER_MAX_BODY_BYTES", &cfg.Server.MaxBodyBytes))
+	envString("LOG_LEVEL", &cfg.Log.Level)
+	envString("LOG_FORMAT", &cfg.Log.Format)
+	errs = append(errs, envBool("LOG_REDACT", &cfg.Log.Redact))
+	envString("TRACING_EXPORTER", &cfg.Tracing.Exporter)
+	envString("TRACING_ENDPOINT", &cfg.Tracing.Endpoint)
+	errs = append(errs, envBool("TRACING_INSECURE", &cfg.Tracing.Insecure))
+	envString("TRACING_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	errs = append(errs, envFloat("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio))
+	errs = append(errs, envDuration("PAGEVIEW_FLUSH_INTERVAL", &cfg.PageViews.FlushInterval))
+	envString("STATIC_DIR", &cfg.Static.Dir)
+	errs = append(errs, envDuration("STATIC_MAX_AGE", &cfg.Static.MaxAge))
+	errs = append(errs, envBool("STATIC_SPA_FALLBACK", &cfg.Static.SPAFallback))
+	errs = append(errs, envDuration("CHIRP_RESTORE_WINDOW", &cfg.Chirps.RestoreWindow))
+	errs = append(errs, envDuration("CHIRP_PURGE_AFTER", &cfg.Chirps.PurgeAfter))
+	errs = append(errs, envDuration("CHIRP_PURGE_INTERVAL", &cfg.Chirps.PurgeInterval))
+	errs = append(errs, envDuration("CHIRP_PUBLISH_INTERVAL", &cfg.Chirps.PublishInterval))
+	errs = append(errs, envInt("CHIRP_SCHEDULED_LIMIT", &cfg.Chirps.ScheduledLimit))
+	errs = append(errs, envInt("MODERATION_AUTO_HIDE_THRESHOLD", &cfg.Moderation.AutoHideThreshold))
+	envString("MEDIA_STORAGE", &cfg.Media.Storage)
+	envString("MEDIA_DIR", &cfg.Media.Dir)
+	envString("MEDIA_BASE_URL", &cfg.Media.BaseURL)
+	errs = append(errs, envInt64("MEDIA_MAX_UPLOAD_BYTES", &cfg.Media.MaxUploadBytes))
+	errs = append(errs, envInt("MEDIA_MAX_PIXELS", &cfg.Media.MaxPixels))
+	errs = append(errs, envInt("MEDIA_MAX_FRAMES", &cfg.Media.MaxFrames))
+	errs = append(errs, envInt("MEDIA_MAX_TOTAL_PIXELS", &cfg.Media.MaxTotalPixels))
+	errs = append(errs, envInt("MEDIA_THUMBNAIL_SIZE", &cfg.Media.ThumbnailSize))
+	errs = append(errs, envDuration("MEDIA_UNATTACHED_TTL", &cfg.Media.UnattachedTTL))
+	errs = append(errs, envBool("LINK_PREVIEWS_ENABLED", &cfg.Links.PreviewsEnabled))
+	errs = append(errs, envDuration("LINK_PREVIEW_TIMEOUT", &cfg.Links.FetchTimeout))
+	errs = append(errs, envInt64("LINK_PREVIEW_MAX_BYTES", &cfg.Links.MaxBytes))
+	errs = append(errs, envDuration("LINK_PREVIEW_POLL_INTERVAL", &cfg.Links.PollInterval))
+	errs = append(errs, envInt("LINK_PREVIEW_MAX_ATTEMPTS", &cfg.Links.MaxAttempts))
+	errs = append(errs, envDuration("NOTIFICATION_POLL_INTERVAL", &cfg.Notify.PollInterval))
+	envString("BOOTSTRAP_ADMIN_EMAIL", &cfg.Bootstrap.AdminEmail)
+	envString("BOOTSTRAP_ADMIN_PASSWORD", &cfg.Bootstrap.AdminPassword)
+	return errors.Join(errs...)
+}
+
+func envString(name string, dst *string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*dst = v
+	}
+}
+
+func envInt(name string, dst *int) error {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("config: %s must be an integer, got %q", name, v)
+	}
+	*dst = n
+	return nil
+}
+
+func envInt64(name string, dst *int64) error {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return fmt.Errorf("config: %s must be an integer, got %q", name, v)
+	}
+	*dst = n
+	return nil
+}
+
+func envBool(name string, dst *bool) error {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("config: %s must be true or false, got %q", name, v)
+	}
+	*dst = b
+	return nil
+}
+
+func envFloat(name string, dst *float64) error {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("config: %s must be a number, got %q", name, v)
+	}
+	*dst = f
+	return nil
+}
+
+func envDuration(name string, dst *time.Duration) error {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("config: %s must be a duration like 30s or 1h, got %q", name, v)
+	}
+	*dst = d
+	return nil
+}
+
+// Validate reports every problem at once rather than stopping at the first.
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("config: "+format, args...))
+	}
+
+	if c.ListenAddr == "" {
+		fail("listen address is required (LISTEN_ADDR or -addr)")
+	}
+	if err := c.ValidateDatabase(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Auth.JWTSecret == "" {
+		fail("JWT secret is required (JWT_AUTH_SECRET)")
+	} else if err := checkSecretStrength(c.Auth.JWTSecret); err != nil {
+		fail("JWT secret is too weak: %v", err)
+	}
+	if c.Auth.JWTDuration <= 0 {
+		fail("JWT duration must be positive, got %s", c.Auth.JWTDuration)
+	}
+	if c.Auth.RefreshTokenTTL < c.Auth.JWTDuration {
+		fail("refresh token TTL (%s) must not be shorter than the JWT duration (%s)", c.Auth.RefreshTokenTTL, c.Auth.JWTDuration)
+	}
+
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"read timeout", c.Server.ReadTimeout},
+		{"read header timeout", c.Server.ReadHeaderTimeout},
+		{"write timeout", c.Server.WriteTimeout},
+		{"idle timeout", c.Server.IdleTimeout},
+		{"shutdown timeout", c.Server.ShutdownTimeout},
+	} {
+		if t.d <= 0 {
+			fail("server %s must be positive, got %s", t.name, t.d)
+		}
+	}
+	if c.Server.DrainDelay < 0 {
+		fail("server drain delay must not be negative, got %s", c.Server.DrainDelay)
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		fail("server max header bytes must be positive, got %d", c.Server.MaxHeaderBytes)
+	}
+	if c.Server.MaxBodyBytes <= 0 {
+		fail("server max body bytes must be positive, got %d", c.Server.MaxBodyBytes)
+	}
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		fail("%v", err)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		fail("log format must be text or json, got %q", c.Log.Format)
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		fail("trace exporter must be none, stdout or otlp, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("trace sample ratio must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	}
+	if c.Tracing.Exporter != "none" && c.Tracing.ServiceName == "" {
+		fail("tracing service name is required when tracing is enabled")
+	}
+
+	if c.PageViews.FlushInterval <= 0 {
+		fail("page view flush interval must be positive, got %s", c.PageViews.FlushInterval)
+	}
+
+	if c.Static.MaxAge < 0 {
+		fail("static max age must not be negative, got %s", c.Static.MaxAge)
+	}
+
+	if c.Chirps.RestoreWindow < 0 {
+		fail("chirp restore window must not be negative, got %s", c.Chirps.RestoreWindow)
+	}
+	if c.Chirps.PurgeAfter < c.Chirps.RestoreWindow {
+		fail("chirp purge age %s must not be shorter than the restore window %s", c.Chirps.PurgeAfter, c.Chirps.RestoreWindow)
+	}
+	if c.Chirps.PurgeInterval <= 0 {
+		fail("chirp purge interval must be positive, got %s", c.Chirps.PurgeInterval)
+	}
+	if c.Chirps.PublishInterval <= 0 {
+		fail("chirp publish interval must be positive, got %s", c.Chirps.PublishInterval)
+	}
+	if c.Chirps.ScheduledLimit < 0 {
+		fail("chirp scheduled limit must not be negative, got %d", c.Chirps.ScheduledLimit)
+	}
+
+	if c.Moderation.AutoHideThreshold < 0 {
+		fail("moderation auto-hide threshold must not be negative, got %d", c.Moderation.AutoHideThreshold)
+	}
+
+	if c.Media.Storage != "local" {
+		fail("unknown media storage %q, expected local", c.Media.Storage)
+	} else if c.Media.Dir == "" {
+		fail("media dir is required for local storage")
+	}
+	if c.Media.MaxUploadBytes <= 0 {
+		fail("media max upload bytes must be positive, got %d", c.Media.MaxUploadBytes)
+	}
+	if c.Media.MaxPixels <= 0 {
+		fail("media max pixels must be positive, got %d", c.Media.MaxPixels)
+	}
+	if c.Media.MaxFrames <= 0 {
+		fail("media max frames must be positive, got %d", c.Media.MaxFrames)
+	}
+	if c.Media.MaxTotalPixels < c.Media.MaxPixels {
+		fail("media max total pixels %d must not be less than max pixels %d", c.Media.MaxTotalPixels, c.Media.MaxPixels)
+	}
+	if c.Media.ThumbnailSize <= 0 {
+		fail("media thumbnail size must be positive, got %d", c.Media.ThumbnailSize)
+	}
+	if c.Media.UnattachedTTL <= 0 {
+		fail("media unattached TTL must be positive, got %s", c.Media.UnattachedTTL)
+	}
+
+	if c.Links.PreviewsEnabled {
+		if c.Links.FetchTimeout <= 0 {
+			fail("link preview timeout must be positive, got %s", c.Links.FetchTimeout)
+		}
+		if c.Links.MaxBytes <= 0 {
+			fail("link preview max bytes must be positive, got %d", c.Links.MaxBytes)
+		}
+		if c.Links.PollInterval <= 0 {
+			fail("link preview poll interval must be positive, got %s", c.Links.PollInterval)
+		}
+		if c.Links.MaxAttempts < 1 {
+			fail("link preview max attempts must be at least 1, got %d", c.Links.MaxAttempts)
+		}
+	}
+
+	if c.Notify.PollInterval <= 0 {
+		fail("notification poll interval must be positive, got %s", c.Notify.PollInterval)
+	}
+
+	if (c.Bootstrap.AdminEmail == "") != (c.Bootstrap.AdminPassword == "") {
+		fail("bootstrap admin needs both BOOTSTRAP_ADMIN_EMAIL and BOOTSTRAP_ADMIN_PASSWORD")
+	} else if c.Bootstrap.AdminPassword != "" && len(c.Bootstrap.AdminPassword) < minAdminPasswordLength {
+		fail("bootstrap admin password must be at least %d characters", minAdminPasswordLength)
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateDatabase checks only the database settings.
+func (c Config) ValidateDatabase() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("config: "+format, args...))
+	}
+
+	if c.Database.URL == "" {
+		fail("database URL is required (DB_URL or -db-url)")
+	}
+	if c.Database.MaxOpenConns < 1 {
+		fail("database max open connections must be at least 1, got %d", c.Database.MaxOpenConns)
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		fail("database max idle connections must be between 0 and max open connections (%d), got %d", c.Database.MaxOpenConns, c.Database.MaxIdleConns)
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		fail("database connection max lifetime must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+func checkSecretStrength(secret string) error {
+	if len(secret) < minSecretLength {
+		return fmt.Errorf("must be at least %d bytes, got %d", minSecretLength, len(secret))
+	}
+	distinct := make(map[rune]struct{})
+	for _, r := range secret {
+		distinct[r] = struct{}{}
+	}
+	if len(distinct) < 8 {
+		return errors.New("must not be made of a handful of repeated characters")
+	}
+	return nil
+}
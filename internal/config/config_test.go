@@ -0,0 +1,119 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "chirpy.yaml")
+	file := `
+listen_addr: ":9000"
+database:
+  url: postgres://file
+  max_open_conns: 10
+  max_idle_conns: 5
+auth:
+  jwt_duration: 30m
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+
+	t.Setenv("CHIRPY_CONFIG", path)
+	t.Setenv("DB_URL", "postgres://env")
+	t.Setenv("JWT_AUTH_SECRET", testSecret)
+
+	cfg, err := Load([]string{"-addr", ":9100"})
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+
+	if cfg.ListenAddr != ":9100" {
+		t.Fatalf("Expected flag to override file, got listen address %q", cfg.ListenAddr)
+	}
+	if cfg.Database.URL != "postgres://env" {
+		t.Fatalf("Expected env to override file, got database URL %q", cfg.Database.URL)
+	}
+	if cfg.Database.MaxOpenConns != 10 {
+		t.Fatalf("Expected max open conns from file, got %d", cfg.Database.MaxOpenConns)
+	}
+	if cfg.Auth.JWTDuration != 30*time.Minute {
+		t.Fatalf("Expected JWT duration from file, got %s", cfg.Auth.JWTDuration)
+	}
+	if cfg.Auth.RefreshTokenTTL != 60*24*time.Hour {
+		t.Fatalf("Expected default refresh token TTL, got %s", cfg.Auth.RefreshTokenTTL)
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := Default()
+	cfg.Auth.JWTSecret = "short"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("Expected validation to fail")
+	}
+	for _, want := range []string{"database URL is required", "JWT secret is too weak"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("Expected error to mention %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestValidateRejectsRepetitiveSecret(t *testing.T) {
+	cfg := Default()
+	cfg.Database.URL = "postgres://localhost"
+	cfg.Auth.JWTSecret = strings.Repeat("ab", 32)
+
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("Expected repetitive secret to be rejected")
+	}
+
+	cfg.Auth.JWTSecret = testSecret
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid config, got: %v", err)
+	}
+}
+
+func TestLoadRejectsInvalidEnv(t *testing.T) {
+	t.Setenv("CHIRPY_CONFIG", "")
+	t.Setenv("DB_URL", "postgres://env")
+	t.Setenv("JWT_AUTH_SECRET", testSecret)
+	t.Setenv("JWT_DURATION", "an hour")
+
+	_, err := Load(nil)
+	if err == nil || !strings.Contains(err.Error(), "JWT_DURATION") {
+		t.Fatalf("Expected error naming JWT_DURATION, got: %v", err)
+	}
+}
+
+func TestParseCommandExtraFlags(t *testing.T) {
+	t.Setenv("CHIRPY_CONFIG", "")
+	t.Setenv("DB_URL", "postgres://env")
+
+	var email string
+	cfg, rest, err := ParseCommand([]string{"-email", "a@example.com", "-db-url", "postgres://flag", "extra"}, func(fs *flag.FlagSet) {
+		fs.StringVar(&email, "email", "", "")
+	})
+	if err != nil {
+		t.Fatalf("Error parsing command: %v", err)
+	}
+
+	if email != "a@example.com" {
+		t.Fatalf("Expected command flag to be parsed, got %q", email)
+	}
+	if cfg.Database.URL != "postgres://flag" {
+		t.Fatalf("Expected config flag to be parsed, got database URL %q", cfg.Database.URL)
+	}
+	if len(rest) != 1 || rest[0] != "extra" {
+		t.Fatalf("Expected positional arguments to be returned, got %v", rest)
+	}
+}
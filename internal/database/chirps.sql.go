@@ -7,29 +7,74 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countQuotesByChirpIds = `-- name: CountQuotesByChirpIds :many
+SELECT quoted_chirp_id, COUNT(*) AS quote_count
+FROM chirps
+WHERE quoted_chirp_id = ANY($1::uuid[]) AND deleted_at IS NULL
+GROUP BY quoted_chirp_id
+`
+
+type CountQuotesByChirpIdsRow struct {
+	QuotedChirpID uuid.NullUUID `json:"quoted_chirp_id"`
+	QuoteCount    int64         `json:"quote_count"`
+}
+
+func (q *Queries) CountQuotesByChirpIds(ctx context.Context, quotedChirpIds []uuid.UUID) ([]CountQuotesByChirpIdsRow, error) {
+	rows, err := q.db.QueryContext(ctx, countQuotesByChirpIds, pq.Array(quotedChirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountQuotesByChirpIdsRow
+	for rows.Next() {
+		var i CountQuotesByChirpIdsRow
+		if err := rows.Scan(
+			&i.QuotedChirpID,
+			&i.QuoteCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quoted_chirp_id, reply_to_chirp_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, taken_down_at, taken_down_by, takedown_reason, quoted_chirp_id, reply_to_chirp_id, counts_updated_at
 `
 
 type CreateChirpParams struct {
-	Body   string    `json:"body"`
-	UserID uuid.UUID `json:"user_id"`
+	Body           string        `json:"body"`
+	UserID         uuid.UUID     `json:"user_id"`
+	QuotedChirpID  uuid.NullUUID `json:"quoted_chirp_id"`
+	ReplyToChirpID uuid.NullUUID `json:"reply_to_chirp_id"`
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.QuotedChirpID, arg.ReplyToChirpID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,24 +82,92 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.TakenDownAt,
+		&i.TakenDownBy,
+		&i.TakedownReason,
+		&i.QuotedChirpID,
+		&i.ReplyToChirpID,
+		&i.CountsUpdatedAt,
 	)
 	return i, err
 }
 
-const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps
-WHERE id = $1
+const deleteChirp = `-- name: DeleteChirp :one
+UPDATE chirps
+SET deleted_at = NOW(), updated_at = NOW(), deleted_by = $2, deletion_reason = $3
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, taken_down_at, taken_down_by, takedown_reason, quoted_chirp_id, reply_to_chirp_id, counts_updated_at
 `
 
-func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteChirp, id)
-	return err
+type DeleteChirpParams struct {
+	ID             uuid.UUID      `json:"id"`
+	DeletedBy      uuid.NullUUID  `json:"deleted_by"`
+	DeletionReason sql.NullString `json:"deletion_reason"`
+}
+
+func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, deleteChirp, arg.ID, arg.DeletedBy, arg.DeletionReason)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.TakenDownAt,
+		&i.TakenDownBy,
+		&i.TakedownReason,
+		&i.QuotedChirpID,
+		&i.ReplyToChirpID,
+		&i.CountsUpdatedAt,
+	)
+	return i, err
+}
+
+const deleteChirpByAuthor = `-- name: DeleteChirpByAuthor :one
+UPDATE chirps
+SET deleted_at = NOW(), updated_at = NOW(), deleted_by = user_id
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, taken_down_at, taken_down_by, takedown_reason, quoted_chirp_id, reply_to_chirp_id, counts_updated_at
+`
+
+type DeleteChirpByAuthorParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) DeleteChirpByAuthor(ctx context.Context, arg DeleteChirpByAuthorParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, deleteChirpByAuthor, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.TakenDownAt,
+		&i.TakenDownBy,
+		&i.TakedownReason,
+		&i.QuotedChirpID,
+		&i.ReplyToChirpID,
+		&i.CountsUpdatedAt,
+	)
+	return i, err
 }
 
 const getChirpById = `-- name: GetChirpById :one
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, taken_down_at, taken_down_by, takedown_reason, quoted_chirp_id, reply_to_chirp_id, counts_updated_at
 FROM chirps
-WHERE id = $1
+WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -66,13 +179,51 @@ func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.TakenDownAt,
+		&i.TakenDownBy,
+		&i.TakedownReason,
+		&i.QuotedChirpID,
+		&i.ReplyToChirpID,
+		&i.CountsUpdatedAt,
+	)
+	return i, err
+}
+
+const getChirpByIdWithDeleted = `-- name: GetChirpByIdWithDeleted :one
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, taken_down_at, taken_down_by, takedown_reason, quoted_chirp_id, reply_to_chirp_id, counts_updated_at
+FROM chirps
+WHERE id = $1
+`
+
+func (q *Queries) GetChirpByIdWithDeleted(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIdWithDeleted, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.TakenDownAt,
+		&i.TakenDownBy,
+		&i.TakedownReason,
+		&i.QuotedChirpID,
+		&i.ReplyToChirpID,
+		&i.CountsUpdatedAt,
 	)
 	return i, err
 }
 
 const getChirpsByCreation = `-- name: GetChirpsByCreation :many
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, taken_down_at, taken_down_by, takedown_reason, quoted_chirp_id, reply_to_chirp_id, counts_updated_at
 FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC
 `
 
@@ -91,6 +242,59 @@ func (q *Queries) GetChirpsByCreation(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.DeletionReason,
+			&i.TakenDownAt,
+			&i.TakenDownBy,
+			&i.TakedownReason,
+			&i.QuotedChirpID,
+			&i.ReplyToChirpID,
+			&i.CountsUpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIds = `-- name: GetChirpsByIds :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, taken_down_at, taken_down_by, takedown_reason, quoted_chirp_id, reply_to_chirp_id, counts_updated_at
+FROM chirps
+WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL
+`
+
+func (q *Queries) GetChirpsByIds(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIds, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.DeletionReason,
+			&i.TakenDownAt,
+			&i.TakenDownBy,
+			&i.TakedownReason,
+			&i.QuotedChirpID,
+			&i.ReplyToChirpID,
+			&i.CountsUpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -106,9 +310,9 @@ func (q *Queries) GetChirpsByCreation(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserId = `-- name: GetChirpsByUserId :many
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, taken_down_at, taken_down_by, takedown_reason, quoted_chirp_id, reply_to_chirp_id, counts_updated_at
 FROM chirps
-WHERE user_id = $1
+WHERE user_id = $1 AND deleted_at IS NULL
 ORDER BY created_at ASC
 `
 
@@ -127,6 +331,15 @@ func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.DeletionReason,
+			&i.TakenDownAt,
+			&i.TakenDownBy,
+			&i.TakedownReason,
+			&i.QuotedChirpID,
+			&i.ReplyToChirpID,
+			&i.CountsUpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -140,3 +353,140 @@ func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const getChirpsVersion = `-- name: GetChirpsVersion :one
+SELECT COUNT(*) FILTER (WHERE deleted_at IS NULL) AS chirp_count,
+    COALESCE(MAX(updated_at), 'epoch')::timestamp AS last_updated,
+    COALESCE(MAX(counts_updated_at), 'epoch')::timestamp AS counts_updated
+FROM chirps
+`
+
+type GetChirpsVersionRow struct {
+	ChirpCount    int64     `json:"chirp_count"`
+	LastUpdated   time.Time `json:"last_updated"`
+	CountsUpdated time.Time `json:"counts_updated"`
+}
+
+func (q *Queries) GetChirpsVersion(ctx context.Context) (GetChirpsVersionRow, error) {
+	row := q.db.QueryRowContext(ctx, getChirpsVersion)
+	var i GetChirpsVersionRow
+	err := row.Scan(&i.ChirpCount, &i.LastUpdated, &i.CountsUpdated)
+	return i, err
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const reinstateChirp = `-- name: ReinstateChirp :one
+UPDATE chirps
+SET taken_down_at = NULL, taken_down_by = NULL, takedown_reason = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, taken_down_at, taken_down_by, takedown_reason, quoted_chirp_id, reply_to_chirp_id, counts_updated_at
+`
+
+func (q *Queries) ReinstateChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, reinstateChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.TakenDownAt,
+		&i.TakenDownBy,
+		&i.TakedownReason,
+		&i.QuotedChirpID,
+		&i.ReplyToChirpID,
+		&i.CountsUpdatedAt,
+	)
+	return i, err
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL, deletion_reason = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NOT NULL
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, taken_down_at, taken_down_by, takedown_reason, quoted_chirp_id, reply_to_chirp_id, counts_updated_at
+`
+
+func (q *Queries) RestoreChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.TakenDownAt,
+		&i.TakenDownBy,
+		&i.TakedownReason,
+		&i.QuotedChirpID,
+		&i.ReplyToChirpID,
+		&i.CountsUpdatedAt,
+	)
+	return i, err
+}
+
+const takeDownChirp = `-- name: TakeDownChirp :one
+UPDATE chirps
+SET taken_down_at = NOW(), taken_down_by = $2, takedown_reason = $3, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, taken_down_at, taken_down_by, takedown_reason, quoted_chirp_id, reply_to_chirp_id, counts_updated_at
+`
+
+type TakeDownChirpParams struct {
+	ID             uuid.UUID      `json:"id"`
+	TakenDownBy    uuid.NullUUID  `json:"taken_down_by"`
+	TakedownReason sql.NullString `json:"takedown_reason"`
+}
+
+func (q *Queries) TakeDownChirp(ctx context.Context, arg TakeDownChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, takeDownChirp, arg.ID, arg.TakenDownBy, arg.TakedownReason)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.TakenDownAt,
+		&i.TakenDownBy,
+		&i.TakedownReason,
+		&i.QuotedChirpID,
+		&i.ReplyToChirpID,
+		&i.CountsUpdatedAt,
+	)
+	return i, err
+}
+
+const touchChirpCounts = `-- name: TouchChirpCounts :exec
+UPDATE chirps
+SET counts_updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) TouchChirpCounts(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, touchChirpCounts, id)
+	return err
+}
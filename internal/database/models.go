@@ -12,6 +12,23 @@ import (
 )
 
 type Chirp struct {
+	ID              uuid.UUID      `json:"id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	Body            string         `json:"body"`
+	UserID          uuid.UUID      `json:"user_id"`
+	DeletedAt       sql.NullTime   `json:"deleted_at"`
+	DeletedBy       uuid.NullUUID  `json:"deleted_by"`
+	DeletionReason  sql.NullString `json:"deletion_reason"`
+	TakenDownAt     sql.NullTime   `json:"taken_down_at"`
+	TakenDownBy     uuid.NullUUID  `json:"taken_down_by"`
+	TakedownReason  sql.NullString `json:"takedown_reason"`
+	QuotedChirpID   uuid.NullUUID  `json:"quoted_chirp_id"`
+	ReplyToChirpID  uuid.NullUUID  `json:"reply_to_chirp_id"`
+	CountsUpdatedAt sql.NullTime   `json:"counts_updated_at"`
+}
+
+type Draft struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -19,6 +36,94 @@ type Chirp struct {
 	UserID    uuid.UUID `json:"user_id"`
 }
 
+type Like struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LinkPreview struct {
+	Url           string    `json:"url"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Status        string    `json:"status"`
+	Attempts      int32     `json:"attempts"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	ImageUrl      string    `json:"image_url"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+type MediaAttachment struct {
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UserID       uuid.UUID     `json:"user_id"`
+	ChirpID      uuid.NullUUID `json:"chirp_id"`
+	Position     sql.NullInt32 `json:"position"`
+	ContentType  string        `json:"content_type"`
+	Width        int32         `json:"width"`
+	Height       int32         `json:"height"`
+	SizeBytes    int64         `json:"size_bytes"`
+	StorageKey   string        `json:"storage_key"`
+	ThumbnailKey string        `json:"thumbnail_key"`
+}
+
+type ModerationAction struct {
+	ID           uuid.UUID      `json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	ModeratorID  uuid.NullUUID  `json:"moderator_id"`
+	CaseID       uuid.NullUUID  `json:"case_id"`
+	ChirpID      uuid.NullUUID  `json:"chirp_id"`
+	TargetUserID uuid.NullUUID  `json:"target_user_id"`
+	Action       string         `json:"action"`
+	Note         sql.NullString `json:"note"`
+}
+
+type ModerationCase struct {
+	ID          uuid.UUID      `json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	ChirpID     uuid.UUID      `json:"chirp_id"`
+	Status      string         `json:"status"`
+	ReportCount int32          `json:"report_count"`
+	ClaimedBy   uuid.NullUUID  `json:"claimed_by"`
+	ClaimedAt   sql.NullTime   `json:"claimed_at"`
+	Resolution  sql.NullString `json:"resolution"`
+	ResolvedBy  uuid.NullUUID  `json:"resolved_by"`
+	ResolvedAt  sql.NullTime   `json:"resolved_at"`
+}
+
+type Notification struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UserID    uuid.UUID     `json:"user_id"`
+	Type      string        `json:"type"`
+	ActorID   uuid.UUID     `json:"actor_id"`
+	ChirpID   uuid.NullUUID `json:"chirp_id"`
+	ReadAt    sql.NullTime  `json:"read_at"`
+}
+
+type NotificationEvent struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	Type      string        `json:"type"`
+	ActorID   uuid.UUID     `json:"actor_id"`
+	ChirpID   uuid.NullUUID `json:"chirp_id"`
+	UserID    uuid.NullUUID `json:"user_id"`
+}
+
+type NotificationPreference struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Type    string    `json:"type"`
+	Enabled bool      `json:"enabled"`
+}
+
+type Rechirp struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type RefreshToken struct {
 	Token     string       `json:"token"`
 	CreatedAt time.Time    `json:"created_at"`
@@ -28,11 +133,60 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime `json:"revoked_at"`
 }
 
+type Report struct {
+	ID         uuid.UUID      `json:"id"`
+	CreatedAt  time.Time      `json:"created_at"`
+	CaseID     uuid.UUID      `json:"case_id"`
+	ChirpID    uuid.UUID      `json:"chirp_id"`
+	ReporterID uuid.UUID      `json:"reporter_id"`
+	Reason     string         `json:"reason"`
+	Details    sql.NullString `json:"details"`
+}
+
+type ScheduledChirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+	PublishAt time.Time `json:"publish_at"`
+}
+
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
-	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ID             uuid.UUID      `json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	Email          string         `json:"email"`
+	HashedPassword string         `json:"hashed_password"`
+	IsChirpyRed    bool           `json:"is_chirpy_red"`
+	Role           string         `json:"role"`
+	DisabledAt     sql.NullTime   `json:"disabled_at"`
+	Username       sql.NullString `json:"username"`
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID `json:"blocker_id"`
+	BlockedID uuid.UUID `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserFollow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FollowedID uuid.UUID `json:"followed_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID `json:"muter_id"`
+	MutedID   uuid.UUID `json:"muted_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookEvent struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	Provider  string        `json:"provider"`
+	Event     string        `json:"event"`
+	UserID    uuid.NullUUID `json:"user_id"`
+	Outcome   string        `json:"outcome"`
 }
@@ -0,0 +1,14 @@
+package database
+
+import "strings"
+
+// QueryName extracts the name sqlc embeds at the start of every generated
+// query ("-- name: GetChirpById :one").
+func QueryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "unnamed"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
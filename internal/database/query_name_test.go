@@ -0,0 +1,16 @@
+package database
+
+import "testing"
+
+func TestQueryName(t *testing.T) {
+	cases := map[string]string{
+		getChirpById: "GetChirpById",
+		createUser:   "CreateUser",
+		"SELECT 1":   "unnamed",
+	}
+	for query, want := range cases {
+		if got := QueryName(query); got != want {
+			t.Fatalf("Expected query name %q, got %q", want, got)
+		}
+	}
+}
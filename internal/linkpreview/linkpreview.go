@@ -0,0 +1,196 @@
+// Package linkpreview fetches the OpenGraph and Twitter card metadata of
+// pages linked from chirps. Fetches run on the server, so they are guarded
+// against reaching private networks and capped in time and size.
+package linkpreview
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	ErrUnsupportedURL = errors.New("linkpreview: only http and https URLs are fetched")
+	ErrBlockedAddress = errors.New("linkpreview: address is not publicly routable")
+	ErrNotHTML        = errors.New("linkpreview: page is not HTML")
+	ErrNoPreview      = errors.New("linkpreview: page has no title or description")
+)
+
+const (
+	maxRedirects      = 3
+	maxTitleLength    = 200
+	maxDescLength     = 500
+	maxImageURLLength = 2048
+	userAgent         = "Chirpy-LinkPreview/1.0"
+)
+
+// Preview is what a page says about itself.
+type Preview struct {
+	Title       string
+	Description string
+	ImageURL    string
+}
+
+type Options struct {
+	// Timeout bounds a whole fetch, redirects included.
+	Timeout time.Duration
+	// MaxBytes is how much of the page is read. Metadata lives in <head>, so
+	// a truncated page usually still has it.
+	MaxBytes int64
+	// AllowPrivateNetworks turns the SSRF guard off. Only for tests against
+	// local servers.
+	AllowPrivateNetworks bool
+}
+
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+func NewFetcher(opts Options) *Fetcher {
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivateNetworks {
+		// checked on the resolved address of every connection, so DNS
+		// rebinding and redirects to internal hosts are caught too
+		dialer.Control = refusePrivateAddress
+	}
+	transport := &http.Transport{
+		// no proxy: it would dial on our behalf, past the address check
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   opts.Timeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	return &Fetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   opts.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("linkpreview: stopped after %d redirects", maxRedirects)
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return ErrUnsupportedURL
+				}
+				return nil
+			},
+		},
+		maxBytes: opts.MaxBytes,
+	}
+}
+
+// Fetch reads the preview metadata of the page at rawURL.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Preview{}, ErrUnsupportedURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Preview{}, fmt.Errorf("linkpreview: %s returned %s", u.Host, resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, fmt.Errorf("%w: %q", ErrNotHTML, mediaType)
+	}
+
+	preview := parse(io.LimitReader(resp.Body, f.maxBytes), resp.Request.URL)
+	if preview.Title == "" && preview.Description == "" {
+		return Preview{}, ErrNoPreview
+	}
+	return preview, nil
+}
+
+// parse reads <head> metadata, preferring OpenGraph, then Twitter cards, then
+// plain HTML. Relative image URLs are resolved against base.
+func parse(r io.Reader, base *url.URL) Preview {
+	meta := make(map[string]string)
+	var title string
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		if tok.DataAtom == atom.Body && tt == html.StartTagToken {
+			break
+		}
+		switch {
+		case tok.DataAtom == atom.Title && tt == html.StartTagToken && title == "":
+			if z.Next() == html.TextToken {
+				title = string(z.Text())
+			}
+		case tok.DataAtom == atom.Meta:
+			var key, content string
+			for _, attr := range tok.Attr {
+				switch attr.Key {
+				case "property", "name":
+					key = strings.ToLower(strings.TrimSpace(attr.Val))
+				case "content":
+					content = attr.Val
+				}
+			}
+			if _, seen := meta[key]; key != "" && !seen {
+				meta[key] = content
+			}
+		}
+	}
+
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v := clean(meta[k]); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	p := Preview{
+		Title:       truncate(cmp.Or(first("og:title", "twitter:title"), clean(title)), maxTitleLength),
+		Description: truncate(first("og:description", "twitter:description", "description"), maxDescLength),
+	}
+	if image := first("og:image:secure_url", "og:image:url", "og:image", "twitter:image", "twitter:image:src"); image != "" {
+		if ref, err := base.Parse(image); err == nil && (ref.Scheme == "http" || ref.Scheme == "https") && len(ref.String()) <= maxImageURLLength {
+			p.ImageURL = ref.String()
+		}
+	}
+	return p
+}
+
+// clean collapses whitespace and drops invalid UTF-8.
+func clean(s string) string {
+	return strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
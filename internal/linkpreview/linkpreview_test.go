@@ -0,0 +1,174 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+const testPage = `<!doctype html>
+<html><head>
+<title>Plain title</title>
+<meta name="description" content="Plain description">
+<meta property="og:title" content="  OpenGraph
+  title ">
+<meta name="twitter:description" content="Card description">
+<meta property="og:image" content="/images/card.png">
+</head><body><meta property="og:title" content="Not in head"></body></html>`
+
+func testServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(testPage))
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Only a title</title></head>" + strings.Repeat("x", 1<<20)))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+	})
+	mux.Handle("/moved", http.RedirectHandler("/page", http.StatusFound))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func localFetcher() *Fetcher {
+	return NewFetcher(Options{Timeout: 5 * time.Second, MaxBytes: 64 << 10, AllowPrivateNetworks: true})
+}
+
+func TestFindURLs(t *testing.T) {
+	got := FindURLs("see https://example.com/a?b=1. and (http://example.org/x) or ftp://nope, https://example.com/a?b=1 again")
+	want := []string{"https://example.com/a?b=1", "http://example.org/x"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	if got := FindURLs("no links, just https:// on its own"); len(got) != 0 {
+		t.Fatalf("Expected no URLs, got %v", got)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := testServer(t)
+	f := localFetcher()
+
+	preview, err := f.Fetch(context.Background(), server.URL+"/moved")
+	if err != nil {
+		t.Fatalf("Error fetching page: %v", err)
+	}
+	want := Preview{Title: "OpenGraph title", Description: "Card description", ImageURL: server.URL + "/images/card.png"}
+	if preview != want {
+		t.Fatalf("Expected %+v, got %+v", want, preview)
+	}
+
+	preview, err = f.Fetch(context.Background(), server.URL+"/plain")
+	if err != nil || preview.Title != "Only a title" {
+		t.Fatalf("Expected the <title> of a page read only in part, got %+v, %v", preview, err)
+	}
+
+	if _, err := f.Fetch(context.Background(), server.URL+"/image.png"); !errors.Is(err, ErrNotHTML) {
+		t.Fatalf("Expected ErrNotHTML, got %v", err)
+	}
+	if _, err := f.Fetch(context.Background(), "file:///etc/passwd"); !errors.Is(err, ErrUnsupportedURL) {
+		t.Fatalf("Expected ErrUnsupportedURL, got %v", err)
+	}
+}
+
+func TestFetchRefusesPrivateAddresses(t *testing.T) {
+	server := testServer(t)
+	f := NewFetcher(Options{Timeout: 5 * time.Second, MaxBytes: 64 << 10})
+
+	if _, err := f.Fetch(context.Background(), server.URL+"/page"); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Expected the loopback test server to be refused, got %v", err)
+	}
+
+	for addr, public := range map[string]bool{
+		"93.184.215.14":        true,
+		"2606:4700::1111":      true,
+		"::ffff:93.184.215.14": true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"::1":                  false,
+		"::ffff:192.168.0.1":   false,
+		"64:ff9b::a00:1":       false,
+		"fd00:ec2::254":        false,
+		"fe80::1":              false,
+	} {
+		ip, err := netip.ParseAddr(addr)
+		if err != nil {
+			t.Fatalf("Error parsing %s: %v", addr, err)
+		}
+		if got := publicAddress(ip); got != public {
+			t.Fatalf("Expected publicAddress(%s) to be %v", addr, public)
+		}
+	}
+}
+
+func TestWorker(t *testing.T) {
+	ctx := context.Background()
+	server := testServer(t)
+	m := store.NewMemory()
+	w := NewWorker(m, localFetcher(), 2)
+
+	m.EnqueueLinkPreview(ctx, server.URL+"/page")
+	m.EnqueueLinkPreview(ctx, server.URL+"/image.png")
+	m.EnqueueLinkPreview(ctx, server.URL+"/missing")
+
+	if n, err := w.Work(ctx); err != nil || n != 3 {
+		t.Fatalf("Expected 3 previews tried, got %d, %v", n, err)
+	}
+	ready, _ := m.GetLinkPreviewsByUrls(ctx, []string{server.URL + "/page"})
+	if len(ready) != 1 || ready[0].Title != "OpenGraph title" {
+		t.Fatalf("Expected the page's preview to be stored, got %+v", ready)
+	}
+
+	// the 404 is retried once more, but not straight away; the image can
+	// never have a preview
+	if n, err := w.Work(ctx); err != nil || n != 0 {
+		t.Fatalf("Expected the retry to wait, got %d tried, %v", n, err)
+	}
+	later := time.Now().Add(retryDelay + time.Second)
+	w.now = func() time.Time { return later }
+	if n, err := w.Work(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected only the missing page to be retried, got %d, %v", n, err)
+	}
+	w.now = func() time.Time { return later.Add(maxRetryDelay) }
+	if n, _ := w.Work(ctx); n != 0 {
+		t.Fatalf("Expected no retries after the last attempt, got %d", n)
+	}
+}
+
+func TestWorkerClaims(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemory()
+	m.EnqueueLinkPreview(ctx, "https://example.com/")
+
+	now := time.Now()
+	params := database.ClaimLinkPreviewsParams{ClaimedUntil: now.Add(time.Minute), Now: now, MaxClaimed: 10}
+	if claimed, _ := m.ClaimLinkPreviews(ctx, params); len(claimed) != 1 {
+		t.Fatalf("Expected the preview to be claimed, got %+v", claimed)
+	}
+	// another instance finds nothing until the claim runs out
+	if claimed, _ := m.ClaimLinkPreviews(ctx, params); len(claimed) != 0 {
+		t.Fatalf("Expected a claimed preview to be skipped, got %+v", claimed)
+	}
+	params.Now = now.Add(2 * time.Minute)
+	if claimed, _ := m.ClaimLinkPreviews(ctx, params); len(claimed) != 1 {
+		t.Fatalf("Expected an expired claim to be taken over, got %+v", claimed)
+	}
+}
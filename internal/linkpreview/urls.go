@@ -0,0 +1,30 @@
+package linkpreview
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// FindURLs returns the distinct http and https URLs in text, in the order
+// they appear. Brackets and quotes around a URL and punctuation ending a
+// sentence are not taken as part of it.
+func FindURLs(text string) []string {
+	var urls []string
+	for _, word := range strings.Fields(text) {
+		word = strings.TrimLeft(word, `("'[{<`)
+		lower := strings.ToLower(word)
+		if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+			continue
+		}
+		word = strings.TrimRight(word, `.,;:!?'")]}>`)
+		u, err := url.Parse(word)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		if s := u.String(); !slices.Contains(urls, s) {
+			urls = append(urls, s)
+		}
+	}
+	return urls
+}
@@ -0,0 +1,122 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jonvanw/chirpy/internal/database"
+)
+
+const (
+	batchSize = 20
+
+	// a failed fetch is retried after retryDelay, doubling with each
+	// attempt up to maxRetryDelay
+	retryDelay    = time.Minute
+	maxRetryDelay = time.Hour
+)
+
+type Store interface {
+	ClaimLinkPreviews(ctx context.Context, arg database.ClaimLinkPreviewsParams) ([]database.LinkPreview, error)
+	CompleteLinkPreview(ctx context.Context, arg database.CompleteLinkPreviewParams) error
+	FailLinkPreview(ctx context.Context, arg database.FailLinkPreviewParams) error
+}
+
+// Worker fetches the previews queued in the store. Posting a chirp wakes it;
+// it also polls, so previews queued before a restart or by another instance
+// are picked up. Each batch is claimed for long enough to fetch it, so
+// instances never fetch the same page at once.
+type Worker struct {
+	store       Store
+	fetcher     *Fetcher
+	maxAttempts int
+	wake        chan struct{}
+	now         func() time.Time
+}
+
+func NewWorker(store Store, fetcher *Fetcher, maxAttempts int) *Worker {
+	return &Worker{store: store, fetcher: fetcher, maxAttempts: maxAttempts, wake: make(chan struct{}, 1), now: time.Now}
+}
+
+// Wake asks the worker to look for pending previews now rather than at the
+// next poll. It never blocks.
+func (w *Worker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run works through pending previews whenever woken and every interval,
+// until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := w.Work(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "linkpreview: failed to process pending previews", "err", err)
+		}
+		if n == batchSize && err == nil {
+			continue // more may be waiting
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// Work fetches one batch of due previews and returns how many it tried.
+// Failures are retried, further apart each time, until they have been tried
+// maxAttempts times; pages that can never have a preview fail straight away.
+// If the worker stops mid-batch, the rest become due again once the claim
+// runs out.
+func (w *Worker) Work(ctx context.Context) (int, error) {
+	now := w.now().UTC()
+	pending, err := w.store.ClaimLinkPreviews(ctx, database.ClaimLinkPreviewsParams{
+		ClaimedUntil: now.Add(batchSize*w.fetcher.client.Timeout + time.Minute),
+		Now:          now,
+		MaxClaimed:   batchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+	for i, p := range pending {
+		if err := w.fetch(ctx, p); err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
+
+func (w *Worker) fetch(ctx context.Context, p database.LinkPreview) error {
+	url := p.Url
+	preview, err := w.fetcher.Fetch(ctx, url)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		slog.InfoContext(ctx, "linkpreview: fetch failed", "url", url, "err", err)
+		maxAttempts := w.maxAttempts
+		if errors.Is(err, ErrUnsupportedURL) || errors.Is(err, ErrBlockedAddress) || errors.Is(err, ErrNotHTML) || errors.Is(err, ErrNoPreview) {
+			maxAttempts = 0
+		}
+		delay := min(retryDelay<<p.Attempts, maxRetryDelay)
+		return w.store.FailLinkPreview(ctx, database.FailLinkPreviewParams{
+			MaxAttempts:   int32(maxAttempts),
+			NextAttemptAt: w.now().UTC().Add(delay),
+			Url:           url,
+		})
+	}
+	return w.store.CompleteLinkPreview(ctx, database.CompleteLinkPreviewParams{
+		Url:         url,
+		Title:       preview.Title,
+		Description: preview.Description,
+		ImageUrl:    preview.ImageURL,
+	})
+}
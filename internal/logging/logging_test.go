@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedactsSensitiveKeys(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, Options{Level: slog.LevelInfo, Format: "json", Redact: true})
+
+	logger.Info("login failed", "email", "someone@example.com", "user_id", "abc")
+
+	out := buf.String()
+	if strings.Contains(out, "someone@example.com") {
+		t.Fatalf("Expected email to be redacted, got: %s", out)
+	}
+	if !strings.Contains(out, `"user_id":"abc"`) {
+		t.Fatalf("Expected user_id to be logged, got: %s", out)
+	}
+}
+
+func TestAddsRequestIDFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, Options{Format: "json"})
+
+	logger.InfoContext(WithRequestID(context.Background(), "req-1"), "hello")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Error decoding log record: %v", err)
+	}
+	if record["request_id"] != "req-1" {
+		t.Fatalf("Expected request_id req-1, got %v", record["request_id"])
+	}
+}
+
+func TestAccessLogPropagatesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, Options{Format: "json"})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := AccessLog(logger, func(*http.Request) string { return "user-1" }, mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/things/1", nil)
+	req.Header.Set(RequestIDHeader, "incoming-id")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "incoming-id" {
+		t.Fatalf("Expected propagated request ID, got %q", got)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Error decoding log record: %v", err)
+	}
+	if record["route"] != "GET /api/things/{id}" || record["status"] != float64(http.StatusTeapot) || record["user_id"] != "user-1" {
+		t.Fatalf("Unexpected access log record: %v", record)
+	}
+}
+
+func TestAccessLogGeneratesRequestID(t *testing.T) {
+	logger := New(&bytes.Buffer{}, Options{})
+	handler := AccessLog(logger, func(*http.Request) string { return "" }, http.NotFoundHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "bad id with spaces")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); len(got) != 32 {
+		t.Fatalf("Expected a generated request ID, got %q", got)
+	}
+}
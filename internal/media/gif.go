@@ -0,0 +1,82 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var errBadGIF = errors.New("media: malformed gif")
+
+// checkGIFFrames walks the blocks of a GIF without decompressing anything and
+// refuses it if it has more than limits.MaxFrames frames or more than
+// limits.MaxTotalPixels pixels across them. gif.DecodeAll keeps every frame
+// in memory, so a small file of many frames could otherwise take gigabytes.
+func checkGIFFrames(data []byte, limits Limits) error {
+	// header, then the logical screen descriptor
+	if len(data) < 13 {
+		return errBadGIF
+	}
+	pos := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&0x07 + 1)
+	}
+
+	frames, pixels := 0, 0
+	for {
+		if pos >= len(data) {
+			return errBadGIF
+		}
+		switch data[pos] {
+		case 0x3B: // trailer
+			return nil
+		case 0x21: // extension: label, then sub-blocks
+			var err error
+			if pos, err = skipSubBlocks(data, pos+2); err != nil {
+				return err
+			}
+		case 0x2C: // image descriptor
+			if pos+10 > len(data) {
+				return errBadGIF
+			}
+			w := int(binary.LittleEndian.Uint16(data[pos+5:]))
+			h := int(binary.LittleEndian.Uint16(data[pos+7:]))
+			frames++
+			pixels += w * h
+			if frames > limits.MaxFrames {
+				return fmt.Errorf("%w: more than %d", ErrTooManyFrames, limits.MaxFrames)
+			}
+			if pixels > limits.MaxTotalPixels {
+				return fmt.Errorf("%w: frames add up to more than %d pixels", ErrTooManyPixels, limits.MaxTotalPixels)
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			// LZW minimum code size, then the image data
+			var err error
+			if pos, err = skipSubBlocks(data, pos+1); err != nil {
+				return err
+			}
+		default:
+			return errBadGIF
+		}
+	}
+}
+
+// skipSubBlocks returns the position after the run of sub-blocks starting at
+// pos, each a length byte and that many bytes, ended by a zero length.
+func skipSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, errBadGIF
+		}
+		n := int(data[pos])
+		pos++
+		if n == 0 {
+			return pos, nil
+		}
+		pos += n
+	}
+}
@@ -0,0 +1,149 @@
+// Package media turns uploaded images into files that are safe to serve:
+// the type is sniffed rather than trusted, oversized images are refused, and
+// everything is re-encoded so EXIF and other metadata (GPS positions, camera
+// serial numbers) never leave the server.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	ErrUnsupportedType = errors.New("media: unsupported image type")
+	ErrTooManyPixels   = errors.New("media: image dimensions too large")
+	ErrTooManyFrames   = errors.New("media: too many animation frames")
+)
+
+const jpegQuality = 90
+
+// Limits bound the work done for a single upload.
+type Limits struct {
+	// MaxPixels caps width*height, checked before the image is decoded. For
+	// a GIF it caps the logical screen, which every frame must fit in.
+	MaxPixels int
+	// MaxFrames and MaxTotalPixels cap the number of frames in a GIF and
+	// their summed width*height, also checked before decoding.
+	MaxFrames      int
+	MaxTotalPixels int
+	// ThumbnailSize is the longest side of a thumbnail. Smaller images are
+	// not scaled up.
+	ThumbnailSize int
+}
+
+// Image is an upload ready to store.
+type Image struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	Data        []byte
+
+	ThumbnailType string
+	ThumbnailExt  string
+	Thumbnail     []byte
+}
+
+// Process checks data is a JPEG, PNG or GIF within limits and re-encodes it
+// without metadata, applying any EXIF orientation first so the stripped image
+// still displays the right way up. Animated GIFs keep their frames.
+func Process(data []byte, limits Limits) (Image, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return Image{}, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("media: reading image header: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > limits.MaxPixels {
+		return Image{}, fmt.Errorf("%w: %dx%d", ErrTooManyPixels, cfg.Width, cfg.Height)
+	}
+
+	var out Image
+	var still image.Image
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("media: decoding jpeg: %w", err)
+		}
+		still = orient(img, jpegOrientation(data))
+		out = Image{ContentType: "image/jpeg", Ext: ".jpg"}
+		if err := jpeg.Encode(&buf, still, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Image{}, err
+		}
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("media: decoding png: %w", err)
+		}
+		still = img
+		out = Image{ContentType: "image/png", Ext: ".png"}
+		if err := png.Encode(&buf, img); err != nil {
+			return Image{}, err
+		}
+	case "image/gif":
+		if err := checkGIFFrames(data, limits); err != nil {
+			return Image{}, err
+		}
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("media: decoding gif: %w", err)
+		}
+		// comments and application extensions other than looping are
+		// dropped by EncodeAll
+		still = anim.Image[0]
+		out = Image{ContentType: "image/gif", Ext: ".gif"}
+		if err := gif.EncodeAll(&buf, anim); err != nil {
+			return Image{}, err
+		}
+	}
+	out.Data = buf.Bytes()
+	out.Width = still.Bounds().Dx()
+	out.Height = still.Bounds().Dy()
+
+	out.Thumbnail, err = thumbnail(still, limits.ThumbnailSize, contentType == "image/jpeg")
+	if err != nil {
+		return Image{}, err
+	}
+	out.ThumbnailType, out.ThumbnailExt = "image/png", ".png"
+	if contentType == "image/jpeg" {
+		out.ThumbnailType, out.ThumbnailExt = "image/jpeg", ".jpg"
+	}
+	return out, nil
+}
+
+// thumbnail scales img to fit in a size×size box, as a JPEG for photos and a
+// PNG otherwise so transparency survives.
+func thumbnail(img image.Image, size int, asJPEG bool) ([]byte, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if longest := max(w, h); longest > size {
+		w = max(1, w*size/longest)
+		h = max(1, h*size/longest)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	var err error
+	if asJPEG {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	return buf.Bytes(), err
+}
@@ -0,0 +1,193 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// withExif inserts an APP1 segment carrying only an orientation tag straight
+// after the JPEG's SOI marker.
+func withExif(t *testing.T, data []byte, orientation uint16) []byte {
+	t.Helper()
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	for _, v := range []any{
+		// magic number, offset of IFD0 and its entry count
+		uint16(42), uint32(8), uint16(1),
+		// orientation: SHORT, count 1, value padded to 4 bytes
+		uint16(exifOrientationTag), uint16(3), uint32(1), orientation, uint16(0),
+		// no next IFD
+		uint32(0),
+	} {
+		binary.Write(&tiff, binary.BigEndian, v)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestProcessJPEGAppliesOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(60, 20), nil); err != nil {
+		t.Fatalf("Error encoding test image: %v", err)
+	}
+	data := withExif(t, buf.Bytes(), 6)
+	if jpegOrientation(data) != 6 {
+		t.Fatalf("Expected the test image to carry orientation 6")
+	}
+
+	img, err := Process(data, Limits{MaxPixels: 10_000, ThumbnailSize: 10})
+	if err != nil {
+		t.Fatalf("Error processing image: %v", err)
+	}
+	if img.ContentType != "image/jpeg" || img.Width != 20 || img.Height != 60 {
+		t.Fatalf("Expected a 20x60 JPEG after rotating, got %s %dx%d", img.ContentType, img.Width, img.Height)
+	}
+	if bytes.Contains(img.Data, []byte("Exif")) {
+		t.Fatalf("Expected EXIF data to be stripped")
+	}
+
+	thumb, err := jpeg.Decode(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatalf("Error decoding thumbnail: %v", err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 3 || b.Dy() != 10 {
+		t.Fatalf("Expected a 3x10 thumbnail, got %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestProcessPNGKeepsSmallThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(8, 4))
+
+	img, err := Process(buf.Bytes(), Limits{MaxPixels: 10_000, ThumbnailSize: 400})
+	if err != nil {
+		t.Fatalf("Error processing image: %v", err)
+	}
+	if img.Ext != ".png" || img.ThumbnailType != "image/png" {
+		t.Fatalf("Expected a PNG and PNG thumbnail, got %s and %s", img.Ext, img.ThumbnailType)
+	}
+	thumb, _ := png.Decode(bytes.NewReader(img.Thumbnail))
+	if b := thumb.Bounds(); b.Dx() != 8 || b.Dy() != 4 {
+		t.Fatalf("Expected small images not to be scaled up, got %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(200, 100))
+
+	if _, err := Process(buf.Bytes(), Limits{MaxPixels: 10_000, ThumbnailSize: 10}); !errors.Is(err, ErrTooManyPixels) {
+		t.Fatalf("Expected ErrTooManyPixels, got %v", err)
+	}
+	if _, err := Process([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"), Limits{MaxPixels: 10_000}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("Expected ErrUnsupportedType, got %v", err)
+	}
+}
+
+func testGIF(t *testing.T, frames, size int) []byte {
+	t.Helper()
+	// a global palette keeps frames down to a few bytes each
+	bw := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{Config: image.Config{ColorModel: bw, Width: size, Height: size}}
+	for i := range frames {
+		frame := image.NewPaletted(image.Rect(0, 0, size, size), bw)
+		frame.SetColorIndex(0, 0, uint8(i%2))
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 1)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("Error encoding gif: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessGIFLimitsFrames(t *testing.T) {
+	limits := Limits{MaxPixels: 10_000, MaxFrames: 20, MaxTotalPixels: 50_000, ThumbnailSize: 10}
+
+	img, err := Process(testGIF(t, 3, 50), limits)
+	if err != nil {
+		t.Fatalf("Error processing gif: %v", err)
+	}
+	anim, err := gif.DecodeAll(bytes.NewReader(img.Data))
+	if err != nil || len(anim.Image) != 3 {
+		t.Fatalf("Expected the 3 frames kept, got %v", err)
+	}
+
+	// small on disk, but thousands of frames once decoded
+	many := testGIF(t, 5000, 1)
+	if len(many) > 200_000 {
+		t.Fatalf("Expected a small file, got %d bytes", len(many))
+	}
+	if _, err := Process(many, limits); !errors.Is(err, ErrTooManyFrames) {
+		t.Fatalf("Expected ErrTooManyFrames, got %v", err)
+	}
+	// every frame fits the screen but together they are too many pixels
+	if _, err := Process(testGIF(t, 6, 100), limits); !errors.Is(err, ErrTooManyPixels) {
+		t.Fatalf("Expected ErrTooManyPixels, got %v", err)
+	}
+	if _, err := Process(testGIF(t, 2, 10)[:40], limits); err == nil {
+		t.Fatalf("Expected a truncated gif to be refused")
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	storage, err := NewLocalStorage(t.TempDir(), "/media")
+	if err != nil {
+		t.Fatalf("Error creating storage: %v", err)
+	}
+	if err := storage.Put(ctx, "../escape.png", "image/png", []byte("x")); err == nil {
+		t.Fatalf("Expected a key outside the directory to be rejected")
+	}
+	if err := storage.Put(ctx, "a.png", "image/png", []byte("data")); err != nil {
+		t.Fatalf("Error storing file: %v", err)
+	}
+	if url := storage.URL("a.png"); url != "/media/a.png" {
+		t.Fatalf("Expected /media/a.png, got %s", url)
+	}
+
+	rec := httptest.NewRecorder()
+	storage.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.png", nil))
+	if body, _ := io.ReadAll(rec.Body); rec.Code != http.StatusOK || string(body) != "data" {
+		t.Fatalf("Expected the stored file to be served, got %d %q", rec.Code, body)
+	}
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("Expected nosniff to be set")
+	}
+
+	if err := storage.Delete(ctx, "a.png"); err != nil {
+		t.Fatalf("Error deleting file: %v", err)
+	}
+	if err := storage.Delete(ctx, "a.png"); err != nil {
+		t.Fatalf("Expected deleting a missing file to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jonvanw/chirpy/internal/static"
+)
+
+// Storage keeps uploaded files under opaque keys. LocalStorage writes them to
+// disk; anything with the same put/delete/URL shape, such as an
+// S3-compatible bucket, can stand in for it.
+type Storage interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL is where clients fetch key from.
+	URL(key string) string
+}
+
+// LocalStorage keeps files in a directory and serves them itself.
+type LocalStorage struct {
+	dir     string
+	baseURL string
+}
+
+var _ Storage = (*LocalStorage)(nil)
+
+// NewLocalStorage stores files in dir, creating it if needed. baseURL is the
+// path or URL the directory is served from, e.g. "/media/".
+func NewLocalStorage(dir, baseURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("media: creating storage directory: %w", err)
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &LocalStorage{dir: dir, baseURL: baseURL}, nil
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	if key == "" || !filepath.IsLocal(key) || strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("media: invalid storage key %q", key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+// Put writes through a temporary file so a crash never leaves half a file
+// under key.
+func (s *LocalStorage) Put(ctx context.Context, key, contentType string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return s.baseURL + key
+}
+
+// Handler serves the stored files. Keys are never reused, so they can be
+// cached for a long time.
+func (s *LocalStorage) Handler() http.Handler {
+	files := static.New(os.DirFS(s.dir), static.Options{MaxAge: 365 * 24 * time.Hour})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareLabelsByRoutePattern(t *testing.T) {
+	m := New()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpId}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler := m.Middleware(mux)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/chirps/abc", nil))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	want := `chirpy_http_requests_total{code="404",route="GET /api/chirps/{chirpId}"} 1`
+	if !strings.Contains(body, want) {
+		t.Fatalf("Expected metrics output to contain %q", want)
+	}
+}
+
+func TestValueReadsCounterFunc(t *testing.T) {
+	m := New()
+	m.RegisterCounterFunc("test_hits_total", "test", func() float64 { return 42 })
+
+	v, err := m.Value("test_hits_total")
+	if err != nil {
+		t.Fatalf("Error reading value: %v", err)
+	}
+	if v != 42 {
+		t.Fatalf("Expected 42, got %v", v)
+	}
+}
+
+func TestStatusRecorderUnwraps(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := &StatusRecorder{ResponseWriter: w}
+	if err := http.NewResponseController(rec).Flush(); err != nil {
+		t.Fatalf("Expected Flush to reach the underlying writer, got %v", err)
+	}
+	if !w.Flushed || rec.Status != 0 {
+		t.Fatalf("Expected a flush without a recorded status, got flushed=%v status=%d", w.Flushed, rec.Status)
+	}
+}
@@ -0,0 +1,98 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// ErrSchemaOutdated is returned by Check when the database is behind the
+// migrations compiled into the binary.
+var ErrSchemaOutdated = errors.New("database schema is out of date")
+
+type Result = goose.MigrationResult
+type Status = goose.MigrationStatus
+
+// Migrator applies the goose migrations in fsys. Every operation that changes
+// the schema holds a Postgres advisory lock, so several instances migrating on
+// start take turns instead of racing.
+type Migrator struct {
+	provider *goose.Provider
+	latest   int64
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, fsys,
+		goose.WithSessionLocker(locker),
+		goose.WithDisableGlobalRegistry(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Migrator{provider: provider}
+	for _, source := range provider.ListSources() {
+		m.latest = max(m.latest, source.Version)
+	}
+	return m, nil
+}
+
+// Latest is the version of the newest migration, the schema version this
+// binary was built for.
+func (m *Migrator) Latest() int64 {
+	return m.latest
+}
+
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	return m.provider.GetDBVersion(ctx)
+}
+
+func (m *Migrator) Up(ctx context.Context) ([]*Result, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) (*Result, error) {
+	return m.provider.Down(ctx)
+}
+
+// Redo rolls back the most recent migration and applies it again, which is
+// handy while writing one.
+func (m *Migrator) Redo(ctx context.Context) ([]*Result, error) {
+	down, err := m.provider.Down(ctx)
+	if err != nil {
+		return nil, err
+	}
+	up, err := m.provider.UpByOne(ctx)
+	if err != nil {
+		return []*Result{down}, err
+	}
+	return []*Result{down, up}, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	return m.provider.Status(ctx)
+}
+
+// Check fails with ErrSchemaOutdated when migrations are missing from the
+// database. A newer schema is accepted, since during a rolling deploy the old
+// binary keeps serving while the new one has already migrated.
+func (m *Migrator) Check(ctx context.Context) (int64, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if version < m.latest {
+		return version, fmt.Errorf("%w: database is at version %d, this binary needs %d", ErrSchemaOutdated, version, m.latest)
+	}
+	return version, nil
+}
@@ -0,0 +1,32 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/lib/pq"
+)
+
+func TestLatestIsNewestMigration(t *testing.T) {
+	// sql.Open doesn't connect, which is all New needs
+	db, err := sql.Open("postgres", "postgres://localhost/chirpy?sslmode=disable")
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+
+	migration := []byte("-- +goose Up\nSELECT 1;\n\n-- +goose Down\nSELECT 1;\n")
+	m, err := New(db, fstest.MapFS{
+		"001_users.sql":  {Data: migration},
+		"002_chirps.sql": {Data: migration},
+		"010_later.sql":  {Data: migration},
+	})
+	if err != nil {
+		t.Fatalf("Error creating migrator: %v", err)
+	}
+
+	if m.Latest() != 10 {
+		t.Fatalf("Expected latest version 10, got %d", m.Latest())
+	}
+}
@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-z0-9_]{1,15}$`)
+	// an @ starting a word, so addresses like a@example.com aren't mentions
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w{1,15})\b`)
+)
+
+// ValidUsername reports whether name can be used as a handle. Handles are
+// lowercase; callers lowercase what users type first.
+func ValidUsername(name string) bool {
+	return usernamePattern.MatchString(name)
+}
+
+// FindMentions returns the distinct handles @mentioned in text, lowercased,
+// in the order they appear.
+func FindMentions(text string) []string {
+	var names []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		name := strings.ToLower(match[1])
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
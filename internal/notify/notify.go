@@ -0,0 +1,240 @@
+// Package notify tells users when others interact with them or their
+// chirps. Write paths queue an event with EnqueueNotificationEvent in their
+// own transaction; a Worker fans events out into notifications in the
+// background, so the request that caused one never waits on it.
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+// Notification types. Each is also a preference users can turn off.
+const (
+	Rechirp = "rechirp" // someone rechirped your chirp
+	Quote   = "quote"   // someone quoted your chirp
+	Reply   = "reply"   // someone replied to your chirp
+	Like    = "like"    // someone liked your chirp
+	Follow  = "follow"  // someone followed you
+	Mention = "mention" // someone @mentioned you in a chirp
+)
+
+// Types lists every notification type.
+var Types = []string{Rechirp, Quote, Reply, Like, Follow, Mention}
+
+const batchSize = 50
+
+// Worker turns queued events into notifications. Writes wake it; it also
+// polls, so events queued before a restart or by another instance are picked
+// up.
+type Worker struct {
+	store store.Store
+	wake  chan struct{}
+}
+
+func NewWorker(store store.Store) *Worker {
+	return &Worker{store: store, wake: make(chan struct{}, 1)}
+}
+
+// Wake asks the worker to look for queued events now rather than at the next
+// poll. It never blocks.
+func (w *Worker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run fans out queued events whenever woken and every interval, until ctx is
+// cancelled.
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := w.Work(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "notify: failed to fan out notifications", "err", err)
+		}
+		if n == batchSize && err == nil {
+			continue // more may be waiting
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// Work fans out one batch of events and returns how many it took. The batch
+// is taken and its notifications created in one transaction, so an event is
+// never delivered twice or lost, even with several instances running.
+func (w *Worker) Work(ctx context.Context) (int, error) {
+	var n int
+	err := w.store.InTx(ctx, func(tx store.Store) error {
+		events, err := tx.TakeNotificationEvents(ctx, batchSize)
+		if err != nil {
+			return err
+		}
+		n = len(events)
+		for _, e := range events {
+			if err := fanOut(ctx, tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// fanOut notifies everyone e concerns, except the actor, anyone who has
+// blocked or muted the actor, and anyone who has turned the type off.
+func fanOut(ctx context.Context, tx store.Store, e database.NotificationEvent) error {
+	recipients, err := recipients(ctx, tx, e)
+	if err != nil {
+		return err
+	}
+	for _, userId := range recipients {
+		if userId == e.ActorID {
+			continue
+		}
+		hidden, err := tx.GetHiddenUserIds(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(hidden, e.ActorID) {
+			continue
+		}
+		on, err := enabled(ctx, tx, userId, e.Type)
+		if err != nil {
+			return err
+		}
+		if !on {
+			continue
+		}
+		err = tx.CreateNotification(ctx, database.CreateNotificationParams{
+			UserID:  userId,
+			Type:    e.Type,
+			ActorID: e.ActorID,
+			ChirpID: e.ChirpID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recipients is who e concerns. Events overtaken by a deletion or by an undone
+// rechirp, like or follow concern no one.
+func recipients(ctx context.Context, tx store.Store, e database.NotificationEvent) ([]uuid.UUID, error) {
+	if e.Type == Follow {
+		if !e.UserID.Valid {
+			return nil, nil
+		}
+		following, err := tx.IsUserFollowed(ctx, database.IsUserFollowedParams{FollowerID: e.ActorID, FollowedID: e.UserID.UUID})
+		if err != nil || !following {
+			return nil, err
+		}
+		return []uuid.UUID{e.UserID.UUID}, nil
+	}
+
+	// every other type is about a chirp
+	if !e.ChirpID.Valid {
+		return nil, nil
+	}
+	chirp, err := tx.GetChirpById(ctx, e.ChirpID.UUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Type {
+	case Rechirp:
+		_, err := tx.GetRechirp(ctx, database.GetRechirpParams{UserID: e.ActorID, ChirpID: chirp.ID})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []uuid.UUID{chirp.UserID}, nil
+	case Like:
+		_, err := tx.GetLike(ctx, database.GetLikeParams{UserID: e.ActorID, ChirpID: chirp.ID})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []uuid.UUID{chirp.UserID}, nil
+	case Quote:
+		return authorOf(ctx, tx, chirp.QuotedChirpID)
+	case Reply:
+		return authorOf(ctx, tx, chirp.ReplyToChirpID)
+	case Mention:
+		names := FindMentions(chirp.Body)
+		if len(names) == 0 {
+			return nil, nil
+		}
+		return tx.GetUserIdsByUsernames(ctx, names)
+	}
+	// dropping it beats retrying a batch that can never succeed
+	slog.WarnContext(ctx, "notify: dropping event of unknown type", "event_id", e.ID, "type", e.Type)
+	return nil, nil
+}
+
+// authorOf is the author of the chirp a quote or reply points at, if it is
+// still there.
+func authorOf(ctx context.Context, tx store.Store, chirpId uuid.NullUUID) ([]uuid.UUID, error) {
+	if !chirpId.Valid {
+		return nil, nil
+	}
+	chirp, err := tx.GetChirpById(ctx, chirpId.UUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []uuid.UUID{chirp.UserID}, nil
+}
+
+// Preferences returns whether userId wants each type of notification.
+func Preferences(ctx context.Context, q store.Notifications, userId uuid.UUID) (map[string]bool, error) {
+	saved, err := q.GetNotificationPreferences(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	prefs := make(map[string]bool, len(Types))
+	for _, t := range Types {
+		prefs[t] = true
+	}
+	for _, p := range saved {
+		prefs[p.Type] = p.Enabled
+	}
+	return prefs, nil
+}
+
+// enabled reports whether userId wants notifications of type typ.
+func enabled(ctx context.Context, q store.Notifications, userId uuid.UUID, typ string) (bool, error) {
+	prefs, err := Preferences(ctx, q, userId)
+	if err != nil {
+		return false, err
+	}
+	return prefs[typ], nil
+}
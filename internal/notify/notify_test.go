@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+func TestWorker(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemory()
+	w := NewWorker(m)
+
+	author, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "author@example.com"})
+	fan, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "fan@example.com"})
+	troll, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "troll@example.com"})
+	chirp, _ := m.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: author.ID})
+	gone, _ := m.CreateChirp(ctx, database.CreateChirpParams{Body: "bye", UserID: author.ID})
+	m.BlockUser(ctx, database.BlockUserParams{BlockerID: author.ID, BlockedID: troll.ID})
+
+	for _, actor := range []uuid.UUID{fan.ID, troll.ID} {
+		m.CreateRechirp(ctx, database.CreateRechirpParams{UserID: actor, ChirpID: chirp.ID})
+		m.EnqueueNotificationEvent(ctx, database.EnqueueNotificationEventParams{Type: Rechirp, ActorID: actor, ChirpID: valid(chirp.ID)})
+	}
+	m.CreateRechirp(ctx, database.CreateRechirpParams{UserID: fan.ID, ChirpID: gone.ID})
+	m.EnqueueNotificationEvent(ctx, database.EnqueueNotificationEventParams{Type: Rechirp, ActorID: fan.ID, ChirpID: valid(gone.ID)})
+	m.DeleteChirpByAuthor(ctx, database.DeleteChirpByAuthorParams{ID: gone.ID, UserID: author.ID})
+
+	if n, err := w.Work(ctx); err != nil || n != 3 {
+		t.Fatalf("Expected 3 events taken, got %d, %v", n, err)
+	}
+	got, _ := m.GetNotificationsByUserId(ctx, database.GetNotificationsByUserIdParams{UserID: author.ID, Limit: 10})
+	if len(got) != 1 || got[0].ActorID != fan.ID || got[0].ChirpID != valid(chirp.ID) {
+		t.Fatalf("Expected only the fan's rechirp of the live chirp, got %+v", got)
+	}
+	if n, _ := w.Work(ctx); n != 0 {
+		t.Fatalf("Expected the queue to be empty, got %d", n)
+	}
+}
+
+func valid(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: true}
+}
+
+func TestWorkerTypes(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemory()
+	w := NewWorker(m)
+
+	author, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "author@example.com"})
+	fan, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "fan@example.com"})
+	friend, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "friend@example.com"})
+	m.SetUsername(ctx, database.SetUsernameParams{ID: author.ID, Username: sql.NullString{String: "author", Valid: true}})
+	m.SetUsername(ctx, database.SetUsernameParams{ID: friend.ID, Username: sql.NullString{String: "friend", Valid: true}})
+	chirp, _ := m.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: author.ID})
+
+	reply, _ := m.CreateChirp(ctx, database.CreateChirpParams{
+		Body:           "hi @Author and @friend, mail fan@friend.com",
+		UserID:         fan.ID,
+		ReplyToChirpID: valid(chirp.ID),
+	})
+	m.EnqueueNotificationEvent(ctx, database.EnqueueNotificationEventParams{Type: Reply, ActorID: fan.ID, ChirpID: valid(reply.ID)})
+	m.EnqueueNotificationEvent(ctx, database.EnqueueNotificationEventParams{Type: Mention, ActorID: fan.ID, ChirpID: valid(reply.ID)})
+	m.CreateLike(ctx, database.CreateLikeParams{UserID: fan.ID, ChirpID: chirp.ID})
+	m.EnqueueNotificationEvent(ctx, database.EnqueueNotificationEventParams{Type: Like, ActorID: fan.ID, ChirpID: valid(chirp.ID)})
+	m.FollowUser(ctx, database.FollowUserParams{FollowerID: fan.ID, FollowedID: author.ID})
+	m.EnqueueNotificationEvent(ctx, database.EnqueueNotificationEventParams{Type: Follow, ActorID: fan.ID, UserID: valid(author.ID)})
+	// undone before fan-out
+	m.FollowUser(ctx, database.FollowUserParams{FollowerID: fan.ID, FollowedID: friend.ID})
+	m.EnqueueNotificationEvent(ctx, database.EnqueueNotificationEventParams{Type: Follow, ActorID: fan.ID, UserID: valid(friend.ID)})
+	m.UnfollowUser(ctx, database.UnfollowUserParams{FollowerID: fan.ID, FollowedID: friend.ID})
+
+	if n, err := w.Work(ctx); err != nil || n != 5 {
+		t.Fatalf("Expected 5 events taken, got %d, %v", n, err)
+	}
+	types := func(userId uuid.UUID) []string {
+		got, _ := m.GetNotificationsByUserId(ctx, database.GetNotificationsByUserIdParams{UserID: userId, Limit: 10})
+		var out []string
+		for _, n := range got {
+			out = append(out, n.Type)
+		}
+		slices.Sort(out)
+		return out
+	}
+	if got := types(author.ID); !slices.Equal(got, []string{Follow, Like, Mention, Reply}) {
+		t.Fatalf("Expected a follow, like, mention and reply for the author, got %v", got)
+	}
+	if got := types(friend.ID); !slices.Equal(got, []string{Mention}) {
+		t.Fatalf("Expected only a mention for the friend, got %v", got)
+	}
+}
+
+func TestFindMentions(t *testing.T) {
+	got := FindMentions("@Alice hi, (@bob) and @alice again; not a@example.com or @@carol or @waytoolonghandle16")
+	if want := []string{"alice", "bob"}; !slices.Equal(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for name, want := range map[string]bool{"alice_1": true, "Alice": false, "": false, "a-b": false, "sixteen_letters_": false} {
+		if ValidUsername(name) != want {
+			t.Fatalf("Expected ValidUsername(%q) to be %v", name, want)
+		}
+	}
+}
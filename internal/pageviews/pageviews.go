@@ -0,0 +1,125 @@
+package pageviews
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jonvanw/chirpy/internal/database"
+)
+
+const maxPathLength = 256
+
+type Store interface {
+	IncrementPageViews(ctx context.Context, arg database.IncrementPageViewsParams) error
+}
+
+type key struct {
+	path string
+	day  time.Time
+}
+
+// Counter buffers page views in memory and periodically writes them to the
+// database as one upsert per (path, day), so a busy page costs one write
+// per flush instead of one per request.
+type Counter struct {
+	store Store
+
+	mu      sync.Mutex
+	pending map[key]int64
+
+	total atomic.Int64
+}
+
+func NewCounter(store Store) *Counter {
+	return &Counter{store: store, pending: make(map[key]int64)}
+}
+
+func (c *Counter) Record(path string, at time.Time) {
+	if len(path) > maxPathLength {
+		path = path[:maxPathLength]
+	}
+	k := key{path: path, day: Day(at)}
+
+	c.mu.Lock()
+	c.pending[k]++
+	c.mu.Unlock()
+	c.total.Add(1)
+}
+
+// Total is the number of views recorded by this process since it started.
+func (c *Counter) Total() int64 {
+	return c.total.Load()
+}
+
+// Pending is the number of recorded views not yet written to the store.
+func (c *Counter) Pending() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for _, hits := range c.pending {
+		n += hits
+	}
+	return n
+}
+
+// Discard drops buffered views without writing them.
+func (c *Counter) Discard() {
+	c.mu.Lock()
+	c.pending = make(map[key]int64)
+	c.mu.Unlock()
+}
+
+// Flush writes all buffered views. Views that fail to write are put back so
+// the next flush retries them.
+func (c *Counter) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = make(map[key]int64)
+	c.mu.Unlock()
+
+	var errs []error
+	for k, hits := range batch {
+		err := c.store.IncrementPageViews(ctx, database.IncrementPageViewsParams{
+			Path: k.path,
+			Day:  k.day,
+			Hits: hits,
+		})
+		if err != nil {
+			errs = append(errs, err)
+			c.mu.Lock()
+			c.pending[k] += hits
+			c.mu.Unlock()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run flushes every interval until ctx is cancelled, then flushes one last
+// time using finalCtx.
+func (c *Counter) Run(ctx context.Context, finalCtx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil {
+				slog.Error("pageviews: failed to flush page views", "err", err)
+			}
+		case <-ctx.Done():
+			if err := c.Flush(finalCtx); err != nil {
+				slog.Error("pageviews: failed final flush of page views", "err", err, "pending", c.Pending())
+			}
+			return
+		}
+	}
+}
+
+// Day truncates t to its UTC calendar day, the granularity views are stored at.
+func Day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
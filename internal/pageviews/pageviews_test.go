@@ -0,0 +1,71 @@
+package pageviews
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonvanw/chirpy/internal/database"
+)
+
+type fakeStore struct {
+	fail  bool
+	saved map[key]int64
+}
+
+func (f *fakeStore) IncrementPageViews(ctx context.Context, arg database.IncrementPageViewsParams) error {
+	if f.fail {
+		return errors.New("database unavailable")
+	}
+	f.saved[key{path: arg.Path, day: arg.Day}] += arg.Hits
+	return nil
+}
+
+func TestFlushBatchesByPathAndDay(t *testing.T) {
+	store := &fakeStore{saved: make(map[key]int64)}
+	counter := NewCounter(store)
+	monday := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	counter.Record("/app/", monday)
+	counter.Record("/app/", monday.Add(time.Hour))
+	counter.Record("/app/", monday.Add(24*time.Hour))
+	counter.Record("/app/logo.png", monday)
+
+	if err := counter.Flush(context.Background()); err != nil {
+		t.Fatalf("Error flushing: %v", err)
+	}
+
+	if got := store.saved[key{"/app/", Day(monday)}]; got != 2 {
+		t.Fatalf("Expected 2 views of /app/ on Monday, got %d", got)
+	}
+	if len(store.saved) != 3 {
+		t.Fatalf("Expected 3 (path, day) rows, got %d", len(store.saved))
+	}
+	if counter.Pending() != 0 || counter.Total() != 4 {
+		t.Fatalf("Expected 0 pending and 4 total, got %d pending and %d total", counter.Pending(), counter.Total())
+	}
+}
+
+func TestFlushKeepsViewsOnFailure(t *testing.T) {
+	store := &fakeStore{fail: true, saved: make(map[key]int64)}
+	counter := NewCounter(store)
+
+	counter.Record("/app/", time.Now())
+	counter.Record("/app/", time.Now())
+
+	if err := counter.Flush(context.Background()); err == nil {
+		t.Fatalf("Expected flush to fail")
+	}
+	if counter.Pending() != 2 {
+		t.Fatalf("Expected failed views to stay pending, got %d", counter.Pending())
+	}
+
+	store.fail = false
+	if err := counter.Flush(context.Background()); err != nil {
+		t.Fatalf("Error flushing: %v", err)
+	}
+	if counter.Pending() != 0 {
+		t.Fatalf("Expected retry to drain pending views, got %d", counter.Pending())
+	}
+}
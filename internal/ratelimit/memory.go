@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type memoryEntry struct {
+	bucket Bucket
+	policy Policy
+}
+
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryEntry)}
+}
+
+func (m *MemoryStore) Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+
+	entry, ok := m.buckets[key]
+	if !ok {
+		entry = &memoryEntry{bucket: NewBucket(policy, now)}
+		m.buckets[key] = entry
+	}
+	entry.policy = policy
+	return entry.bucket.Take(policy, now), nil
+}
+
+// sweep drops buckets that have refilled completely so idle clients don't
+// accumulate in memory.
+func (m *MemoryStore) sweep(now time.Time) {
+	for key, entry := range m.buckets {
+		if entry.bucket.Full(entry.policy, now) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
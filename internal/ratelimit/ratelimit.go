@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Policy describes a token bucket: Burst tokens at most, refilled at Rate
+// tokens per second.
+type Policy struct {
+	Rate  float64
+	Burst int
+}
+
+func PerMinute(n int, burst int) Policy {
+	return Policy{Rate: float64(n) / 60, Burst: burst}
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // zero when allowed
+	ResetAfter time.Duration // time until the bucket is full again
+}
+
+// Store holds bucket state. MemoryStore works for a single instance; a shared
+// implementation (e.g. backed by Redis) can be swapped in for multiple instances.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error)
+}
+
+// Bucket is the persisted state of a single token bucket. Stores only need to
+// load and save it atomically around a call to Take.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+func NewBucket(policy Policy, now time.Time) Bucket {
+	return Bucket{Tokens: float64(policy.Burst), Updated: now}
+}
+
+func (b *Bucket) Take(policy Policy, now time.Time) Result {
+	if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(policy.Burst), b.Tokens+elapsed*policy.Rate)
+	}
+	b.Updated = now
+
+	res := Result{Limit: policy.Burst}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - b.Tokens) / policy.Rate)
+	}
+	res.Remaining = int(math.Floor(b.Tokens))
+	res.ResetAfter = secondsToDuration((float64(policy.Burst) - b.Tokens) / policy.Rate)
+	return res
+}
+
+// Full reports whether the bucket would be back at capacity by now, meaning
+// its state can be dropped without changing behaviour.
+func (b *Bucket) Full(policy Policy, now time.Time) bool {
+	return b.Tokens+now.Sub(b.Updated).Seconds()*policy.Rate >= float64(policy.Burst)
+}
+
+func secondsToDuration(s float64) time.Duration {
+	if s <= 0 || math.IsInf(s, 0) || math.IsNaN(s) {
+		return 0
+	}
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBucketBurstAndRefill(t *testing.T) {
+	policy := Policy{Rate: 1, Burst: 3}
+	now := time.Now()
+	bucket := NewBucket(policy, now)
+
+	for i := 0; i < 3; i++ {
+		res := bucket.Take(policy, now)
+		if !res.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+		if res.Remaining != 2-i {
+			t.Fatalf("Expected %d remaining, got %d", 2-i, res.Remaining)
+		}
+	}
+
+	res := bucket.Take(policy, now)
+	if res.Allowed {
+		t.Fatalf("Expected request to be limited after burst")
+	}
+	if res.RetryAfter != time.Second {
+		t.Fatalf("Expected retry after 1s, got %v", res.RetryAfter)
+	}
+
+	res = bucket.Take(policy, now.Add(time.Second))
+	if !res.Allowed {
+		t.Fatalf("Expected request to be allowed after refill")
+	}
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	store := NewMemoryStore()
+	policy := Policy{Rate: 0.1, Burst: 1}
+	now := time.Now()
+	ctx := context.Background()
+
+	if res, _ := store.Take(ctx, "a", policy, now); !res.Allowed {
+		t.Fatalf("Expected first request for key a to be allowed")
+	}
+	if res, _ := store.Take(ctx, "a", policy, now); res.Allowed {
+		t.Fatalf("Expected second request for key a to be limited")
+	}
+	if res, _ := store.Take(ctx, "b", policy, now); !res.Allowed {
+		t.Fatalf("Expected first request for key b to be allowed")
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	policy := Policy{Rate: 1, Burst: 1}
+	now := time.Now()
+	ctx := context.Background()
+
+	store.Take(ctx, "idle", policy, now)
+	store.Take(ctx, "other", policy, now.Add(2*sweepInterval))
+
+	if _, ok := store.buckets["idle"]; ok {
+		t.Fatalf("Expected idle bucket to be swept")
+	}
+}
@@ -0,0 +1,211 @@
+package static
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const indexFile = "index.html"
+
+// Precompressed variants are looked up next to the original file, in order of
+// preference, e.g. app.js.br before app.js.gz.
+var encodings = []struct {
+	name string
+	ext  string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+type Options struct {
+	// MaxAge is how long browsers may cache anything other than HTML. HTML is
+	// always revalidated so new deploys are picked up straight away.
+	MaxAge time.Duration
+	// SPAFallback serves the root index.html for extensionless paths that
+	// don't exist, so client-side routes survive a reload.
+	SPAFallback bool
+}
+
+// Handler serves files from an fs.FS. Unlike http.FileServer it never lists
+// directories, refuses any path with a dotfile segment, and serves
+// precompressed variants when the client accepts them.
+type Handler struct {
+	fsys fs.FS
+	opts Options
+
+	mu    sync.Mutex
+	etags map[string]etag
+}
+
+type etag struct {
+	size    int64
+	modTime time.Time
+	value   string
+}
+
+func New(fsys fs.FS, opts Options) *Handler {
+	return &Handler{fsys: fsys, opts: opts, etags: make(map[string]etag)}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if hidden(name) {
+		http.NotFound(w, r)
+		return
+	}
+	if name == "" {
+		name = "."
+	}
+
+	info, err := fs.Stat(h.fsys, name)
+	switch {
+	case err == nil && info.IsDir():
+		if !strings.HasSuffix(r.URL.Path, "/") && r.URL.Path != "" {
+			// relative links in the index only resolve from inside the directory
+			http.Redirect(w, r, path.Base(r.URL.Path)+"/", http.StatusMovedPermanently)
+			return
+		}
+		name = path.Join(name, indexFile)
+	case errors.Is(err, fs.ErrNotExist) && h.opts.SPAFallback && path.Ext(name) == "":
+		name = indexFile
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.serveFile(w, r, name)
+}
+
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	served := name
+	w.Header().Add("Vary", "Accept-Encoding")
+	for _, enc := range encodings {
+		if !acceptsEncoding(r.Header.Get("Accept-Encoding"), enc.name) {
+			continue
+		}
+		variant, err := fs.Stat(h.fsys, name+enc.ext)
+		if err != nil || !variant.Mode().IsRegular() {
+			continue
+		}
+		served, info = name+enc.ext, variant
+		w.Header().Set("Content-Encoding", enc.name)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		break
+	}
+
+	f, err := h.fsys.Open(served)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+
+	tag, err := h.etag(served, info, content)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", h.cacheControl(name))
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// etag hashes the file contents once per (name, size, modtime), so an
+// on-disk web root picks up edits while embedded files are hashed only once.
+func (h *Handler) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	h.mu.Lock()
+	cached, ok := h.etags[name]
+	h.mu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.value, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	value := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+
+	h.mu.Lock()
+	h.etags[name] = etag{size: info.Size(), modTime: info.ModTime(), value: value}
+	h.mu.Unlock()
+	return value, nil
+}
+
+func (h *Handler) cacheControl(name string) string {
+	if path.Ext(name) == ".html" || h.opts.MaxAge <= 0 {
+		return "no-cache"
+	}
+	return fmt.Sprintf("public, max-age=%d", int(h.opts.MaxAge.Seconds()))
+}
+
+// hidden reports whether any segment of name starts with a dot, which covers
+// .env, .git/ and editor swap files alike.
+func hidden(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
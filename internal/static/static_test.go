@@ -0,0 +1,113 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":         {Data: []byte("<html>home</html>")},
+		"app.js":             {Data: []byte("console.log('hi')")},
+		"app.js.br":          {Data: []byte("brotli bytes")},
+		"app.js.gz":          {Data: []byte("gzip bytes")},
+		".env":               {Data: []byte("JWT_AUTH_SECRET=nope")},
+		"assets/.secret.png": {Data: []byte("hidden")},
+		"assets/logo.png":    {Data: []byte("png")},
+	}
+}
+
+func get(h http.Handler, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDotfilesAreNotServed(t *testing.T) {
+	h := New(testFS(), Options{SPAFallback: true})
+
+	for _, target := range []string{"/.env", "/assets/.secret.png", "/assets/../.env"} {
+		if rec := get(h, target, nil); rec.Code != http.StatusNotFound {
+			t.Fatalf("Expected 404 for %s, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestServesPrecompressedVariant(t *testing.T) {
+	h := New(testFS(), Options{MaxAge: time.Hour})
+
+	rec := get(h, "/app.js", map[string]string{"Accept-Encoding": "gzip, br"})
+	if rec.Header().Get("Content-Encoding") != "br" || rec.Body.String() != "brotli bytes" {
+		t.Fatalf("Expected brotli variant, got encoding %q body %q", rec.Header().Get("Content-Encoding"), rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/javascript; charset=utf-8" {
+		t.Fatalf("Expected JavaScript content type, got %q", ct)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "public, max-age=3600" {
+		t.Fatalf("Expected cacheable asset, got Cache-Control %q", cc)
+	}
+
+	rec = get(h, "/app.js", map[string]string{"Accept-Encoding": "br;q=0, gzip"})
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip variant when br is refused, got %q", rec.Header().Get("Content-Encoding"))
+	}
+
+	rec = get(h, "/app.js", nil)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "console.log('hi')" {
+		t.Fatalf("Expected identity content, got encoding %q", rec.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestETagRevalidation(t *testing.T) {
+	h := New(testFS(), Options{})
+
+	first := get(h, "/assets/logo.png", nil)
+	tag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || tag == "" {
+		t.Fatalf("Expected 200 with an ETag, got %d %q", first.Code, tag)
+	}
+
+	second := get(h, "/assets/logo.png", map[string]string{"If-None-Match": tag})
+	if second.Code != http.StatusNotModified {
+		t.Fatalf("Expected 304 for matching ETag, got %d", second.Code)
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	h := New(testFS(), Options{SPAFallback: true})
+
+	rec := get(h, "/users/42", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "<html>home</html>" {
+		t.Fatalf("Expected index.html for client route, got %d %q", rec.Code, rec.Body.String())
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Fatalf("Expected HTML to be revalidated, got Cache-Control %q", cc)
+	}
+
+	if rec := get(h, "/missing.js", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 for missing asset, got %d", rec.Code)
+	}
+
+	h = New(testFS(), Options{})
+	if rec := get(h, "/users/42", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 without SPA fallback, got %d", rec.Code)
+	}
+}
+
+func TestDirectoryServesIndexWithoutListing(t *testing.T) {
+	h := New(testFS(), Options{})
+
+	if rec := get(h, "/", nil); rec.Code != http.StatusOK || rec.Body.String() != "<html>home</html>" {
+		t.Fatalf("Expected index.html for root, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := get(h, "/assets/", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 for directory without index, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,1852 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/auth"
+	"github.com/jonvanw/chirpy/internal/database"
+)
+
+// Memory is a Store kept in process. It follows the Postgres queries closely
+// enough for handler tests: missing rows are sql.ErrNoRows, emails are unique,
+// deleting a user cascades to their chirps and tokens, and timestamps have
+// microsecond precision.
+type Memory struct {
+	txMu          sync.Mutex // held for the whole of InTx
+	mu            sync.RWMutex
+	users         map[uuid.UUID]database.User
+	chirps        []database.Chirp // in creation order
+	refreshTokens map[string]database.RefreshToken
+	pageViews     map[pageViewKey]int64
+	webhookEvents []database.WebhookEvent
+	cases         []database.ModerationCase // in creation order
+	reports       []database.Report
+	modActions    []database.ModerationAction
+	blocks        []database.UserBlock
+	mutes         []database.UserMute
+	media         []database.MediaAttachment
+	linkPreviews  []database.LinkPreview
+	scheduled     []database.ScheduledChirp
+	drafts        []database.Draft
+	rechirps      []database.Rechirp // in creation order
+	likes         []database.Like
+	follows       []database.UserFollow
+	notifyEvents  []database.NotificationEvent
+	notifications []database.Notification
+	notifyPrefs   []database.NotificationPreference
+
+	// Now is the clock; tests may replace it.
+	Now func() time.Time
+}
+
+type pageViewKey struct {
+	path string
+	day  time.Time
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{
+		users:         make(map[uuid.UUID]database.User),
+		refreshTokens: make(map[string]database.RefreshToken),
+		pageViews:     make(map[pageViewKey]int64),
+		Now:           time.Now,
+	}
+}
+
+// InTx runs transactions one at a time and, if fn fails, puts back the state
+// from before it started. Writes made outside InTx while fn runs are lost on
+// rollback, which is good enough for tests.
+func (m *Memory) InTx(ctx context.Context, fn func(Store) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	saved := m.snapshot()
+	if err := fn(memoryTx{m}); err != nil {
+		m.restore(saved)
+		return err
+	}
+	return nil
+}
+
+// memoryTx is the Store handed to InTx callbacks; nesting reuses the
+// transaction instead of deadlocking on txMu.
+type memoryTx struct {
+	*Memory
+}
+
+func (tx memoryTx) InTx(ctx context.Context, fn func(Store) error) error {
+	return fn(tx)
+}
+
+type memorySnapshot struct {
+	users         map[uuid.UUID]database.User
+	chirps        []database.Chirp
+	refreshTokens map[string]database.RefreshToken
+	pageViews     map[pageViewKey]int64
+	webhookEvents []database.WebhookEvent
+	cases         []database.ModerationCase
+	reports       []database.Report
+	modActions    []database.ModerationAction
+	blocks        []database.UserBlock
+	mutes         []database.UserMute
+	media         []database.MediaAttachment
+	linkPreviews  []database.LinkPreview
+	scheduled     []database.ScheduledChirp
+	drafts        []database.Draft
+	rechirps      []database.Rechirp
+	likes         []database.Like
+	follows       []database.UserFollow
+	notifyEvents  []database.NotificationEvent
+	notifications []database.Notification
+	notifyPrefs   []database.NotificationPreference
+}
+
+func (m *Memory) snapshot() memorySnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return memorySnapshot{
+		users:         maps.Clone(m.users),
+		chirps:        slices.Clone(m.chirps),
+		refreshTokens: maps.Clone(m.refreshTokens),
+		pageViews:     maps.Clone(m.pageViews),
+		webhookEvents: slices.Clone(m.webhookEvents),
+		cases:         slices.Clone(m.cases),
+		reports:       slices.Clone(m.reports),
+		modActions:    slices.Clone(m.modActions),
+		blocks:        slices.Clone(m.blocks),
+		mutes:         slices.Clone(m.mutes),
+		media:         slices.Clone(m.media),
+		linkPreviews:  slices.Clone(m.linkPreviews),
+		scheduled:     slices.Clone(m.scheduled),
+		drafts:        slices.Clone(m.drafts),
+		rechirps:      slices.Clone(m.rechirps),
+		likes:         slices.Clone(m.likes),
+		follows:       slices.Clone(m.follows),
+		notifyEvents:  slices.Clone(m.notifyEvents),
+		notifications: slices.Clone(m.notifications),
+		notifyPrefs:   slices.Clone(m.notifyPrefs),
+	}
+}
+
+func (m *Memory) restore(s memorySnapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.users = s.users
+	m.chirps = s.chirps
+	m.refreshTokens = s.refreshTokens
+	m.pageViews = s.pageViews
+	m.webhookEvents = s.webhookEvents
+	m.cases = s.cases
+	m.reports = s.reports
+	m.modActions = s.modActions
+	m.blocks = s.blocks
+	m.mutes = s.mutes
+	m.media = s.media
+	m.linkPreviews = s.linkPreviews
+	m.scheduled = s.scheduled
+	m.drafts = s.drafts
+	m.rechirps = s.rechirps
+	m.likes = s.likes
+	m.follows = s.follows
+	m.notifyEvents = s.notifyEvents
+	m.notifications = s.notifications
+	m.notifyPrefs = s.notifyPrefs
+}
+
+func (m *Memory) now() time.Time {
+	return m.Now().UTC().Truncate(time.Microsecond)
+}
+
+func uniqueViolation(constraint string) error {
+	return fmt.Errorf("duplicate key value violates unique constraint %q", constraint)
+}
+
+// Users
+
+func (m *Memory) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email == arg.Email {
+			return database.User{}, uniqueViolation("users_email_key")
+		}
+	}
+	now := m.now()
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		Role:           auth.RoleUser,
+	}
+	m.users[user.ID] = user
+	return user, nil
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetUserById(ctx context.Context, id uuid.UUID) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (m *Memory) GetUserByUsername(ctx context.Context, username sql.NullString) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.users {
+		if username.Valid && u.Username == username {
+			return u, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetUserIdsByUsernames(ctx context.Context, usernames []string) ([]uuid.UUID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ids []uuid.UUID
+	for _, u := range m.users {
+		if u.Username.Valid && slices.Contains(usernames, u.Username.String) && !u.DisabledAt.Valid {
+			ids = append(ids, u.ID)
+		}
+	}
+	return ids, nil
+}
+
+// updateUser applies fn to the stored user and returns the result.
+func (m *Memory) updateUser(id uuid.UUID, fn func(*database.User) error) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	if err := fn(&u); err != nil {
+		return database.User{}, err
+	}
+	m.users[id] = u
+	return u, nil
+}
+
+func (m *Memory) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	return m.updateUser(arg.ID, func(u *database.User) error {
+		for _, other := range m.users {
+			if other.ID != arg.ID && other.Email == arg.Email {
+				return uniqueViolation("users_email_key")
+			}
+		}
+		u.UpdatedAt = m.now()
+		u.Email = arg.Email
+		u.HashedPassword = arg.HashedPassword
+		return nil
+	})
+}
+
+func (m *Memory) UpdateUserIsChirpyRed(ctx context.Context, arg database.UpdateUserIsChirpyRedParams) (database.User, error) {
+	return m.updateUser(arg.ID, func(u *database.User) error {
+		u.IsChirpyRed = arg.IsChirpyRed
+		return nil
+	})
+}
+
+func (m *Memory) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error) {
+	return m.updateUser(arg.ID, func(u *database.User) error {
+		if !auth.ValidRole(arg.Role) {
+			return fmt.Errorf("new row for relation \"users\" violates check constraint \"users_role_check\"")
+		}
+		u.UpdatedAt = m.now()
+		u.Role = arg.Role
+		return nil
+	})
+}
+
+func (m *Memory) SetUserDisabled(ctx context.Context, arg database.SetUserDisabledParams) (database.User, error) {
+	return m.updateUser(arg.ID, func(u *database.User) error {
+		now := m.now()
+		u.UpdatedAt = now
+		u.DisabledAt = sql.NullTime{Time: now, Valid: arg.Disabled}
+		return nil
+	})
+}
+
+func (m *Memory) SetUsername(ctx context.Context, arg database.SetUsernameParams) (database.User, error) {
+	return m.updateUser(arg.ID, func(u *database.User) error {
+		for _, other := range m.users {
+			if other.ID != arg.ID && arg.Username.Valid && other.Username == arg.Username {
+				return uniqueViolation("users_username_key")
+			}
+		}
+		u.UpdatedAt = m.now()
+		u.Username = arg.Username
+		return nil
+	})
+}
+
+func (m *Memory) CountUsersWithRole(ctx context.Context, role string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var n int64
+	for _, u := range m.users {
+		if u.Role == role {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) ResetUsers(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clear(m.users)
+	m.chirps = nil
+	clear(m.refreshTokens)
+	m.cases = nil
+	m.reports = nil
+	m.blocks = nil
+	m.mutes = nil
+	m.media = nil
+	m.scheduled = nil
+	m.drafts = nil
+	m.rechirps = nil
+	m.likes = nil
+	m.follows = nil
+	m.notifyEvents = nil
+	m.notifications = nil
+	m.notifyPrefs = nil
+	return nil
+}
+
+// Chirps
+
+func (m *Memory) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.Chirp{}, fmt.Errorf("insert on table \"chirps\" violates foreign key constraint \"chirps_user_id_fkey\"")
+	}
+	if arg.QuotedChirpID.Valid && !slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == arg.QuotedChirpID.UUID }) {
+		return database.Chirp{}, fmt.Errorf("insert on table \"chirps\" violates foreign key constraint \"chirps_quoted_chirp_id_fkey\"")
+	}
+	if arg.ReplyToChirpID.Valid && !slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == arg.ReplyToChirpID.UUID }) {
+		return database.Chirp{}, fmt.Errorf("insert on table \"chirps\" violates foreign key constraint \"chirps_reply_to_chirp_id_fkey\"")
+	}
+	now := m.now()
+	chirp := database.Chirp{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Body:           arg.Body,
+		UserID:         arg.UserID,
+		QuotedChirpID:  arg.QuotedChirpID,
+		ReplyToChirpID: arg.ReplyToChirpID,
+	}
+	m.chirps = append(m.chirps, chirp)
+	return chirp, nil
+}
+
+func (m *Memory) GetChirpById(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := m.GetChirpByIdWithDeleted(ctx, id)
+	if err == nil && chirp.DeletedAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, err
+}
+
+func (m *Memory) GetChirpByIdWithDeleted(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, c := range m.chirps {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return database.Chirp{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetChirpsByCreation(ctx context.Context) ([]database.Chirp, error) {
+	return m.filterChirps(func(database.Chirp) bool { return true }), nil
+}
+
+func (m *Memory) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	return m.filterChirps(func(c database.Chirp) bool { return c.UserID == userID }), nil
+}
+
+func (m *Memory) GetChirpsByIds(ctx context.Context, ids []uuid.UUID) ([]database.Chirp, error) {
+	return m.filterChirps(func(c database.Chirp) bool { return slices.Contains(ids, c.ID) }), nil
+}
+
+func (m *Memory) CountQuotesByChirpIds(ctx context.Context, quotedChirpIds []uuid.UUID) ([]database.CountQuotesByChirpIdsRow, error) {
+	counts := make(map[uuid.UUID]int64)
+	for _, c := range m.filterChirps(func(c database.Chirp) bool {
+		return c.QuotedChirpID.Valid && slices.Contains(quotedChirpIds, c.QuotedChirpID.UUID)
+	}) {
+		counts[c.QuotedChirpID.UUID]++
+	}
+	var out []database.CountQuotesByChirpIdsRow
+	for id, count := range counts {
+		out = append(out, database.CountQuotesByChirpIdsRow{QuotedChirpID: uuid.NullUUID{UUID: id, Valid: true}, QuoteCount: count})
+	}
+	return out, nil
+}
+
+// filterChirps skips deleted chirps, like every query but
+// GetChirpByIdWithDeleted. It returns nil rather than an empty slice when
+// nothing matches, the same as the generated code.
+func (m *Memory) filterChirps(keep func(database.Chirp) bool) []database.Chirp {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []database.Chirp
+	for _, c := range m.chirps {
+		if !c.DeletedAt.Valid && keep(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// GetChirpsVersion counts live chirps, but deleted ones still count toward
+// LastUpdated: deleting a chirp changes the list.
+func (m *Memory) GetChirpsVersion(ctx context.Context) (database.GetChirpsVersionRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	epoch := time.Unix(0, 0).UTC()
+	version := database.GetChirpsVersionRow{LastUpdated: epoch, CountsUpdated: epoch}
+	for _, c := range m.chirps {
+		if live(c) {
+			version.ChirpCount++
+		}
+		if c.UpdatedAt.After(version.LastUpdated) {
+			version.LastUpdated = c.UpdatedAt
+		}
+		if c.CountsUpdatedAt.Valid && c.CountsUpdatedAt.Time.After(version.CountsUpdated) {
+			version.CountsUpdated = c.CountsUpdatedAt.Time
+		}
+	}
+	return version, nil
+}
+
+// updateChirp applies fn to the chirp with id if match accepts it, the way
+// an UPDATE ... WHERE ... RETURNING would.
+func (m *Memory) updateChirp(id uuid.UUID, match func(database.Chirp) bool, fn func(*database.Chirp)) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.chirps, func(c database.Chirp) bool { return c.ID == id && match(c) })
+	if i < 0 {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	fn(&m.chirps[i])
+	m.chirps[i].UpdatedAt = m.now()
+	return m.chirps[i], nil
+}
+
+func live(c database.Chirp) bool {
+	return !c.DeletedAt.Valid
+}
+
+func (m *Memory) DeleteChirp(ctx context.Context, arg database.DeleteChirpParams) (database.Chirp, error) {
+	return m.updateChirp(arg.ID, live, func(c *database.Chirp) {
+		c.DeletedAt = sql.NullTime{Time: m.now(), Valid: true}
+		c.DeletedBy = arg.DeletedBy
+		c.DeletionReason = arg.DeletionReason
+	})
+}
+
+func (m *Memory) DeleteChirpByAuthor(ctx context.Context, arg database.DeleteChirpByAuthorParams) (database.Chirp, error) {
+	byAuthor := func(c database.Chirp) bool { return live(c) && c.UserID == arg.UserID }
+	return m.updateChirp(arg.ID, byAuthor, func(c *database.Chirp) {
+		c.DeletedAt = sql.NullTime{Time: m.now(), Valid: true}
+		c.DeletedBy = uuid.NullUUID{UUID: c.UserID, Valid: true}
+	})
+}
+
+func (m *Memory) RestoreChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	deleted := func(c database.Chirp) bool { return !live(c) }
+	return m.updateChirp(id, deleted, func(c *database.Chirp) {
+		c.DeletedAt = sql.NullTime{}
+		c.DeletedBy = uuid.NullUUID{}
+		c.DeletionReason = sql.NullString{}
+	})
+}
+
+func (m *Memory) TakeDownChirp(ctx context.Context, arg database.TakeDownChirpParams) (database.Chirp, error) {
+	return m.updateChirp(arg.ID, live, func(c *database.Chirp) {
+		c.TakenDownAt = sql.NullTime{Time: m.now(), Valid: true}
+		c.TakenDownBy = arg.TakenDownBy
+		c.TakedownReason = arg.TakedownReason
+	})
+}
+
+func (m *Memory) ReinstateChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	return m.updateChirp(id, live, func(c *database.Chirp) {
+		c.TakenDownAt = sql.NullTime{}
+		c.TakenDownBy = uuid.NullUUID{}
+		c.TakedownReason = sql.NullString{}
+	})
+}
+
+func (m *Memory) PurgeDeletedChirps(ctx context.Context, deletedAt sql.NullTime) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.chirps)
+	m.chirps = slices.DeleteFunc(m.chirps, func(c database.Chirp) bool {
+		return c.DeletedAt.Valid && deletedAt.Valid && c.DeletedAt.Time.Before(deletedAt.Time)
+	})
+	m.dropOrphanedModeration()
+	m.detachOrphanedMedia()
+	m.dropOrphanedRechirps()
+	m.dropOrphanedLikes()
+	m.dropOrphanedNotifications()
+	return int64(before - len(m.chirps)), nil
+}
+
+// TouchChirpCounts leaves updated_at alone: a rechirp or like isn't an edit
+// of the chirp itself.
+func (m *Memory) TouchChirpCounts(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.chirps {
+		if m.chirps[i].ID == id {
+			m.chirps[i].CountsUpdatedAt = sql.NullTime{Time: m.now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+// Rechirps
+
+func (m *Memory) CreateRechirp(ctx context.Context, arg database.CreateRechirpParams) (database.Rechirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slices.ContainsFunc(m.rechirps, func(r database.Rechirp) bool { return r.UserID == arg.UserID && r.ChirpID == arg.ChirpID }) {
+		// ON CONFLICT DO NOTHING returns no row
+		return database.Rechirp{}, sql.ErrNoRows
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.Rechirp{}, fmt.Errorf("insert on table \"rechirps\" violates foreign key constraint \"rechirps_user_id_fkey\"")
+	}
+	if !slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == arg.ChirpID }) {
+		return database.Rechirp{}, fmt.Errorf("insert on table \"rechirps\" violates foreign key constraint \"rechirps_chirp_id_fkey\"")
+	}
+	rechirp := database.Rechirp{UserID: arg.UserID, ChirpID: arg.ChirpID, CreatedAt: m.now()}
+	m.rechirps = append(m.rechirps, rechirp)
+	return rechirp, nil
+}
+
+func (m *Memory) GetRechirp(ctx context.Context, arg database.GetRechirpParams) (database.Rechirp, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.rechirps {
+		if r.UserID == arg.UserID && r.ChirpID == arg.ChirpID {
+			return r, nil
+		}
+	}
+	return database.Rechirp{}, sql.ErrNoRows
+}
+
+func (m *Memory) DeleteRechirp(ctx context.Context, arg database.DeleteRechirpParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.rechirps)
+	m.rechirps = slices.DeleteFunc(m.rechirps, func(r database.Rechirp) bool {
+		return r.UserID == arg.UserID && r.ChirpID == arg.ChirpID
+	})
+	return int64(before - len(m.rechirps)), nil
+}
+
+func (m *Memory) GetRechirps(ctx context.Context) ([]database.Rechirp, error) {
+	return m.filterRechirps(func(database.Rechirp) bool { return true }), nil
+}
+
+func (m *Memory) GetRechirpsByUserId(ctx context.Context, userID uuid.UUID) ([]database.Rechirp, error) {
+	return m.filterRechirps(func(r database.Rechirp) bool { return r.UserID == userID }), nil
+}
+
+// filterRechirps skips rechirps of deleted chirps, like the join in the
+// listing queries.
+func (m *Memory) filterRechirps(keep func(database.Rechirp) bool) []database.Rechirp {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []database.Rechirp
+	for _, r := range m.rechirps {
+		deleted := !slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == r.ChirpID && live(c) })
+		if !deleted && keep(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (m *Memory) CountRechirpsByChirpIds(ctx context.Context, chirpIds []uuid.UUID) ([]database.CountRechirpsByChirpIdsRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[uuid.UUID]int64)
+	for _, r := range m.rechirps {
+		if slices.Contains(chirpIds, r.ChirpID) {
+			counts[r.ChirpID]++
+		}
+	}
+	var out []database.CountRechirpsByChirpIdsRow
+	for id, count := range counts {
+		out = append(out, database.CountRechirpsByChirpIdsRow{ChirpID: id, RechirpCount: count})
+	}
+	return out, nil
+}
+
+func (m *Memory) GetRechirpsVersion(ctx context.Context) (database.GetRechirpsVersionRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	latest := time.Unix(0, 0).UTC()
+	for _, r := range m.rechirps {
+		if r.CreatedAt.After(latest) {
+			latest = r.CreatedAt
+		}
+	}
+	return database.GetRechirpsVersionRow{RechirpCount: int64(len(m.rechirps)), LastCreated: latest}, nil
+}
+
+// dropOrphanedRechirps stands in for the rechirps' ON DELETE CASCADE and the
+// quotes' and replies' ON DELETE SET NULL when chirps are purged.
+func (m *Memory) dropOrphanedRechirps() {
+	exists := func(id uuid.UUID) bool {
+		return slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == id })
+	}
+	m.rechirps = slices.DeleteFunc(m.rechirps, func(r database.Rechirp) bool { return !exists(r.ChirpID) })
+	for i, c := range m.chirps {
+		if c.QuotedChirpID.Valid && !exists(c.QuotedChirpID.UUID) {
+			m.chirps[i].QuotedChirpID = uuid.NullUUID{}
+		}
+		if c.ReplyToChirpID.Valid && !exists(c.ReplyToChirpID.UUID) {
+			m.chirps[i].ReplyToChirpID = uuid.NullUUID{}
+		}
+	}
+}
+
+// Likes
+
+func (m *Memory) CreateLike(ctx context.Context, arg database.CreateLikeParams) (database.Like, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slices.ContainsFunc(m.likes, func(l database.Like) bool { return l.UserID == arg.UserID && l.ChirpID == arg.ChirpID }) {
+		// ON CONFLICT DO NOTHING returns no row
+		return database.Like{}, sql.ErrNoRows
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.Like{}, fmt.Errorf("insert on table \"likes\" violates foreign key constraint \"likes_user_id_fkey\"")
+	}
+	if !slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == arg.ChirpID }) {
+		return database.Like{}, fmt.Errorf("insert on table \"likes\" violates foreign key constraint \"likes_chirp_id_fkey\"")
+	}
+	like := database.Like{UserID: arg.UserID, ChirpID: arg.ChirpID, CreatedAt: m.now()}
+	m.likes = append(m.likes, like)
+	return like, nil
+}
+
+func (m *Memory) GetLike(ctx context.Context, arg database.GetLikeParams) (database.Like, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, l := range m.likes {
+		if l.UserID == arg.UserID && l.ChirpID == arg.ChirpID {
+			return l, nil
+		}
+	}
+	return database.Like{}, sql.ErrNoRows
+}
+
+func (m *Memory) DeleteLike(ctx context.Context, arg database.DeleteLikeParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.likes)
+	m.likes = slices.DeleteFunc(m.likes, func(l database.Like) bool {
+		return l.UserID == arg.UserID && l.ChirpID == arg.ChirpID
+	})
+	return int64(before - len(m.likes)), nil
+}
+
+func (m *Memory) CountLikesByChirpIds(ctx context.Context, chirpIds []uuid.UUID) ([]database.CountLikesByChirpIdsRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[uuid.UUID]int64)
+	for _, l := range m.likes {
+		if slices.Contains(chirpIds, l.ChirpID) {
+			counts[l.ChirpID]++
+		}
+	}
+	var out []database.CountLikesByChirpIdsRow
+	for id, count := range counts {
+		out = append(out, database.CountLikesByChirpIdsRow{ChirpID: id, LikeCount: count})
+	}
+	return out, nil
+}
+
+func (m *Memory) GetLikesVersion(ctx context.Context) (database.GetLikesVersionRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	latest := time.Unix(0, 0).UTC()
+	for _, l := range m.likes {
+		if l.CreatedAt.After(latest) {
+			latest = l.CreatedAt
+		}
+	}
+	return database.GetLikesVersionRow{LikeCount: int64(len(m.likes)), LastCreated: latest}, nil
+}
+
+// dropOrphanedLikes stands in for the likes' ON DELETE CASCADE when chirps
+// are purged.
+func (m *Memory) dropOrphanedLikes() {
+	exists := func(id uuid.UUID) bool {
+		return slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == id })
+	}
+	m.likes = slices.DeleteFunc(m.likes, func(l database.Like) bool { return !exists(l.ChirpID) })
+}
+
+// Notifications
+
+func (m *Memory) EnqueueNotificationEvent(ctx context.Context, arg database.EnqueueNotificationEventParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.ActorID]; !ok {
+		return fmt.Errorf("insert on table \"notification_events\" violates foreign key constraint \"notification_events_actor_id_fkey\"")
+	}
+	if arg.ChirpID.Valid && !slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == arg.ChirpID.UUID }) {
+		return fmt.Errorf("insert on table \"notification_events\" violates foreign key constraint \"notification_events_chirp_id_fkey\"")
+	}
+	if _, ok := m.users[arg.UserID.UUID]; arg.UserID.Valid && !ok {
+		return fmt.Errorf("insert on table \"notification_events\" violates foreign key constraint \"notification_events_user_id_fkey\"")
+	}
+	m.notifyEvents = append(m.notifyEvents, database.NotificationEvent{
+		ID:        uuid.New(),
+		CreatedAt: m.now(),
+		Type:      arg.Type,
+		ActorID:   arg.ActorID,
+		ChirpID:   arg.ChirpID,
+		UserID:    arg.UserID,
+	})
+	return nil
+}
+
+func (m *Memory) TakeNotificationEvents(ctx context.Context, limit int32) ([]database.NotificationEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := min(int(limit), len(m.notifyEvents))
+	taken := slices.Clone(m.notifyEvents[:n])
+	m.notifyEvents = slices.Delete(m.notifyEvents, 0, n)
+	return taken, nil
+}
+
+func (m *Memory) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return fmt.Errorf("insert on table \"notifications\" violates foreign key constraint \"notifications_user_id_fkey\"")
+	}
+	m.notifications = append(m.notifications, database.Notification{
+		ID:        uuid.New(),
+		CreatedAt: m.now(),
+		UserID:    arg.UserID,
+		Type:      arg.Type,
+		ActorID:   arg.ActorID,
+		ChirpID:   arg.ChirpID,
+	})
+	return nil
+}
+
+func (m *Memory) GetNotificationsByUserId(ctx context.Context, arg database.GetNotificationsByUserIdParams) ([]database.Notification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []database.Notification
+	for _, n := range slices.Backward(m.notifications) {
+		if n.UserID == arg.UserID {
+			out = append(out, n)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b database.Notification) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	if len(out) > int(arg.Limit) {
+		out = out[:arg.Limit]
+	}
+	return out, nil
+}
+
+func (m *Memory) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var n int64
+	for _, notification := range m.notifications {
+		if notification.UserID == userID && !notification.ReadAt.Valid {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) MarkNotificationsRead(ctx context.Context, arg database.MarkNotificationsReadParams) (int64, error) {
+	return m.markRead(func(n database.Notification) bool { return n.UserID == arg.UserID && slices.Contains(arg.Ids, n.ID) }), nil
+}
+
+func (m *Memory) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return m.markRead(func(n database.Notification) bool { return n.UserID == userID }), nil
+}
+
+func (m *Memory) markRead(match func(database.Notification) bool) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	var n int64
+	for i, notification := range m.notifications {
+		if match(notification) && !notification.ReadAt.Valid {
+			m.notifications[i].ReadAt = sql.NullTime{Time: now, Valid: true}
+			n++
+		}
+	}
+	return n
+}
+
+func (m *Memory) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []database.NotificationPreference
+	for _, p := range m.notifyPrefs {
+		if p.UserID == userID {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) SetNotificationPreference(ctx context.Context, arg database.SetNotificationPreferenceParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return fmt.Errorf("insert on table \"notification_preferences\" violates foreign key constraint \"notification_preferences_user_id_fkey\"")
+	}
+	for i, p := range m.notifyPrefs {
+		if p.UserID == arg.UserID && p.Type == arg.Type {
+			m.notifyPrefs[i].Enabled = arg.Enabled
+			return nil
+		}
+	}
+	m.notifyPrefs = append(m.notifyPrefs, database.NotificationPreference{UserID: arg.UserID, Type: arg.Type, Enabled: arg.Enabled})
+	return nil
+}
+
+// dropOrphanedNotifications stands in for ON DELETE CASCADE from chirps to
+// notifications and their queued events.
+func (m *Memory) dropOrphanedNotifications() {
+	gone := func(id uuid.NullUUID) bool {
+		return id.Valid && !slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == id.UUID })
+	}
+	m.notifyEvents = slices.DeleteFunc(m.notifyEvents, func(e database.NotificationEvent) bool { return gone(e.ChirpID) })
+	m.notifications = slices.DeleteFunc(m.notifications, func(n database.Notification) bool { return gone(n.ChirpID) })
+}
+
+// Refresh tokens
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.refreshTokens[arg.Token]; ok {
+		return database.RefreshToken{}, uniqueViolation("refresh_tokens_pkey")
+	}
+	now := m.now()
+	token := database.RefreshToken{
+		Token:     arg.Token,
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	m.refreshTokens[token.Token] = token
+	return token, nil
+}
+
+func (m *Memory) GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, ok := m.refreshTokens[token]
+	if !ok {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+func (m *Memory) RevokeRefreshToken(ctx context.Context, token string) error {
+	m.revokeTokens(func(t database.RefreshToken) bool { return t.Token == token })
+	return nil
+}
+
+func (m *Memory) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return m.revokeTokens(func(t database.RefreshToken) bool { return t.UserID == userID }), nil
+}
+
+func (m *Memory) revokeTokens(match func(database.RefreshToken) bool) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	now := m.now()
+	for key, t := range m.refreshTokens {
+		if !match(t) || t.RevokedAt.Valid {
+			continue
+		}
+		t.RevokedAt = sql.NullTime{Time: now, Valid: true}
+		t.UpdatedAt = now
+		m.refreshTokens[key] = t
+		n++
+	}
+	return n
+}
+
+// Page views
+
+func (m *Memory) IncrementPageViews(ctx context.Context, arg database.IncrementPageViewsParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pageViews[pageViewKey{path: arg.Path, day: arg.Day}] += arg.Hits
+	return nil
+}
+
+func (m *Memory) GetTotalPageViews(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var total int64
+	for _, hits := range m.pageViews {
+		total += hits
+	}
+	return total, nil
+}
+
+func (m *Memory) GetPageViewsByDay(ctx context.Context, arg database.GetPageViewsByDayParams) ([]database.GetPageViewsByDayRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	byDay := make(map[time.Time]int64)
+	for k, hits := range m.pageViews {
+		if k.day.Before(arg.FromDay) || k.day.After(arg.ToDay) || (arg.Path.Valid && k.path != arg.Path.String) {
+			continue
+		}
+		byDay[k.day] += hits
+	}
+
+	var rows []database.GetPageViewsByDayRow
+	for day, hits := range byDay {
+		rows = append(rows, database.GetPageViewsByDayRow{Day: day, Hits: hits})
+	}
+	slices.SortFunc(rows, func(a, b database.GetPageViewsByDayRow) int { return a.Day.Compare(b.Day) })
+	return rows, nil
+}
+
+func (m *Memory) GetTopPages(ctx context.Context, arg database.GetTopPagesParams) ([]database.GetTopPagesRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	byPath := make(map[string]int64)
+	for k, hits := range m.pageViews {
+		if k.day.Before(arg.FromDay) || k.day.After(arg.ToDay) {
+			continue
+		}
+		byPath[k.path] += hits
+	}
+
+	var rows []database.GetTopPagesRow
+	for path, hits := range byPath {
+		rows = append(rows, database.GetTopPagesRow{Path: path, Hits: hits})
+	}
+	slices.SortFunc(rows, func(a, b database.GetTopPagesRow) int {
+		return cmp.Or(cmp.Compare(b.Hits, a.Hits), cmp.Compare(a.Path, b.Path))
+	})
+	return rows[:min(len(rows), int(arg.MaxPaths))], nil
+}
+
+func (m *Memory) ResetPageViews(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clear(m.pageViews)
+	return nil
+}
+
+// Webhook events
+
+func (m *Memory) CreateWebhookEvent(ctx context.Context, arg database.CreateWebhookEventParams) (database.WebhookEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	event := database.WebhookEvent{
+		ID:        uuid.New(),
+		CreatedAt: m.now(),
+		Provider:  arg.Provider,
+		Event:     arg.Event,
+		UserID:    arg.UserID,
+		Outcome:   arg.Outcome,
+	}
+	m.webhookEvents = append(m.webhookEvents, event)
+	return event, nil
+}
+
+func (m *Memory) GetRecentWebhookEvents(ctx context.Context, limit int32) ([]database.WebhookEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var events []database.WebhookEvent
+	for i := len(m.webhookEvents) - 1; i >= 0 && len(events) < int(limit); i-- {
+		events = append(events, m.webhookEvents[i])
+	}
+	return events, nil
+}
+
+// Scheduled chirps
+
+func (m *Memory) CreateScheduledChirp(ctx context.Context, arg database.CreateScheduledChirpParams) (database.ScheduledChirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.ScheduledChirp{}, fmt.Errorf("insert on table \"scheduled_chirps\" violates foreign key constraint \"scheduled_chirps_user_id_fkey\"")
+	}
+	now := m.now()
+	scheduled := database.ScheduledChirp{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+		PublishAt: arg.PublishAt,
+	}
+	m.scheduled = append(m.scheduled, scheduled)
+	return scheduled, nil
+}
+
+func (m *Memory) CountScheduledChirpsByUserId(ctx context.Context, userID uuid.UUID) (int64, error) {
+	scheduled, err := m.GetScheduledChirpsByUserId(ctx, userID)
+	return int64(len(scheduled)), err
+}
+
+func (m *Memory) GetScheduledChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]database.ScheduledChirp, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []database.ScheduledChirp
+	for _, s := range m.scheduled {
+		if s.UserID == userID {
+			out = append(out, s)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b database.ScheduledChirp) int { return a.PublishAt.Compare(b.PublishAt) })
+	return out, nil
+}
+
+func (m *Memory) GetScheduledChirp(ctx context.Context, arg database.GetScheduledChirpParams) (database.ScheduledChirp, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, s := range m.scheduled {
+		if s.ID == arg.ID && s.UserID == arg.UserID {
+			return s, nil
+		}
+	}
+	return database.ScheduledChirp{}, sql.ErrNoRows
+}
+
+func (m *Memory) UpdateScheduledChirp(ctx context.Context, arg database.UpdateScheduledChirpParams) (database.ScheduledChirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, s := range m.scheduled {
+		if s.ID == arg.ID && s.UserID == arg.UserID {
+			m.scheduled[i].Body = arg.Body
+			m.scheduled[i].PublishAt = arg.PublishAt
+			m.scheduled[i].UpdatedAt = m.now()
+			return m.scheduled[i], nil
+		}
+	}
+	return database.ScheduledChirp{}, sql.ErrNoRows
+}
+
+func (m *Memory) DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.scheduled)
+	m.scheduled = slices.DeleteFunc(m.scheduled, func(s database.ScheduledChirp) bool {
+		return s.ID == arg.ID && s.UserID == arg.UserID
+	})
+	return int64(before - len(m.scheduled)), nil
+}
+
+func (m *Memory) TakeDueScheduledChirps(ctx context.Context, arg database.TakeDueScheduledChirpsParams) ([]database.ScheduledChirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []database.ScheduledChirp
+	for _, s := range m.scheduled {
+		if !s.PublishAt.After(arg.PublishAt) && !m.users[s.UserID].DisabledAt.Valid {
+			due = append(due, s)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b database.ScheduledChirp) int { return a.PublishAt.Compare(b.PublishAt) })
+	if len(due) > int(arg.Limit) {
+		due = due[:arg.Limit]
+	}
+	m.scheduled = slices.DeleteFunc(m.scheduled, func(s database.ScheduledChirp) bool {
+		return slices.ContainsFunc(due, func(d database.ScheduledChirp) bool { return d.ID == s.ID })
+	})
+	return due, nil
+}
+
+// Drafts
+
+func (m *Memory) CreateDraft(ctx context.Context, arg database.CreateDraftParams) (database.Draft, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.Draft{}, fmt.Errorf("insert on table \"drafts\" violates foreign key constraint \"drafts_user_id_fkey\"")
+	}
+	now := m.now()
+	draft := database.Draft{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+	}
+	m.drafts = append(m.drafts, draft)
+	return draft, nil
+}
+
+func (m *Memory) GetDraftsByUserId(ctx context.Context, userID uuid.UUID) ([]database.Draft, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []database.Draft
+	for _, d := range m.drafts {
+		if d.UserID == userID {
+			out = append(out, d)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b database.Draft) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+	return out, nil
+}
+
+func (m *Memory) GetDraft(ctx context.Context, arg database.GetDraftParams) (database.Draft, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, d := range m.drafts {
+		if d.ID == arg.ID && d.UserID == arg.UserID {
+			return d, nil
+		}
+	}
+	return database.Draft{}, sql.ErrNoRows
+}
+
+func (m *Memory) UpdateDraft(ctx context.Context, arg database.UpdateDraftParams) (database.Draft, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, d := range m.drafts {
+		if d.ID == arg.ID && d.UserID == arg.UserID && d.UpdatedAt.Equal(arg.UpdatedAt) {
+			m.drafts[i].Body = arg.Body
+			m.drafts[i].UpdatedAt = m.now()
+			return m.drafts[i], nil
+		}
+	}
+	return database.Draft{}, sql.ErrNoRows
+}
+
+func (m *Memory) DeleteDraft(ctx context.Context, arg database.DeleteDraftParams) (database.Draft, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, d := range m.drafts {
+		if d.ID == arg.ID && d.UserID == arg.UserID {
+			m.drafts = slices.Delete(m.drafts, i, i+1)
+			return d, nil
+		}
+	}
+	return database.Draft{}, sql.ErrNoRows
+}
+
+// Media
+
+func (m *Memory) CreateMediaAttachment(ctx context.Context, arg database.CreateMediaAttachmentParams) (database.MediaAttachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.MediaAttachment{}, fmt.Errorf("insert on table \"media_attachments\" violates foreign key constraint \"media_attachments_user_id_fkey\"")
+	}
+	attachment := database.MediaAttachment{
+		ID:           arg.ID,
+		CreatedAt:    m.now(),
+		UserID:       arg.UserID,
+		ContentType:  arg.ContentType,
+		Width:        arg.Width,
+		Height:       arg.Height,
+		SizeBytes:    arg.SizeBytes,
+		StorageKey:   arg.StorageKey,
+		ThumbnailKey: arg.ThumbnailKey,
+	}
+	m.media = append(m.media, attachment)
+	return attachment, nil
+}
+
+func (m *Memory) AttachMedia(ctx context.Context, arg database.AttachMediaParams) (database.MediaAttachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.media, func(a database.MediaAttachment) bool {
+		return a.ID == arg.ID && a.UserID == arg.UserID && !a.ChirpID.Valid
+	})
+	if i < 0 {
+		return database.MediaAttachment{}, sql.ErrNoRows
+	}
+	if slices.ContainsFunc(m.media, func(a database.MediaAttachment) bool {
+		return a.ChirpID == arg.ChirpID && a.Position == arg.Position
+	}) {
+		return database.MediaAttachment{}, uniqueViolation("media_attachments_chirp_id_position_key")
+	}
+	m.media[i].ChirpID = arg.ChirpID
+	m.media[i].Position = arg.Position
+	return m.media[i], nil
+}
+
+func (m *Memory) GetMediaByChirpIds(ctx context.Context, chirpIds []uuid.UUID) ([]database.MediaAttachment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []database.MediaAttachment
+	for _, a := range m.media {
+		if a.ChirpID.Valid && slices.Contains(chirpIds, a.ChirpID.UUID) {
+			out = append(out, a)
+		}
+	}
+	slices.SortFunc(out, func(a, b database.MediaAttachment) int {
+		return cmp.Or(cmp.Compare(a.ChirpID.UUID.String(), b.ChirpID.UUID.String()), cmp.Compare(a.Position.Int32, b.Position.Int32))
+	})
+	return out, nil
+}
+
+func (m *Memory) DeleteUnattachedMedia(ctx context.Context, createdAt time.Time) ([]database.DeleteUnattachedMediaRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rows []database.DeleteUnattachedMediaRow
+	m.media = slices.DeleteFunc(m.media, func(a database.MediaAttachment) bool {
+		if a.ChirpID.Valid || !a.CreatedAt.Before(createdAt) {
+			return false
+		}
+		rows = append(rows, database.DeleteUnattachedMediaRow{StorageKey: a.StorageKey, ThumbnailKey: a.ThumbnailKey})
+		return true
+	})
+	return rows, nil
+}
+
+// detachOrphanedMedia unlinks attachments from chirps that no longer exist,
+// as ON DELETE SET NULL would. The caller holds mu.
+func (m *Memory) detachOrphanedMedia() {
+	for i, a := range m.media {
+		if a.ChirpID.Valid && !slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == a.ChirpID.UUID }) {
+			m.media[i].ChirpID = uuid.NullUUID{}
+			m.media[i].Position = sql.NullInt32{}
+		}
+	}
+}
+
+// Link previews
+
+func (m *Memory) EnqueueLinkPreview(ctx context.Context, url string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slices.ContainsFunc(m.linkPreviews, func(p database.LinkPreview) bool { return p.Url == url }) {
+		return nil
+	}
+	now := m.now()
+	m.linkPreviews = append(m.linkPreviews, database.LinkPreview{Url: url, CreatedAt: now, UpdatedAt: now, Status: "pending"})
+	return nil
+}
+
+func (m *Memory) ClaimLinkPreviews(ctx context.Context, arg database.ClaimLinkPreviewsParams) ([]database.LinkPreview, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []database.LinkPreview
+	for i, p := range m.linkPreviews {
+		if p.Status == "pending" && !p.NextAttemptAt.After(arg.Now) && len(out) < int(arg.MaxClaimed) {
+			m.linkPreviews[i].NextAttemptAt = arg.ClaimedUntil
+			out = append(out, m.linkPreviews[i])
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) updateLinkPreview(url string, update func(*database.LinkPreview)) {
+	for i := range m.linkPreviews {
+		if m.linkPreviews[i].Url == url {
+			update(&m.linkPreviews[i])
+			m.linkPreviews[i].UpdatedAt = m.now()
+		}
+	}
+}
+
+func (m *Memory) CompleteLinkPreview(ctx context.Context, arg database.CompleteLinkPreviewParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.updateLinkPreview(arg.Url, func(p *database.LinkPreview) {
+		p.Status = "ready"
+		p.Attempts++
+		p.Title = arg.Title
+		p.Description = arg.Description
+		p.ImageUrl = arg.ImageUrl
+	})
+	return nil
+}
+
+func (m *Memory) FailLinkPreview(ctx context.Context, arg database.FailLinkPreviewParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.updateLinkPreview(arg.Url, func(p *database.LinkPreview) {
+		p.Attempts++
+		if p.Attempts >= arg.MaxAttempts {
+			p.Status = "failed"
+		}
+		p.NextAttemptAt = arg.NextAttemptAt
+	})
+	return nil
+}
+
+func (m *Memory) GetLinkPreviewsByUrls(ctx context.Context, urls []string) ([]database.LinkPreview, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []database.LinkPreview
+	for _, p := range m.linkPreviews {
+		if p.Status == "ready" && slices.Contains(urls, p.Url) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) GetLinkPreviewsVersion(ctx context.Context) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	latest := time.Unix(0, 0).UTC()
+	for _, p := range m.linkPreviews {
+		if p.Status == "ready" && p.UpdatedAt.After(latest) {
+			latest = p.UpdatedAt
+		}
+	}
+	return latest, nil
+}
+
+// Relationships
+
+// checkRelationship enforces the foreign keys and the self-reference check
+// shared by user_blocks, user_mutes and user_follows. The caller holds mu.
+func (m *Memory) checkRelationship(table string, from, to uuid.UUID) error {
+	if from == to {
+		return fmt.Errorf("new row for relation %q violates check constraint \"%s_check\"", table, table)
+	}
+	for _, id := range []uuid.UUID{from, to} {
+		if _, ok := m.users[id]; !ok {
+			return fmt.Errorf("insert on table %q violates foreign key constraint", table)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) BlockUser(ctx context.Context, arg database.BlockUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRelationship("user_blocks", arg.BlockerID, arg.BlockedID); err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(m.blocks, func(b database.UserBlock) bool {
+		return b.BlockerID == arg.BlockerID && b.BlockedID == arg.BlockedID
+	}) {
+		m.blocks = append(m.blocks, database.UserBlock{BlockerID: arg.BlockerID, BlockedID: arg.BlockedID, CreatedAt: m.now()})
+	}
+	return nil
+}
+
+func (m *Memory) UnblockUser(ctx context.Context, arg database.UnblockUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.blocks = slices.DeleteFunc(m.blocks, func(b database.UserBlock) bool {
+		return b.BlockerID == arg.BlockerID && b.BlockedID == arg.BlockedID
+	})
+	return nil
+}
+
+func (m *Memory) GetBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]database.UserBlock, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var blocks []database.UserBlock
+	for i := len(m.blocks) - 1; i >= 0; i-- {
+		if m.blocks[i].BlockerID == blockerID {
+			blocks = append(blocks, m.blocks[i])
+		}
+	}
+	return blocks, nil
+}
+
+func (m *Memory) IsUserBlocked(ctx context.Context, arg database.IsUserBlockedParams) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.ContainsFunc(m.blocks, func(b database.UserBlock) bool {
+		return b.BlockerID == arg.BlockerID && b.BlockedID == arg.BlockedID
+	}), nil
+}
+
+func (m *Memory) MuteUser(ctx context.Context, arg database.MuteUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRelationship("user_mutes", arg.MuterID, arg.MutedID); err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(m.mutes, func(u database.UserMute) bool {
+		return u.MuterID == arg.MuterID && u.MutedID == arg.MutedID
+	}) {
+		m.mutes = append(m.mutes, database.UserMute{MuterID: arg.MuterID, MutedID: arg.MutedID, CreatedAt: m.now()})
+	}
+	return nil
+}
+
+func (m *Memory) UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mutes = slices.DeleteFunc(m.mutes, func(u database.UserMute) bool {
+		return u.MuterID == arg.MuterID && u.MutedID == arg.MutedID
+	})
+	return nil
+}
+
+func (m *Memory) GetMutedUsers(ctx context.Context, muterID uuid.UUID) ([]database.UserMute, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var mutes []database.UserMute
+	for i := len(m.mutes) - 1; i >= 0; i-- {
+		if m.mutes[i].MuterID == muterID {
+			mutes = append(mutes, m.mutes[i])
+		}
+	}
+	return mutes, nil
+}
+
+func (m *Memory) GetHiddenUserIds(ctx context.Context, blockerID uuid.UUID) ([]uuid.UUID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ids []uuid.UUID
+	for _, b := range m.blocks {
+		if b.BlockerID == blockerID && !slices.Contains(ids, b.BlockedID) {
+			ids = append(ids, b.BlockedID)
+		}
+	}
+	for _, u := range m.mutes {
+		if u.MuterID == blockerID && !slices.Contains(ids, u.MutedID) {
+			ids = append(ids, u.MutedID)
+		}
+	}
+	return ids, nil
+}
+
+func (m *Memory) FollowUser(ctx context.Context, arg database.FollowUserParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRelationship("user_follows", arg.FollowerID, arg.FollowedID); err != nil {
+		return 0, err
+	}
+	if slices.ContainsFunc(m.follows, func(f database.UserFollow) bool {
+		return f.FollowerID == arg.FollowerID && f.FollowedID == arg.FollowedID
+	}) {
+		return 0, nil
+	}
+	m.follows = append(m.follows, database.UserFollow{FollowerID: arg.FollowerID, FollowedID: arg.FollowedID, CreatedAt: m.now()})
+	return 1, nil
+}
+
+func (m *Memory) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.follows = slices.DeleteFunc(m.follows, func(f database.UserFollow) bool {
+		return f.FollowerID == arg.FollowerID && f.FollowedID == arg.FollowedID
+	})
+	return nil
+}
+
+func (m *Memory) GetFollowedUsers(ctx context.Context, followerID uuid.UUID) ([]database.UserFollow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var follows []database.UserFollow
+	for i := len(m.follows) - 1; i >= 0; i-- {
+		if m.follows[i].FollowerID == followerID {
+			follows = append(follows, m.follows[i])
+		}
+	}
+	return follows, nil
+}
+
+func (m *Memory) IsUserFollowed(ctx context.Context, arg database.IsUserFollowedParams) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.ContainsFunc(m.follows, func(f database.UserFollow) bool {
+		return f.FollowerID == arg.FollowerID && f.FollowedID == arg.FollowedID
+	}), nil
+}
+
+// Moderation
+
+// dropOrphanedModeration removes the cases and reports of chirps that no
+// longer exist, as ON DELETE CASCADE would. The caller holds mu.
+func (m *Memory) dropOrphanedModeration() {
+	gone := func(chirpID uuid.UUID) bool {
+		return !slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == chirpID })
+	}
+	m.cases = slices.DeleteFunc(m.cases, func(c database.ModerationCase) bool { return gone(c.ChirpID) })
+	m.reports = slices.DeleteFunc(m.reports, func(r database.Report) bool { return gone(r.ChirpID) })
+}
+
+func (m *Memory) CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slices.ContainsFunc(m.reports, func(r database.Report) bool {
+		return r.ChirpID == arg.ChirpID && r.ReporterID == arg.ReporterID
+	}) {
+		return database.Report{}, uniqueViolation("reports_chirp_id_reporter_id_key")
+	}
+	report := database.Report{
+		ID:         uuid.New(),
+		CreatedAt:  m.now(),
+		CaseID:     arg.CaseID,
+		ChirpID:    arg.ChirpID,
+		ReporterID: arg.ReporterID,
+		Reason:     arg.Reason,
+		Details:    arg.Details,
+	}
+	m.reports = append(m.reports, report)
+	return report, nil
+}
+
+func (m *Memory) GetReportByReporter(ctx context.Context, arg database.GetReportByReporterParams) (database.Report, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.reports {
+		if r.ChirpID == arg.ChirpID && r.ReporterID == arg.ReporterID {
+			return r, nil
+		}
+	}
+	return database.Report{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetReportsByCase(ctx context.Context, caseID uuid.UUID) ([]database.Report, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var reports []database.Report
+	for _, r := range m.reports {
+		if r.CaseID == caseID {
+			reports = append(reports, r)
+		}
+	}
+	return reports, nil
+}
+
+// OpenModerationCase returns the chirp's unresolved case, creating one if
+// there is none; a chirp has at most one unresolved case at a time.
+func (m *Memory) OpenModerationCase(ctx context.Context, chirpID uuid.UUID) (database.ModerationCase, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == chirpID }) {
+		return database.ModerationCase{}, fmt.Errorf("insert on table \"moderation_cases\" violates foreign key constraint \"moderation_cases_chirp_id_fkey\"")
+	}
+	now := m.now()
+	for i, c := range m.cases {
+		if c.ChirpID == chirpID && c.Status != "resolved" {
+			m.cases[i].UpdatedAt = now
+			return m.cases[i], nil
+		}
+	}
+	modCase := database.ModerationCase{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		ChirpID:   chirpID,
+		Status:    "open",
+	}
+	m.cases = append(m.cases, modCase)
+	return modCase, nil
+}
+
+// updateCase is updateChirp for moderation cases.
+func (m *Memory) updateCase(id uuid.UUID, match func(database.ModerationCase) bool, fn func(*database.ModerationCase)) (database.ModerationCase, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.cases, func(c database.ModerationCase) bool { return c.ID == id && match(c) })
+	if i < 0 {
+		return database.ModerationCase{}, sql.ErrNoRows
+	}
+	fn(&m.cases[i])
+	m.cases[i].UpdatedAt = m.now()
+	return m.cases[i], nil
+}
+
+func (m *Memory) IncrementCaseReportCount(ctx context.Context, id uuid.UUID) (database.ModerationCase, error) {
+	anyCase := func(database.ModerationCase) bool { return true }
+	return m.updateCase(id, anyCase, func(c *database.ModerationCase) {
+		c.ReportCount++
+	})
+}
+
+func (m *Memory) GetModerationCase(ctx context.Context, id uuid.UUID) (database.ModerationCase, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, c := range m.cases {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return database.ModerationCase{}, sql.ErrNoRows
+}
+
+func (m *Memory) ListModerationCases(ctx context.Context, arg database.ListModerationCasesParams) ([]database.ListModerationCasesRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rows []database.ListModerationCasesRow
+	for _, c := range m.cases {
+		if c.Status != arg.Status {
+			continue
+		}
+		i := slices.IndexFunc(m.chirps, func(chirp database.Chirp) bool { return chirp.ID == c.ChirpID })
+		if i < 0 {
+			continue
+		}
+		rows = append(rows, database.ListModerationCasesRow{
+			ID:          c.ID,
+			CreatedAt:   c.CreatedAt,
+			UpdatedAt:   c.UpdatedAt,
+			ChirpID:     c.ChirpID,
+			Status:      c.Status,
+			ReportCount: c.ReportCount,
+			ClaimedBy:   c.ClaimedBy,
+			ClaimedAt:   c.ClaimedAt,
+			Resolution:  c.Resolution,
+			ResolvedBy:  c.ResolvedBy,
+			ResolvedAt:  c.ResolvedAt,
+			AuthorID:    m.chirps[i].UserID,
+			ChirpBody:   m.chirps[i].Body,
+		})
+	}
+	// stable, so equal counts stay in creation order
+	slices.SortStableFunc(rows, func(a, b database.ListModerationCasesRow) int {
+		return cmp.Compare(b.ReportCount, a.ReportCount)
+	})
+	return rows[:min(len(rows), int(arg.Limit))], nil
+}
+
+func (m *Memory) ClaimModerationCase(ctx context.Context, arg database.ClaimModerationCaseParams) (database.ModerationCase, error) {
+	claimable := func(c database.ModerationCase) bool {
+		return c.Status == "open" || (c.Status == "claimed" && c.ClaimedBy == arg.ClaimedBy)
+	}
+	return m.updateCase(arg.ID, claimable, func(c *database.ModerationCase) {
+		c.Status = "claimed"
+		c.ClaimedBy = arg.ClaimedBy
+		c.ClaimedAt = sql.NullTime{Time: m.now(), Valid: true}
+	})
+}
+
+func (m *Memory) ResolveModerationCase(ctx context.Context, arg database.ResolveModerationCaseParams) (database.ModerationCase, error) {
+	claimedByResolver := func(c database.ModerationCase) bool {
+		return c.Status == "claimed" && c.ClaimedBy == arg.ResolvedBy
+	}
+	return m.updateCase(arg.ID, claimedByResolver, func(c *database.ModerationCase) {
+		c.Status = "resolved"
+		c.Resolution = arg.Resolution
+		c.ResolvedBy = arg.ResolvedBy
+		c.ResolvedAt = sql.NullTime{Time: m.now(), Valid: true}
+	})
+}
+
+func (m *Memory) CreateModerationAction(ctx context.Context, arg database.CreateModerationActionParams) (database.ModerationAction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	action := database.ModerationAction{
+		ID:           uuid.New(),
+		CreatedAt:    m.now(),
+		ModeratorID:  arg.ModeratorID,
+		CaseID:       arg.CaseID,
+		ChirpID:      arg.ChirpID,
+		TargetUserID: arg.TargetUserID,
+		Action:       arg.Action,
+		Note:         arg.Note,
+	}
+	m.modActions = append(m.modActions, action)
+	return action, nil
+}
+
+func (m *Memory) GetModerationActions(ctx context.Context, limit int32) ([]database.ModerationAction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var actions []database.ModerationAction
+	for i := len(m.modActions) - 1; i >= 0 && len(actions) < int(limit); i-- {
+		actions = append(actions, m.modActions[i])
+	}
+	return actions, nil
+}
+
+// Stats
+
+func (m *Memory) CountUsers(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return int64(len(m.users)), nil
+}
+
+func (m *Memory) CountChirpyRedUsers(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var n int64
+	for _, u := range m.users {
+		if u.IsChirpyRed {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) CountActiveSessions(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var n int64
+	now := m.now()
+	for _, t := range m.refreshTokens {
+		if !t.RevokedAt.Valid && t.ExpiresAt.After(now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) CountChirpsPerDay(ctx context.Context, createdAt time.Time) ([]database.CountChirpsPerDayRow, error) {
+	byDay := make(map[time.Time]int64)
+	for _, c := range m.filterChirps(func(c database.Chirp) bool { return !c.CreatedAt.Before(createdAt) }) {
+		y, mo, d := c.CreatedAt.Date()
+		byDay[time.Date(y, mo, d, 0, 0, 0, 0, time.UTC)]++
+	}
+
+	var rows []database.CountChirpsPerDayRow
+	for day, n := range byDay {
+		rows = append(rows, database.CountChirpsPerDayRow{Day: day, Chirps: n})
+	}
+	slices.SortFunc(rows, func(a, b database.CountChirpsPerDayRow) int { return a.Day.Compare(b.Day) })
+	return rows, nil
+}
+
+func (m *Memory) GetTopPosters(ctx context.Context, limit int32) ([]database.GetTopPostersRow, error) {
+	counts := make(map[uuid.UUID]int64)
+	for _, c := range m.filterChirps(func(database.Chirp) bool { return true }) {
+		counts[c.UserID]++
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var rows []database.GetTopPostersRow
+	for id, n := range counts {
+		rows = append(rows, database.GetTopPostersRow{ID: id, Email: m.users[id].Email, ChirpCount: n})
+	}
+	slices.SortFunc(rows, func(a, b database.GetTopPostersRow) int {
+		return cmp.Or(cmp.Compare(b.ChirpCount, a.ChirpCount), cmp.Compare(a.Email, b.Email))
+	})
+	return rows[:min(len(rows), int(limit))], nil
+}
@@ -0,0 +1,306 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/database"
+)
+
+func TestMemoryUsers(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	user, err := m.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com", HashedPassword: "hash"})
+	if err != nil {
+		t.Fatalf("Error creating user: %v", err)
+	}
+	if user.Role != "user" || user.CreatedAt.IsZero() {
+		t.Fatalf("Expected defaults to be filled in, got %+v", user)
+	}
+	if _, err := m.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"}); err == nil {
+		t.Fatalf("Expected duplicate email to be rejected")
+	}
+
+	got, err := m.GetUserByEmail(ctx, "a@example.com")
+	if err != nil || got.ID != user.ID {
+		t.Fatalf("Expected to find user %s by email, got %v, %v", user.ID, got.ID, err)
+	}
+	if _, err := m.GetUserByEmail(ctx, "b@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected sql.ErrNoRows for an unknown email, got %v", err)
+	}
+
+	if _, err := m.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: "superuser"}); err == nil {
+		t.Fatalf("Expected an unknown role to be rejected")
+	}
+	if _, err := m.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: "admin"}); err != nil {
+		t.Fatalf("Error setting role: %v", err)
+	}
+	if n, _ := m.CountUsersWithRole(ctx, "admin"); n != 1 {
+		t.Fatalf("Expected 1 admin, got %d", n)
+	}
+}
+
+func TestMemoryResetCascades(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	user, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+	chirp, err := m.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: user.ID})
+	if err != nil {
+		t.Fatalf("Error creating chirp: %v", err)
+	}
+	m.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "t", UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)})
+
+	if err := m.ResetUsers(ctx); err != nil {
+		t.Fatalf("Error resetting users: %v", err)
+	}
+	if _, err := m.GetChirpById(ctx, chirp.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected chirps to be deleted with their author, got %v", err)
+	}
+	if _, err := m.GetRefreshToken(ctx, "t"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected refresh tokens to be deleted with their user, got %v", err)
+	}
+}
+
+func TestMemoryChirpsVersion(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	m.Now = func() time.Time { return now }
+
+	version, _ := m.GetChirpsVersion(ctx)
+	if version.ChirpCount != 0 || !version.LastUpdated.Equal(time.Unix(0, 0)) {
+		t.Fatalf("Expected an empty table to report 0 chirps at the epoch, got %+v", version)
+	}
+
+	user, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+	m.CreateChirp(ctx, database.CreateChirpParams{Body: "first", UserID: user.ID})
+	now = now.Add(time.Minute)
+	second, _ := m.CreateChirp(ctx, database.CreateChirpParams{Body: "second", UserID: user.ID})
+
+	version, _ = m.GetChirpsVersion(ctx)
+	if version.ChirpCount != 2 || !version.LastUpdated.Equal(second.UpdatedAt) {
+		t.Fatalf("Expected 2 chirps last updated at %v, got %+v", second.UpdatedAt, version)
+	}
+
+	chirps, _ := m.GetChirpsByUserId(ctx, user.ID)
+	if len(chirps) != 2 || chirps[0].Body != "first" {
+		t.Fatalf("Expected chirps in creation order, got %+v", chirps)
+	}
+}
+
+func TestMemoryRevokeUserRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	user, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+	expires := time.Now().Add(time.Hour)
+	m.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "one", UserID: user.ID, ExpiresAt: expires})
+	m.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "two", UserID: user.ID, ExpiresAt: expires})
+	m.RevokeRefreshToken(ctx, "one")
+
+	revoked, err := m.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil || revoked != 1 {
+		t.Fatalf("Expected only the unrevoked token to count, got %d, %v", revoked, err)
+	}
+	if n, _ := m.CountActiveSessions(ctx); n != 0 {
+		t.Fatalf("Expected no active sessions, got %d", n)
+	}
+}
+
+func TestMemoryInTxRollsBack(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	failure := errors.New("second step failed")
+
+	err := m.InTx(ctx, func(tx Store) error {
+		if _, err := tx.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the callback's error, got %v", err)
+	}
+	if n, _ := m.CountUsers(ctx); n != 0 {
+		t.Fatalf("Expected the user to be rolled back, got %d users", n)
+	}
+
+	err = m.InTx(ctx, func(tx Store) error {
+		return tx.InTx(ctx, func(nested Store) error {
+			_, err := nested.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("Error in nested transaction: %v", err)
+	}
+	if n, _ := m.CountUsers(ctx); n != 1 {
+		t.Fatalf("Expected the nested transaction to commit, got %d users", n)
+	}
+}
+
+func TestMemoryDeleteChirpByAuthor(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	author, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+	other, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "b@example.com"})
+	chirp, _ := m.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: author.ID})
+
+	if _, err := m.DeleteChirpByAuthor(ctx, database.DeleteChirpByAuthorParams{ID: chirp.ID, UserID: other.ID}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected sql.ErrNoRows for another user, got %v", err)
+	}
+	deleted, err := m.DeleteChirpByAuthor(ctx, database.DeleteChirpByAuthorParams{ID: chirp.ID, UserID: author.ID})
+	if err != nil || deleted.ID != chirp.ID {
+		t.Fatalf("Expected the deleted chirp to be returned, got %v, %v", deleted.ID, err)
+	}
+}
+
+func TestMemoryPurgeDeletedChirps(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	m.Now = func() time.Time { return now }
+
+	user, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+	old, _ := m.CreateChirp(ctx, database.CreateChirpParams{Body: "old", UserID: user.ID})
+	recent, _ := m.CreateChirp(ctx, database.CreateChirpParams{Body: "recent", UserID: user.ID})
+	m.CreateChirp(ctx, database.CreateChirpParams{Body: "kept", UserID: user.ID})
+	m.DeleteChirpByAuthor(ctx, database.DeleteChirpByAuthorParams{ID: old.ID, UserID: user.ID})
+	now = now.Add(48 * time.Hour)
+	m.DeleteChirpByAuthor(ctx, database.DeleteChirpByAuthorParams{ID: recent.ID, UserID: user.ID})
+
+	purged, err := m.PurgeDeletedChirps(ctx, sql.NullTime{Time: now.Add(-24 * time.Hour), Valid: true})
+	if err != nil || purged != 1 {
+		t.Fatalf("Expected 1 chirp purged, got %d, %v", purged, err)
+	}
+	if _, err := m.GetChirpByIdWithDeleted(ctx, recent.ID); err != nil {
+		t.Fatalf("Expected the recently deleted chirp to be kept, got %v", err)
+	}
+	if chirps, _ := m.GetChirpsByCreation(ctx); len(chirps) != 1 {
+		t.Fatalf("Expected only the live chirp to be listed, got %d", len(chirps))
+	}
+}
+
+func TestMemoryModerationCases(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	author, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+	moderator, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "m@example.com"})
+	chirp, _ := m.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: author.ID})
+
+	first, err := m.OpenModerationCase(ctx, chirp.ID)
+	if err != nil {
+		t.Fatalf("Error opening case: %v", err)
+	}
+	if again, _ := m.OpenModerationCase(ctx, chirp.ID); again.ID != first.ID {
+		t.Fatalf("Expected the open case %s to be reused, got %s", first.ID, again.ID)
+	}
+
+	mod := uuid.NullUUID{UUID: moderator.ID, Valid: true}
+	resolve := database.ResolveModerationCaseParams{ID: first.ID, ResolvedBy: mod, Resolution: sql.NullString{String: "dismiss", Valid: true}}
+	if _, err := m.ResolveModerationCase(ctx, resolve); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected an unclaimed case not to resolve, got %v", err)
+	}
+	m.ClaimModerationCase(ctx, database.ClaimModerationCaseParams{ID: first.ID, ClaimedBy: mod})
+	if _, err := m.ResolveModerationCase(ctx, resolve); err != nil {
+		t.Fatalf("Error resolving case: %v", err)
+	}
+
+	next, _ := m.OpenModerationCase(ctx, chirp.ID)
+	if next.ID == first.ID || next.Status != "open" {
+		t.Fatalf("Expected a new case once the first was resolved, got %+v", next)
+	}
+}
+
+func TestMemoryHiddenUserIds(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	alice, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+	bob, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "b@example.com"})
+
+	if err := m.BlockUser(ctx, database.BlockUserParams{BlockerID: alice.ID, BlockedID: alice.ID}); err == nil {
+		t.Fatalf("Expected a user blocking themselves to be rejected")
+	}
+	m.BlockUser(ctx, database.BlockUserParams{BlockerID: alice.ID, BlockedID: bob.ID})
+	m.MuteUser(ctx, database.MuteUserParams{MuterID: alice.ID, MutedID: bob.ID})
+
+	hidden, _ := m.GetHiddenUserIds(ctx, alice.ID)
+	if len(hidden) != 1 || hidden[0] != bob.ID {
+		t.Fatalf("Expected Bob hidden once, got %v", hidden)
+	}
+	if hidden, _ := m.GetHiddenUserIds(ctx, bob.ID); len(hidden) != 0 {
+		t.Fatalf("Expected blocks to be one-way, got %v", hidden)
+	}
+}
+
+func TestMemoryMediaDetachedOnPurge(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	m.Now = func() time.Time { return now }
+	user, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+	chirp, _ := m.CreateChirp(ctx, database.CreateChirpParams{Body: "look", UserID: user.ID})
+	upload, _ := m.CreateMediaAttachment(ctx, database.CreateMediaAttachmentParams{ID: uuid.New(), UserID: user.ID, StorageKey: "a.png", ThumbnailKey: "a_thumb.png"})
+
+	attach := database.AttachMediaParams{
+		ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		Position: sql.NullInt32{Int32: 0, Valid: true},
+		ID:       upload.ID,
+		UserID:   user.ID,
+	}
+	if _, err := m.AttachMedia(ctx, attach); err != nil {
+		t.Fatalf("Error attaching media: %v", err)
+	}
+	if _, err := m.AttachMedia(ctx, attach); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected attached media not to be attached again, got %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	if rows, _ := m.DeleteUnattachedMedia(ctx, now); len(rows) != 0 {
+		t.Fatalf("Expected attached media to be kept, got %+v", rows)
+	}
+	m.DeleteChirpByAuthor(ctx, database.DeleteChirpByAuthorParams{ID: chirp.ID, UserID: user.ID})
+	m.PurgeDeletedChirps(ctx, sql.NullTime{Time: now.Add(time.Minute), Valid: true})
+	rows, _ := m.DeleteUnattachedMedia(ctx, now)
+	if len(rows) != 1 || rows[0].StorageKey != "a.png" {
+		t.Fatalf("Expected media of a purged chirp to be deleted, got %+v", rows)
+	}
+}
+
+func TestMemoryRechirpsAndQuotesOnPurge(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	m.Now = func() time.Time { return now }
+	user, _ := m.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+	chirp, _ := m.CreateChirp(ctx, database.CreateChirpParams{Body: "original", UserID: user.ID})
+	quote, err := m.CreateChirp(ctx, database.CreateChirpParams{Body: "quote", UserID: user.ID, QuotedChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true}})
+	if err != nil {
+		t.Fatalf("Error creating quote: %v", err)
+	}
+
+	rechirp := database.CreateRechirpParams{UserID: user.ID, ChirpID: chirp.ID}
+	if _, err := m.CreateRechirp(ctx, rechirp); err != nil {
+		t.Fatalf("Error rechirping: %v", err)
+	}
+	if _, err := m.CreateRechirp(ctx, rechirp); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected a second rechirp to be ignored, got %v", err)
+	}
+
+	m.DeleteChirpByAuthor(ctx, database.DeleteChirpByAuthorParams{ID: chirp.ID, UserID: user.ID})
+	if rechirps, _ := m.GetRechirps(ctx); len(rechirps) != 0 {
+		t.Fatalf("Expected rechirps of deleted chirps to be hidden, got %+v", rechirps)
+	}
+	m.PurgeDeletedChirps(ctx, sql.NullTime{Time: now.Add(time.Minute), Valid: true})
+	if version, _ := m.GetRechirpsVersion(ctx); version.RechirpCount != 0 {
+		t.Fatalf("Expected rechirps of purged chirps to be deleted, got %d", version.RechirpCount)
+	}
+	if got, _ := m.GetChirpById(ctx, quote.ID); got.QuotedChirpID.Valid {
+		t.Fatalf("Expected the quote to lose its purged original, got %+v", got.QuotedChirpID)
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+const (
+	txAttempts = 3
+	txBackoff  = 10 * time.Millisecond
+)
+
+// SQL is the Postgres Store: the generated queries plus transactions.
+type SQL struct {
+	*database.Queries
+	db   *sql.DB
+	wrap func(database.DBTX) database.DBTX
+	tx   bool
+}
+
+var _ Store = (*SQL)(nil)
+
+// NewSQL builds the store on db. wrap, when set, decorates the connection and
+// every transaction the same way, so metrics and tracing also see queries run
+// inside InTx.
+func NewSQL(db *sql.DB, wrap func(database.DBTX) database.DBTX) *SQL {
+	if wrap == nil {
+		wrap = func(conn database.DBTX) database.DBTX { return conn }
+	}
+	return &SQL{Queries: database.New(wrap(db)), db: db, wrap: wrap}
+}
+
+// InTx runs fn in a serializable transaction, retrying the whole function
+// when Postgres aborts it with a serialization failure or deadlock. fn may
+// therefore run more than once and must not have side effects outside s.
+// Calling InTx on the Store handed to fn reuses the open transaction.
+func (s *SQL) InTx(ctx context.Context, fn func(Store) error) error {
+	if s.tx {
+		return fn(s)
+	}
+
+	var err error
+	for attempt := 1; attempt <= txAttempts; attempt++ {
+		err = s.runTx(ctx, fn)
+		if !retryable(err) || attempt == txAttempts {
+			break
+		}
+		// jitter so the transactions that collided don't collide again
+		backoff := time.Duration(attempt) * txBackoff
+		select {
+		case <-time.After(backoff + rand.N(backoff)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+func (s *SQL) runTx(ctx context.Context, fn func(Store) error) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+	// WithTx would bind the queries to the bare *sql.Tx and skip wrap
+	q := database.New(s.wrap(tx))
+	if err := fn(&SQL{Queries: q, db: s.db, wrap: s.wrap, tx: true}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// retryable reports whether err aborted a transaction that may succeed if
+// simply run again.
+func retryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code.Name() {
+	case "serialization_failure", "deadlock_detected":
+		return true
+	}
+	return false
+}
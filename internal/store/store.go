@@ -0,0 +1,214 @@
+// Package store defines the persistence the HTTP handlers depend on. SQL
+// implements it against Postgres with the sqlc-generated queries and Memory
+// implements it in process for tests.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/database"
+)
+
+type Users interface {
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	GetUserByEmail(ctx context.Context, email string) (database.User, error)
+	GetUserById(ctx context.Context, id uuid.UUID) (database.User, error)
+	GetUserByUsername(ctx context.Context, username sql.NullString) (database.User, error)
+	GetUserIdsByUsernames(ctx context.Context, usernames []string) ([]uuid.UUID, error)
+	UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error)
+	UpdateUserIsChirpyRed(ctx context.Context, arg database.UpdateUserIsChirpyRedParams) (database.User, error)
+	SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error)
+	SetUserDisabled(ctx context.Context, arg database.SetUserDisabledParams) (database.User, error)
+	SetUsername(ctx context.Context, arg database.SetUsernameParams) (database.User, error)
+	CountUsersWithRole(ctx context.Context, role string) (int64, error)
+	ResetUsers(ctx context.Context) error
+}
+
+type Chirps interface {
+	CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error)
+	GetChirpById(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	GetChirpByIdWithDeleted(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	GetChirpsByCreation(ctx context.Context) ([]database.Chirp, error)
+	GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error)
+	GetChirpsByIds(ctx context.Context, ids []uuid.UUID) ([]database.Chirp, error)
+	CountQuotesByChirpIds(ctx context.Context, quotedChirpIds []uuid.UUID) ([]database.CountQuotesByChirpIdsRow, error)
+	GetChirpsVersion(ctx context.Context) (database.GetChirpsVersionRow, error)
+	DeleteChirp(ctx context.Context, arg database.DeleteChirpParams) (database.Chirp, error)
+	DeleteChirpByAuthor(ctx context.Context, arg database.DeleteChirpByAuthorParams) (database.Chirp, error)
+	RestoreChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	TakeDownChirp(ctx context.Context, arg database.TakeDownChirpParams) (database.Chirp, error)
+	ReinstateChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	PurgeDeletedChirps(ctx context.Context, deletedAt sql.NullTime) (int64, error)
+	TouchChirpCounts(ctx context.Context, id uuid.UUID) error
+}
+
+type RefreshTokens interface {
+	CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error)
+	GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, token string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+type PageViews interface {
+	IncrementPageViews(ctx context.Context, arg database.IncrementPageViewsParams) error
+	GetTotalPageViews(ctx context.Context) (int64, error)
+	GetPageViewsByDay(ctx context.Context, arg database.GetPageViewsByDayParams) ([]database.GetPageViewsByDayRow, error)
+	GetTopPages(ctx context.Context, arg database.GetTopPagesParams) ([]database.GetTopPagesRow, error)
+	ResetPageViews(ctx context.Context) error
+}
+
+type WebhookEvents interface {
+	CreateWebhookEvent(ctx context.Context, arg database.CreateWebhookEventParams) (database.WebhookEvent, error)
+	GetRecentWebhookEvents(ctx context.Context, limit int32) ([]database.WebhookEvent, error)
+}
+
+// Rechirps are reposts. The listing queries leave out rechirps of deleted
+// chirps.
+type Rechirps interface {
+	CreateRechirp(ctx context.Context, arg database.CreateRechirpParams) (database.Rechirp, error)
+	GetRechirp(ctx context.Context, arg database.GetRechirpParams) (database.Rechirp, error)
+	DeleteRechirp(ctx context.Context, arg database.DeleteRechirpParams) (int64, error)
+	GetRechirps(ctx context.Context) ([]database.Rechirp, error)
+	GetRechirpsByUserId(ctx context.Context, userID uuid.UUID) ([]database.Rechirp, error)
+	CountRechirpsByChirpIds(ctx context.Context, chirpIds []uuid.UUID) ([]database.CountRechirpsByChirpIdsRow, error)
+	GetRechirpsVersion(ctx context.Context) (database.GetRechirpsVersionRow, error)
+}
+
+// Likes are one per user and chirp.
+type Likes interface {
+	CreateLike(ctx context.Context, arg database.CreateLikeParams) (database.Like, error)
+	GetLike(ctx context.Context, arg database.GetLikeParams) (database.Like, error)
+	DeleteLike(ctx context.Context, arg database.DeleteLikeParams) (int64, error)
+	CountLikesByChirpIds(ctx context.Context, chirpIds []uuid.UUID) ([]database.CountLikesByChirpIdsRow, error)
+	GetLikesVersion(ctx context.Context) (database.GetLikesVersionRow, error)
+}
+
+// Notifications are queued as events by the write paths and fanned out
+// later. TakeNotificationEvents removes what it returns, so the notifications
+// must be created in the same transaction.
+type Notifications interface {
+	EnqueueNotificationEvent(ctx context.Context, arg database.EnqueueNotificationEventParams) error
+	TakeNotificationEvents(ctx context.Context, limit int32) ([]database.NotificationEvent, error)
+	CreateNotification(ctx context.Context, arg database.CreateNotificationParams) error
+	GetNotificationsByUserId(ctx context.Context, arg database.GetNotificationsByUserIdParams) ([]database.Notification, error)
+	CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error)
+	MarkNotificationsRead(ctx context.Context, arg database.MarkNotificationsReadParams) (int64, error)
+	MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error)
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error)
+	SetNotificationPreference(ctx context.Context, arg database.SetNotificationPreferenceParams) error
+}
+
+// ScheduledChirps wait to be published. TakeDueScheduledChirps removes the
+// ones due by the time it is given, so they must be created as chirps in the
+// same transaction.
+type ScheduledChirps interface {
+	CreateScheduledChirp(ctx context.Context, arg database.CreateScheduledChirpParams) (database.ScheduledChirp, error)
+	CountScheduledChirpsByUserId(ctx context.Context, userID uuid.UUID) (int64, error)
+	GetScheduledChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]database.ScheduledChirp, error)
+	GetScheduledChirp(ctx context.Context, arg database.GetScheduledChirpParams) (database.ScheduledChirp, error)
+	UpdateScheduledChirp(ctx context.Context, arg database.UpdateScheduledChirpParams) (database.ScheduledChirp, error)
+	DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error)
+	TakeDueScheduledChirps(ctx context.Context, arg database.TakeDueScheduledChirpsParams) ([]database.ScheduledChirp, error)
+}
+
+// Drafts are private chirp bodies. UpdateDraft only matches the updated_at
+// the caller last saw, so a stale edit finds no row.
+type Drafts interface {
+	CreateDraft(ctx context.Context, arg database.CreateDraftParams) (database.Draft, error)
+	GetDraftsByUserId(ctx context.Context, userID uuid.UUID) ([]database.Draft, error)
+	GetDraft(ctx context.Context, arg database.GetDraftParams) (database.Draft, error)
+	UpdateDraft(ctx context.Context, arg database.UpdateDraftParams) (database.Draft, error)
+	DeleteDraft(ctx context.Context, arg database.DeleteDraftParams) (database.Draft, error)
+}
+
+// Media are uploaded images, attached to a chirp once it is posted.
+type Media interface {
+	CreateMediaAttachment(ctx context.Context, arg database.CreateMediaAttachmentParams) (database.MediaAttachment, error)
+	AttachMedia(ctx context.Context, arg database.AttachMediaParams) (database.MediaAttachment, error)
+	GetMediaByChirpIds(ctx context.Context, chirpIds []uuid.UUID) ([]database.MediaAttachment, error)
+	DeleteUnattachedMedia(ctx context.Context, createdAt time.Time) ([]database.DeleteUnattachedMediaRow, error)
+}
+
+// LinkPreviews are the fetched metadata of pages linked from chirps. Pending
+// rows double as the fetch queue; ClaimLinkPreviews leases due rows to one
+// worker until ClaimedUntil.
+type LinkPreviews interface {
+	EnqueueLinkPreview(ctx context.Context, url string) error
+	ClaimLinkPreviews(ctx context.Context, arg database.ClaimLinkPreviewsParams) ([]database.LinkPreview, error)
+	CompleteLinkPreview(ctx context.Context, arg database.CompleteLinkPreviewParams) error
+	FailLinkPreview(ctx context.Context, arg database.FailLinkPreviewParams) error
+	GetLinkPreviewsByUrls(ctx context.Context, urls []string) ([]database.LinkPreview, error)
+	GetLinkPreviewsVersion(ctx context.Context) (time.Time, error)
+}
+
+// Relationships are the follows, blocks and mutes users put on each other.
+type Relationships interface {
+	BlockUser(ctx context.Context, arg database.BlockUserParams) error
+	UnblockUser(ctx context.Context, arg database.UnblockUserParams) error
+	GetBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]database.UserBlock, error)
+	IsUserBlocked(ctx context.Context, arg database.IsUserBlockedParams) (bool, error)
+	MuteUser(ctx context.Context, arg database.MuteUserParams) error
+	UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) error
+	GetMutedUsers(ctx context.Context, muterID uuid.UUID) ([]database.UserMute, error)
+	GetHiddenUserIds(ctx context.Context, blockerID uuid.UUID) ([]uuid.UUID, error)
+	FollowUser(ctx context.Context, arg database.FollowUserParams) (int64, error)
+	UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) error
+	GetFollowedUsers(ctx context.Context, followerID uuid.UUID) ([]database.UserFollow, error)
+	IsUserFollowed(ctx context.Context, arg database.IsUserFollowedParams) (bool, error)
+}
+
+// Moderation covers chirp reports, the review queue built from them and the
+// log of what moderators did.
+type Moderation interface {
+	CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error)
+	GetReportByReporter(ctx context.Context, arg database.GetReportByReporterParams) (database.Report, error)
+	GetReportsByCase(ctx context.Context, caseID uuid.UUID) ([]database.Report, error)
+	OpenModerationCase(ctx context.Context, chirpID uuid.UUID) (database.ModerationCase, error)
+	IncrementCaseReportCount(ctx context.Context, id uuid.UUID) (database.ModerationCase, error)
+	GetModerationCase(ctx context.Context, id uuid.UUID) (database.ModerationCase, error)
+	ListModerationCases(ctx context.Context, arg database.ListModerationCasesParams) ([]database.ListModerationCasesRow, error)
+	ClaimModerationCase(ctx context.Context, arg database.ClaimModerationCaseParams) (database.ModerationCase, error)
+	ResolveModerationCase(ctx context.Context, arg database.ResolveModerationCaseParams) (database.ModerationCase, error)
+	CreateModerationAction(ctx context.Context, arg database.CreateModerationActionParams) (database.ModerationAction, error)
+	GetModerationActions(ctx context.Context, limit int32) ([]database.ModerationAction, error)
+}
+
+// Stats backs the admin dashboard.
+type Stats interface {
+	CountUsers(ctx context.Context) (int64, error)
+	CountChirpyRedUsers(ctx context.Context) (int64, error)
+	CountActiveSessions(ctx context.Context) (int64, error)
+	CountChirpsPerDay(ctx context.Context, createdAt time.Time) ([]database.CountChirpsPerDayRow, error)
+	GetTopPosters(ctx context.Context, limit int32) ([]database.GetTopPostersRow, error)
+}
+
+// Queries is every query, as generated by sqlc.
+type Queries interface {
+	Users
+	Chirps
+	Rechirps
+	Likes
+	Notifications
+	ScheduledChirps
+	Drafts
+	Media
+	LinkPreviews
+	RefreshTokens
+	PageViews
+	WebhookEvents
+	Relationships
+	Moderation
+	Stats
+}
+
+var _ Queries = (*database.Queries)(nil)
+
+type Store interface {
+	Queries
+	// InTx runs fn atomically against the Store it is given. If fn returns
+	// an error nothing it did is kept. fn may be called more than once.
+	InTx(ctx context.Context, fn func(Store) error) error
+}
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return recorder
+}
+
+type fakeDB struct {
+	sawSpan bool
+}
+
+func (f *fakeDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	f.sawSpan = trace.SpanFromContext(ctx).SpanContext().IsValid()
+	return nil, nil
+}
+
+func (f *fakeDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, nil
+}
+
+func (f *fakeDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, nil
+}
+
+func (f *fakeDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpId}", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/chirps/123", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	Middleware(mux).ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name() != "GET /api/chirps/{chirpId}" {
+		t.Fatalf("Expected span named after route pattern, got %q", spans[0].Name())
+	}
+	if got := spans[0].SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("Expected incoming trace ID to be continued, got %s", got)
+	}
+	if req.Pattern != "GET /api/chirps/{chirpId}" {
+		t.Fatalf("Expected route pattern copied back to the request, got %q", req.Pattern)
+	}
+}
+
+func TestInstrumentDBCreatesChildSpan(t *testing.T) {
+	recorder := setupRecorder(t)
+	fake := &fakeDB{}
+	db := InstrumentDB(fake)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	db.ExecContext(ctx, "-- name: DeleteChirp :exec\nDELETE FROM chirps WHERE id = $1", 1)
+	parent.End()
+
+	if !fake.sawSpan {
+		t.Fatalf("Expected query to run with a span in its context")
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "DeleteChirp" {
+		t.Fatalf("Expected a DeleteChirp span followed by the parent, got %v", spans)
+	}
+	if spans[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("Expected query span to be a child of the request span")
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/notify"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+var likeAction = chirpAction{
+	name:         "like",
+	done:         "liked",
+	notification: notify.Like,
+	create: func(ctx context.Context, q store.Store, userId, chirpId uuid.UUID) (any, error) {
+		return q.CreateLike(ctx, database.CreateLikeParams{UserID: userId, ChirpID: chirpId})
+	},
+	get: func(ctx context.Context, q store.Store, userId, chirpId uuid.UUID) (any, error) {
+		return q.GetLike(ctx, database.GetLikeParams{UserID: userId, ChirpID: chirpId})
+	},
+	undo: func(ctx context.Context, q store.Store, userId, chirpId uuid.UUID) (int64, error) {
+		return q.DeleteLike(ctx, database.DeleteLikeParams{UserID: userId, ChirpID: chirpId})
+	},
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/linkpreview"
+)
+
+type linkPreviewResponse struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url,omitempty"`
+}
+
+// withAttachments fills in everything a chirp response carries besides the
+// chirp itself.
+func (a *apiConfig) withAttachments(ctx context.Context, chirps []chirpResponse) error {
+	if err := a.withShares(ctx, chirps); err != nil {
+		return err
+	}
+	return a.withMediaAndLinks(ctx, chirps)
+}
+
+func (a *apiConfig) withMediaAndLinks(ctx context.Context, chirps []chirpResponse) error {
+	if err := a.withMedia(ctx, chirps); err != nil {
+		return err
+	}
+	return a.withLinkPreviews(ctx, chirps)
+}
+
+// withLinkPreviews adds the preview of each chirp's first link once it has
+// been fetched. Taken down chirps don't get one.
+func (a *apiConfig) withLinkPreviews(ctx context.Context, chirps []chirpResponse) error {
+	links := make(map[int]string)
+	var urls []string
+	for i, c := range chirps {
+		if c.TakenDown {
+			continue
+		}
+		if found := linkpreview.FindURLs(c.Body); len(found) > 0 {
+			links[i] = found[0]
+			urls = append(urls, found[0])
+		}
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+
+	previews, err := a.dbQueries.GetLinkPreviewsByUrls(ctx, urls)
+	if err != nil {
+		return err
+	}
+	byURL := make(map[string]database.LinkPreview, len(previews))
+	for _, p := range previews {
+		byURL[p.Url] = p
+	}
+	for i, url := range links {
+		if p, ok := byURL[url]; ok {
+			chirps[i].LinkPreview = &linkPreviewResponse{
+				URL:         p.Url,
+				Title:       p.Title,
+				Description: p.Description,
+				ImageURL:    p.ImageUrl,
+			}
+		}
+	}
+	return nil
+}
@@ -1,82 +1,242 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/jonvanw/chirpy/internal/compression"
+	"github.com/jonvanw/chirpy/internal/config"
 	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/linkpreview"
+	"github.com/jonvanw/chirpy/internal/logging"
+	"github.com/jonvanw/chirpy/internal/media"
+	"github.com/jonvanw/chirpy/internal/metrics"
+	"github.com/jonvanw/chirpy/internal/notify"
+	"github.com/jonvanw/chirpy/internal/pageviews"
+	"github.com/jonvanw/chirpy/internal/ratelimit"
+	"github.com/jonvanw/chirpy/internal/static"
+	"github.com/jonvanw/chirpy/internal/store"
+	"github.com/jonvanw/chirpy/internal/tracing"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 func main() {
-	const port ="8080"
-	const appPrefix = "/app/"
-
 	godotenv.Load()
-	dbUrl := os.Getenv("DB_URL")
+	os.Exit(runCLI(os.Args[1:]))
+}
 
-	db, err := sql.Open("postgres", dbUrl)
+// runServe runs the HTTP server until SIGINT or SIGTERM.
+func runServe(args []string) int {
+	cfg, err := config.Load(args)
 	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
-	appConfig := apiConfig{
-		dbQueries: database.New(db),
-		platform: os.Getenv("PLATFORM"),
-		jwtAuthSecret: os.Getenv("JWT_AUTH_SECRET"),
-		pokaApiKey: os.Getenv("POLKA_KEY"),
+		slog.Error("invalid configuration", "err", err)
+		return 1
 	}
 
-	mux := http.NewServeMux()
-	server := http.Server{
-		Handler: mux,
-		Addr:    ":" + port,
+	logLevel, _ := logging.ParseLevel(cfg.Log.Level) // already checked by config.Validate
+	logger := logging.New(os.Stderr, logging.Options{
+		Level:  logLevel,
+		Format: cfg.Log.Format,
+		Redact: cfg.Log.Redact,
+	})
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		slog.Error("failed to set up tracing", "err", err)
+		return 1
 	}
-	
-
-	fileServerHandler := http.StripPrefix(appPrefix, http.FileServer(http.Dir(".")))
-	mux.Handle(appPrefix, appConfig.middlewareMetricsInc(fileServerHandler))
-
-	mux.HandleFunc("GET /admin/metrics", appConfig.handlerMetrics)
-
-	mux.HandleFunc("POST /admin/reset", appConfig.handlerReset)
 
-	mux.HandleFunc("GET /api/healthz", readinessHandler)
-
-	mux.HandleFunc("POST /api/chirps", appConfig.handleAddChirp)
-
-	mux.HandleFunc("GET /api/chirps", appConfig.handlerGetChirps)
-
-	mux.HandleFunc("GET /api/chirps/{chirpId}", appConfig.handlerGetChirpById)
-
-	mux.HandleFunc("DELETE /api/chirps/{chirpId}", appConfig.handlerDeleteChirp)
+	db, err := sql.Open("postgres", cfg.Database.URL)
+	if err != nil {
+		slog.Error("failed to open database", "err", err)
+		return 1
+	}
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
 
-	mux.HandleFunc("POST /api/users", appConfig.handleAddUser)
+	migrator, err := newMigrator(db)
+	if err != nil {
+		slog.Error("failed to load migrations", "err", err)
+		return 1
+	}
+	if err := migrateOnStart(context.Background(), migrator, cfg.Database.MigrateOnStart); err != nil {
+		slog.Error("database schema is not ready, run `chirpy migrate up` or start with -migrate", "err", err)
+		return 1
+	}
 
-	mux.HandleFunc("PUT /api/users", appConfig.handleUpdateUser)
+	mediaStorage, err := media.NewLocalStorage(cfg.Media.Dir, cfg.Media.BaseURL)
+	if err != nil {
+		slog.Error("failed to set up media storage", "err", err)
+		return 1
+	}
 
-	mux.HandleFunc("POST /api/login", appConfig.handleLogin)
+	appMetrics := metrics.New()
+
+	appConfig := &apiConfig{
+		dbQueries: store.NewSQL(db, func(conn database.DBTX) database.DBTX {
+			return appMetrics.InstrumentDB(tracing.InstrumentDB(conn))
+		}),
+		platform: cfg.Platform,
+		jwtAuthSecret: cfg.Auth.JWTSecret,
+		jwtDuration: cfg.Auth.JWTDuration,
+		refreshTokenTTL: cfg.Auth.RefreshTokenTTL,
+		chirpRestoreWindow: cfg.Chirps.RestoreWindow,
+		scheduledLimit: cfg.Chirps.ScheduledLimit,
+		autoHideThreshold: cfg.Moderation.AutoHideThreshold,
+		mediaStorage: mediaStorage,
+		mediaLimits: media.Limits{
+			MaxPixels: cfg.Media.MaxPixels,
+			MaxFrames: cfg.Media.MaxFrames,
+			MaxTotalPixels: cfg.Media.MaxTotalPixels,
+			ThumbnailSize: cfg.Media.ThumbnailSize,
+		},
+		maxUploadBytes: cfg.Media.MaxUploadBytes,
+		pokaApiKey: cfg.Polka.APIKey,
+		rateLimiter: ratelimit.NewMemoryStore(),
+		readiness: &readinessChecker{
+			ping: db.PingContext,
+			schemaVersion: func(ctx context.Context) (int64, error) {
+				return currentSchemaVersion(ctx, db)
+			},
+			expected: migrator.Latest(),
+		},
+		metrics: appMetrics,
+	}
+	appConfig.pageViews = pageviews.NewCounter(appConfig.dbQueries)
+	appConfig.notifications = notify.NewWorker(appConfig.dbQueries)
+	if cfg.Links.PreviewsEnabled {
+		fetcher := linkpreview.NewFetcher(linkpreview.Options{
+			Timeout:  cfg.Links.FetchTimeout,
+			MaxBytes: cfg.Links.MaxBytes,
+		})
+		appConfig.linkPreviews = linkpreview.NewWorker(appConfig.dbQueries, fetcher, cfg.Links.MaxAttempts)
+	}
+	if cfg.Bootstrap.AdminEmail != "" {
+		if err := appConfig.bootstrapAdmin(context.Background(), cfg.Bootstrap.AdminEmail, cfg.Bootstrap.AdminPassword); err != nil {
+			slog.Error("failed to bootstrap admin user", "err", err)
+		}
+	}
 
-	mux.HandleFunc("POST /api/refresh", appConfig.handleRefreshAuthToken)
+	appMetrics.RegisterCounterFunc("fileserver_hits_total", "Requests served under /app/.", func() float64 {
+		return float64(appConfig.pageViews.Total())
+	})
 
-	mux.HandleFunc("POST /api/revoke", appConfig.handleRevokeRefreshToken)
+	webRoot, err := appFS(cfg.Static.Dir)
+	if err != nil {
+		slog.Error("invalid static directory", "dir", cfg.Static.Dir, "err", err)
+		return 1
+	}
+	app := static.New(webRoot, static.Options{
+		MaxAge:      cfg.Static.MaxAge,
+		SPAFallback: cfg.Static.SPAFallback,
+	})
+
+	handler := appMetrics.Middleware(appConfig.routes(app))
+	handler = compression.Middleware(handler)
+	handler = logging.AccessLog(logger, appConfig.accessLogUserId, handler)
+	handler = tracing.Middleware(handler)
+	server := newServer(cfg, handler)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// background jobs outlive the server so buffered page views still get
+	// flushed while draining; they are stopped just before the database closes
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+	jobs.Go(func() {
+		finalCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		appConfig.pageViews.Run(jobsCtx, finalCtx, cfg.PageViews.FlushInterval)
+	})
+	if appConfig.linkPreviews != nil {
+		jobs.Go(func() {
+			appConfig.linkPreviews.Run(jobsCtx, cfg.Links.PollInterval)
+		})
+	}
+	jobs.Go(func() {
+		appConfig.notifications.Run(jobsCtx, cfg.Notify.PollInterval)
+	})
+	jobs.Go(func() {
+		appConfig.runScheduledChirps(jobsCtx, cfg.Chirps.PublishInterval)
+	})
+	jobs.Go(func() {
+		appConfig.runChirpPurge(jobsCtx, cfg.Chirps.PurgeInterval, cfg.Chirps.PurgeAfter, cfg.Media.UnattachedTTL)
+	})
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "addr", cfg.ListenAddr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server failed", "err", err)
+			exitCode = 1
+		}
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received, draining connections", "delay", cfg.Server.DrainDelay, "timeout", cfg.Server.ShutdownTimeout)
+		if err := appConfig.drain(server, cfg.Server.DrainDelay, cfg.Server.ShutdownTimeout); err != nil {
+			slog.Error("graceful shutdown failed", "err", err)
+		}
+	}
 
-	mux.HandleFunc("POST /api/polka/webhooks", appConfig.handlePolkaEvent)
+	stopJobs()
+	jobs.Wait()
 
-	log.Println("Starting server on localhost:8080")
+	if err := db.Close(); err != nil {
+		slog.Error("failed to close database", "err", err)
+	}
 
-	log.Fatal(server.ListenAndServe())
+	tracingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		slog.Error("failed to flush traces", "err", err)
+	}
+	slog.Info("Server stopped")
+	return exitCode
 }
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	dbQueries 	*database.Queries
+	pageViews *pageviews.Counter
+	dbQueries 	store.Store
 	platform string
 	jwtAuthSecret string
+	jwtDuration time.Duration
+	refreshTokenTTL time.Duration
+	chirpRestoreWindow time.Duration
+	scheduledLimit int
+	autoHideThreshold int
+	mediaStorage media.Storage
+	mediaLimits media.Limits
+	maxUploadBytes int64
+	linkPreviews *linkpreview.Worker // nil when previews are turned off
+	notifications *notify.Worker
 	pokaApiKey string
+	rateLimiter ratelimit.Store
+	draining atomic.Bool
+	readiness *readinessChecker
+	metrics *metrics.Metrics
 }
@@ -1,21 +1,48 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/jonvanw/chirpy/internal/metrics"
 )
 
+// middlewareMetricsInc counts a view once the response is known, so requests
+// for files that don't exist can't fill the page_views table with junk paths.
 func (a *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		a.fileserverHits.Add(1)
-		next.ServeHTTP(w, r)
+		rec := &metrics.StatusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.Status < http.StatusBadRequest {
+			a.pageViews.Record(r.URL.Path, time.Now())
+		}
 	})
 }
 
+// totalPageViews is every view ever recorded: what has been flushed to the
+// database plus what is still buffered in this process.
+func (a *apiConfig) totalPageViews(ctx context.Context) (int64, error) {
+	stored, err := a.dbQueries.GetTotalPageViews(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return stored + a.pageViews.Pending(), nil
+}
+
 func (a *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
+	hits, err := a.totalPageViews(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerMetrics: failed to read page views", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "text/html")
-	msg := fmt.Sprintf(metricsHtmlTemplate, a.fileserverHits.Load())
+	msg := fmt.Sprintf(metricsHtmlTemplate, hits)
 	w.Write([]byte(msg))
 }
 
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jonvanw/chirpy/internal/config"
+	"github.com/jonvanw/chirpy/internal/migrate"
+)
+
+//go:embed sql/schema/*.sql
+var embeddedMigrations embed.FS
+
+func newMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	schema, err := fs.Sub(embeddedMigrations, "sql/schema")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, schema)
+}
+
+const migrateUsage = "usage: chirpy migrate up|down|status|redo [flags]"
+
+// runMigrate implements `chirpy migrate`. It only needs the database settings,
+// so it can run before the rest of the configuration (JWT secret, Polka key)
+// has been provisioned.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	action := args[0]
+
+	cfg, err := config.Parse(args[1:])
+	if err == nil {
+		err = cfg.ValidateDatabase()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chirpy migrate: %v\n", err)
+		return 2
+	}
+
+	db, err := sql.Open("postgres", cfg.Database.URL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chirpy migrate: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	migrator, err := newMigrator(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chirpy migrate: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch action {
+	case "up":
+		var results []*migrate.Result
+		results, err = migrator.Up(ctx)
+		printMigrationResults(results)
+		if err == nil && len(results) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		var result *migrate.Result
+		result, err = migrator.Down(ctx)
+		if result != nil {
+			printMigrationResults([]*migrate.Result{result})
+		}
+	case "redo":
+		var results []*migrate.Result
+		results, err = migrator.Redo(ctx)
+		printMigrationResults(results)
+	case "status":
+		err = printMigrationStatus(ctx, migrator)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chirpy migrate %s: %v\n", action, err)
+		return 1
+	}
+	return 0
+}
+
+func printMigrationResults(results []*migrate.Result) {
+	for _, r := range results {
+		fmt.Printf("%-4s %s (%s)\n", r.Direction, path.Base(r.Source.Path), r.Duration.Round(time.Millisecond))
+	}
+}
+
+func printMigrationStatus(ctx context.Context, migrator *migrate.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tMIGRATION")
+	for _, s := range statuses {
+		appliedAt := "-"
+		if !s.AppliedAt.IsZero() {
+			appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, path.Base(s.Source.Path))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\ndatabase version %d, binary expects %d\n", version, migrator.Latest())
+	return nil
+}
+
+// migrateOnStart applies pending migrations when configured to, then refuses
+// to serve against a schema older than the embedded migrations.
+func migrateOnStart(ctx context.Context, migrator *migrate.Migrator, apply bool) error {
+	if apply {
+		results, err := migrator.Up(ctx)
+		for _, r := range results {
+			slog.Info("applied migration", "version", r.Source.Version, "migration", path.Base(r.Source.Path), "duration", r.Duration)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	version, err := migrator.Check(ctx)
+	if err != nil {
+		return err
+	}
+	if version > migrator.Latest() {
+		slog.Warn("database schema is newer than this binary", "version", version, "expected", migrator.Latest())
+	}
+	return nil
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/auth"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "misinformation", "other"}
+
+const (
+	caseOpen     = "open"
+	caseClaimed  = "claimed"
+	caseResolved = "resolved"
+
+	resolutionRemove  = "remove"
+	resolutionWarn    = "warn"
+	resolutionDismiss = "dismiss"
+
+	// actions in the moderation log besides the resolutions above
+	actionAutoHide  = "auto_hide"
+	actionClaim     = "claim"
+	actionTakeDown  = "takedown"
+	actionReinstate = "reinstate"
+	actionDelete    = "delete"
+
+	maxReportDetailsLength = 1000
+	moderationDefaultLimit = 50
+	moderationMaxLimit     = 200
+)
+
+var (
+	errOwnChirp         = errors.New("cannot report own chirp")
+	errAlreadyReported  = errors.New("chirp already reported by this user")
+	errCaseNotClaimable = errors.New("case is claimed by someone else or resolved")
+	errCaseNotClaimed   = errors.New("case is not claimed by this moderator")
+)
+
+type reportResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Reason    string    `json:"reason"`
+	Details   string    `json:"details,omitempty"`
+}
+
+func newReportResponse(report database.Report) reportResponse {
+	return reportResponse{
+		ID:        report.ID,
+		CreatedAt: report.CreatedAt,
+		ChirpID:   report.ChirpID,
+		Reason:    report.Reason,
+		Details:   report.Details.String,
+	}
+}
+
+type moderationCaseResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ChirpID     uuid.UUID  `json:"chirp_id"`
+	Status      string     `json:"status"`
+	ReportCount int32      `json:"report_count"`
+	ClaimedBy   *uuid.UUID `json:"claimed_by,omitempty"`
+	ClaimedAt   *time.Time `json:"claimed_at,omitempty"`
+	Resolution  string     `json:"resolution,omitempty"`
+	ResolvedBy  *uuid.UUID `json:"resolved_by,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	AuthorID    *uuid.UUID `json:"author_id,omitempty"`
+	ChirpBody   string     `json:"chirp_body,omitempty"`
+}
+
+func newModerationCaseResponse(c database.ModerationCase) moderationCaseResponse {
+	return moderationCaseResponse{
+		ID:          c.ID,
+		CreatedAt:   c.CreatedAt,
+		UpdatedAt:   c.UpdatedAt,
+		ChirpID:     c.ChirpID,
+		Status:      c.Status,
+		ReportCount: c.ReportCount,
+		ClaimedBy:   nullUUIDPtr(c.ClaimedBy),
+		ClaimedAt:   nullTimePtr(c.ClaimedAt),
+		Resolution:  c.Resolution.String,
+		ResolvedBy:  nullUUIDPtr(c.ResolvedBy),
+		ResolvedAt:  nullTimePtr(c.ResolvedAt),
+	}
+}
+
+// moderationChirp is a chirp as moderators see it: the original body even when
+// it has been hidden, and who removed it.
+type moderationChirp struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Body           string     `json:"body"`
+	UserID         uuid.UUID  `json:"user_id"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy      *uuid.UUID `json:"deleted_by,omitempty"`
+	TakenDownAt    *time.Time `json:"taken_down_at,omitempty"`
+	TakenDownBy    *uuid.UUID `json:"taken_down_by,omitempty"`
+	TakedownReason string     `json:"takedown_reason,omitempty"`
+}
+
+type moderationCaseDetail struct {
+	moderationCaseResponse
+	Chirp   moderationChirp  `json:"chirp"`
+	Reports []reportResponse `json:"reports"`
+}
+
+type moderationActionResponse struct {
+	ID           uuid.UUID  `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ModeratorID  *uuid.UUID `json:"moderator_id"` // null for automatic actions
+	CaseID       *uuid.UUID `json:"case_id,omitempty"`
+	ChirpID      *uuid.UUID `json:"chirp_id,omitempty"`
+	TargetUserID *uuid.UUID `json:"target_user_id,omitempty"`
+	Action       string     `json:"action"`
+	Note         string     `json:"note,omitempty"`
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func validUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+// autoHidden reports whether the chirp was hidden by the report threshold
+// rather than by a moderator; only those takedowns carry no moderator.
+func autoHidden(chirp database.Chirp) bool {
+	return chirp.TakenDownAt.Valid && !chirp.TakenDownBy.Valid
+}
+
+// logModerationAction adds an entry to the moderation log. moderatorId is
+// uuid.Nil for actions taken automatically or from the command line.
+func logModerationAction(ctx context.Context, tx store.Moderation, moderatorId uuid.UUID, caseId uuid.UUID, chirp database.Chirp, action, note string) error {
+	_, err := tx.CreateModerationAction(ctx, database.CreateModerationActionParams{
+		ModeratorID:  validUUID(moderatorId),
+		CaseID:       validUUID(caseId),
+		ChirpID:      validUUID(chirp.ID),
+		TargetUserID: validUUID(chirp.UserID),
+		Action:       action,
+		Note:         sql.NullString{String: note, Valid: note != ""},
+	})
+	return err
+}
+
+// handlerReportChirp files a report against someone else's chirp. Reports on
+// the same chirp share one open case in the review queue; once that case
+// collects autoHideThreshold reports the chirp is hidden until a moderator
+// looks at it.
+func (a *apiConfig) handlerReportChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		slog.WarnContext(r.Context(), "handlerReportChirp: failed to get bearer token", "err", err)
+		http.Error(w, "Unauthorized, no user token provided.", http.StatusUnauthorized)
+		return
+	}
+	userId, err := auth.ValidateJWT(token, a.jwtAuthSecret)
+	if err != nil || userId == uuid.Nil {
+		slog.WarnContext(r.Context(), "handlerReportChirp: failed to validate JWT", "err", err)
+		http.Error(w, "Unauthorized. Invalid user token.", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		slog.InfoContext(r.Context(), "handlerReportChirp: invalid ID parameter", "err", err)
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		slog.InfoContext(r.Context(), "handlerReportChirp: failed to decode request body", "err", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if !slices.Contains(reportReasons, payload.Reason) {
+		slog.InfoContext(r.Context(), "handlerReportChirp: unknown reason", "reason", payload.Reason)
+		http.Error(w, fmt.Sprintf("Unknown reason %q, expected one of %v", payload.Reason, reportReasons), http.StatusBadRequest)
+		return
+	}
+	if len(payload.Details) > maxReportDetailsLength {
+		http.Error(w, fmt.Sprintf("Details must be at most %d characters", maxReportDetailsLength), http.StatusBadRequest)
+		return
+	}
+
+	var report database.Report
+	var hidden bool
+	err = a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		chirp, err := tx.GetChirpById(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if chirp.UserID == userId {
+			return errOwnChirp
+		}
+		_, err = tx.GetReportByReporter(r.Context(), database.GetReportByReporterParams{ChirpID: id, ReporterID: userId})
+		if err == nil {
+			return errAlreadyReported
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		modCase, err := tx.OpenModerationCase(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		report, err = tx.CreateReport(r.Context(), database.CreateReportParams{
+			CaseID:     modCase.ID,
+			ChirpID:    id,
+			ReporterID: userId,
+			Reason:     payload.Reason,
+			Details:    sql.NullString{String: payload.Details, Valid: payload.Details != ""},
+		})
+		if err != nil {
+			return err
+		}
+		if modCase, err = tx.IncrementCaseReportCount(r.Context(), modCase.ID); err != nil {
+			return err
+		}
+
+		hidden = false // the function may be retried
+		if a.autoHideThreshold <= 0 || int(modCase.ReportCount) < a.autoHideThreshold || chirp.TakenDownAt.Valid {
+			return nil
+		}
+		note := fmt.Sprintf("Hidden pending review after %d reports", modCase.ReportCount)
+		if chirp, err = tx.TakeDownChirp(r.Context(), database.TakeDownChirpParams{
+			ID:             id,
+			TakedownReason: sql.NullString{String: note, Valid: true},
+		}); err != nil {
+			return err
+		}
+		hidden = true
+		return logModerationAction(r.Context(), tx, uuid.Nil, modCase.ID, chirp, actionAutoHide, note)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			slog.InfoContext(r.Context(), "handlerReportChirp: chirp not found", "chirp_id", id)
+			http.Error(w, fmt.Sprintf("Chirp with ID %s not found", id.String()), http.StatusNotFound)
+		case errors.Is(err, errOwnChirp):
+			http.Error(w, "You can't report your own chirp", http.StatusBadRequest)
+		case errors.Is(err, errAlreadyReported):
+			http.Error(w, "You have already reported this chirp", http.StatusConflict)
+		default:
+			slog.ErrorContext(r.Context(), "handlerReportChirp: failed to report chirp", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	slog.InfoContext(r.Context(), "handlerReportChirp: chirp reported", "chirp_id", id, "reporter_id", userId, "reason", report.Reason)
+	if hidden {
+		slog.WarnContext(r.Context(), "handlerReportChirp: chirp hidden pending review", "chirp_id", id, "case_id", report.CaseID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newReportResponse(report))
+}
+
+// handlerListModerationCases lists the cases with the given status, open by
+// default, most reported first.
+func (a *apiConfig) handlerListModerationCases(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = caseOpen
+	}
+	if !slices.Contains([]string{caseOpen, caseClaimed, caseResolved}, status) {
+		http.Error(w, fmt.Sprintf("Unknown status %q", status), http.StatusBadRequest)
+		return
+	}
+	limit, ok := moderationLimit(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := a.dbQueries.ListModerationCases(r.Context(), database.ListModerationCasesParams{Status: status, Limit: limit})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerListModerationCases: failed to list cases", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]moderationCaseResponse, len(rows))
+	for i, row := range rows {
+		res[i] = newModerationCaseResponse(database.ModerationCase{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			ChirpID:     row.ChirpID,
+			Status:      row.Status,
+			ReportCount: row.ReportCount,
+			ClaimedBy:   row.ClaimedBy,
+			ClaimedAt:   row.ClaimedAt,
+			Resolution:  row.Resolution,
+			ResolvedBy:  row.ResolvedBy,
+			ResolvedAt:  row.ResolvedAt,
+		})
+		res[i].AuthorID = &row.AuthorID
+		res[i].ChirpBody = row.ChirpBody
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (a *apiConfig) handlerGetModerationCase(w http.ResponseWriter, r *http.Request) {
+	id, ok := caseIdParam(w, r, "handlerGetModerationCase")
+	if !ok {
+		return
+	}
+
+	var res moderationCaseDetail
+	modCase, err := a.dbQueries.GetModerationCase(r.Context(), id)
+	if err != nil {
+		a.moderationCaseFailed(w, r, "handlerGetModerationCase", id, err)
+		return
+	}
+	chirp, err := a.dbQueries.GetChirpByIdWithDeleted(r.Context(), modCase.ChirpID)
+	if err != nil {
+		a.moderationCaseFailed(w, r, "handlerGetModerationCase", id, err)
+		return
+	}
+	reports, err := a.dbQueries.GetReportsByCase(r.Context(), id)
+	if err != nil {
+		a.moderationCaseFailed(w, r, "handlerGetModerationCase", id, err)
+		return
+	}
+
+	res.moderationCaseResponse = newModerationCaseResponse(modCase)
+	res.AuthorID = &chirp.UserID
+	res.Chirp = moderationChirp{
+		ID:             chirp.ID,
+		CreatedAt:      chirp.CreatedAt,
+		Body:           chirp.Body,
+		UserID:         chirp.UserID,
+		DeletedAt:      nullTimePtr(chirp.DeletedAt),
+		DeletedBy:      nullUUIDPtr(chirp.DeletedBy),
+		TakenDownAt:    nullTimePtr(chirp.TakenDownAt),
+		TakenDownBy:    nullUUIDPtr(chirp.TakenDownBy),
+		TakedownReason: chirp.TakedownReason.String,
+	}
+	res.Reports = make([]reportResponse, len(reports))
+	for i, report := range reports {
+		res.Reports[i] = newReportResponse(report)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+// handlerClaimModerationCase assigns an open case to the calling moderator so
+// two moderators don't review the same chirp. Claiming a case you already
+// hold is a no-op.
+func (a *apiConfig) handlerClaimModerationCase(w http.ResponseWriter, r *http.Request) {
+	id, ok := caseIdParam(w, r, "handlerClaimModerationCase")
+	if !ok {
+		return
+	}
+	moderatorId, _ := a.optionalUserId(r) // already checked by middlewareRequirePermission
+
+	var modCase database.ModerationCase
+	err := a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		before, err := tx.GetModerationCase(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		modCase, err = tx.ClaimModerationCase(r.Context(), database.ClaimModerationCaseParams{
+			ID:        id,
+			ClaimedBy: validUUID(moderatorId),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errCaseNotClaimable
+		} else if err != nil {
+			return err
+		}
+		if before.Status == caseClaimed {
+			return nil // already ours, nothing new to log
+		}
+		chirp, err := tx.GetChirpByIdWithDeleted(r.Context(), modCase.ChirpID)
+		if err != nil {
+			return err
+		}
+		return logModerationAction(r.Context(), tx, moderatorId, id, chirp, actionClaim, "")
+	})
+	if err != nil {
+		a.moderationCaseFailed(w, r, "handlerClaimModerationCase", id, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "handlerClaimModerationCase: case claimed", "case_id", id, "moderator_id", moderatorId)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newModerationCaseResponse(modCase))
+}
+
+// handlerResolveModerationCase closes a case the caller has claimed. remove
+// deletes the chirp; warn and dismiss keep it, putting it back if the report
+// threshold hid it. warn additionally records a warning against the author in
+// the moderation log.
+func (a *apiConfig) handlerResolveModerationCase(w http.ResponseWriter, r *http.Request) {
+	id, ok := caseIdParam(w, r, "handlerResolveModerationCase")
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Resolution string `json:"resolution"`
+		Note       string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		slog.InfoContext(r.Context(), "handlerResolveModerationCase: failed to decode request body", "err", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	resolutions := []string{resolutionRemove, resolutionWarn, resolutionDismiss}
+	if !slices.Contains(resolutions, payload.Resolution) {
+		http.Error(w, fmt.Sprintf("Unknown resolution %q, expected one of %v", payload.Resolution, resolutions), http.StatusBadRequest)
+		return
+	}
+	moderatorId, _ := a.optionalUserId(r) // already checked by middlewareRequirePermission
+
+	var modCase database.ModerationCase
+	err := a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		var err error
+		if _, err = tx.GetModerationCase(r.Context(), id); err != nil {
+			return err
+		}
+		modCase, err = tx.ResolveModerationCase(r.Context(), database.ResolveModerationCaseParams{
+			ID:         id,
+			ResolvedBy: validUUID(moderatorId),
+			Resolution: sql.NullString{String: payload.Resolution, Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errCaseNotClaimed
+		} else if err != nil {
+			return err
+		}
+
+		chirp, err := tx.GetChirpByIdWithDeleted(r.Context(), modCase.ChirpID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case payload.Resolution == resolutionRemove && !chirp.DeletedAt.Valid:
+			reason := payload.Note
+			if reason == "" {
+				reason = "Removed after review of reports"
+			}
+			_, err = tx.DeleteChirp(r.Context(), database.DeleteChirpParams{
+				ID:             chirp.ID,
+				DeletedBy:      validUUID(moderatorId),
+				DeletionReason: sql.NullString{String: reason, Valid: true},
+			})
+		case payload.Resolution != resolutionRemove && !chirp.DeletedAt.Valid && autoHidden(chirp):
+			_, err = tx.ReinstateChirp(r.Context(), chirp.ID)
+		}
+		if err != nil {
+			return err
+		}
+		return logModerationAction(r.Context(), tx, moderatorId, id, chirp, payload.Resolution, payload.Note)
+	})
+	if err != nil {
+		a.moderationCaseFailed(w, r, "handlerResolveModerationCase", id, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "handlerResolveModerationCase: case resolved", "case_id", id, "moderator_id", moderatorId, "resolution", payload.Resolution)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newModerationCaseResponse(modCase))
+}
+
+// handlerModerationLog returns the most recent moderation actions, newest
+// first.
+func (a *apiConfig) handlerModerationLog(w http.ResponseWriter, r *http.Request) {
+	limit, ok := moderationLimit(w, r)
+	if !ok {
+		return
+	}
+
+	actions, err := a.dbQueries.GetModerationActions(r.Context(), limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerModerationLog: failed to get moderation actions", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]moderationActionResponse, len(actions))
+	for i, action := range actions {
+		res[i] = moderationActionResponse{
+			ID:           action.ID,
+			CreatedAt:    action.CreatedAt,
+			ModeratorID:  nullUUIDPtr(action.ModeratorID),
+			CaseID:       nullUUIDPtr(action.CaseID),
+			ChirpID:      nullUUIDPtr(action.ChirpID),
+			TargetUserID: nullUUIDPtr(action.TargetUserID),
+			Action:       action.Action,
+			Note:         action.Note.String,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+func caseIdParam(w http.ResponseWriter, r *http.Request, handler string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("caseId"))
+	if err != nil {
+		slog.InfoContext(r.Context(), handler+": invalid ID parameter", "err", err)
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func moderationLimit(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	text := r.URL.Query().Get("limit")
+	if text == "" {
+		return moderationDefaultLimit, true
+	}
+	limit, err := strconv.Atoi(text)
+	if err != nil || limit < 1 || limit > moderationMaxLimit {
+		http.Error(w, fmt.Sprintf("Invalid limit parameter, expected 1 to %d", moderationMaxLimit), http.StatusBadRequest)
+		return 0, false
+	}
+	return int32(limit), true
+}
+
+func (a *apiConfig) moderationCaseFailed(w http.ResponseWriter, r *http.Request, handler string, id uuid.UUID, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		slog.InfoContext(r.Context(), handler+": case not found", "case_id", id)
+		http.Error(w, fmt.Sprintf("Case with ID %s not found", id.String()), http.StatusNotFound)
+	case errors.Is(err, errCaseNotClaimable):
+		http.Error(w, "Case is already claimed by another moderator or resolved", http.StatusConflict)
+	case errors.Is(err, errCaseNotClaimed):
+		http.Error(w, "Claim the case before resolving it", http.StatusConflict)
+	default:
+		slog.ErrorContext(r.Context(), handler+": failed", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
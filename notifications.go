@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/notify"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+const (
+	notificationsDefaultLimit = 50
+	notificationsMaxLimit     = 200
+)
+
+type notificationResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Type      string     `json:"type"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	ChirpID   *uuid.UUID `json:"chirp_id"` // null for follows
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+func newNotificationResponse(n database.Notification) notificationResponse {
+	res := notificationResponse{
+		ID:        n.ID,
+		CreatedAt: n.CreatedAt,
+		Type:      n.Type,
+		ActorID:   n.ActorID,
+	}
+	if n.ChirpID.Valid {
+		res.ChirpID = &n.ChirpID.UUID
+	}
+	if n.ReadAt.Valid {
+		res.ReadAt = &n.ReadAt.Time
+	}
+	return res
+}
+
+type notificationsResponse struct {
+	UnreadCount   int64                  `json:"unread_count"`
+	Notifications []notificationResponse `json:"notifications"`
+}
+
+// handlerListNotifications lists the caller's latest notifications, newest
+// first, with how many are unread in all.
+func (a *apiConfig) handlerListNotifications(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerListNotifications")
+	if !ok {
+		return
+	}
+	limit := int32(notificationsDefaultLimit)
+	if text := r.URL.Query().Get("limit"); text != "" {
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 1 || n > notificationsMaxLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit parameter, expected 1 to %d", notificationsMaxLimit), http.StatusBadRequest)
+			return
+		}
+		limit = int32(n)
+	}
+
+	notifications, err := a.dbQueries.GetNotificationsByUserId(r.Context(), database.GetNotificationsByUserIdParams{UserID: userId, Limit: limit})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerListNotifications: failed to list notifications", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	unread, err := a.dbQueries.CountUnreadNotifications(r.Context(), userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerListNotifications: failed to count unread notifications", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	res := notificationsResponse{UnreadCount: unread, Notifications: []notificationResponse{}}
+	for _, n := range notifications {
+		res.Notifications = append(res.Notifications, newNotificationResponse(n))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+// handlerMarkNotificationsRead marks the listed notifications, or with all
+// set every one, as read and returns how many are still unread.
+func (a *apiConfig) handlerMarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerMarkNotificationsRead")
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		IDs []uuid.UUID `json:"ids"`
+		All bool        `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		slog.InfoContext(r.Context(), "handlerMarkNotificationsRead: failed to decode request body", "err", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if payload.All == (len(payload.IDs) > 0) {
+		http.Error(w, "Send either ids or all", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if payload.All {
+		_, err = a.dbQueries.MarkAllNotificationsRead(r.Context(), userId)
+	} else {
+		_, err = a.dbQueries.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{UserID: userId, Ids: payload.IDs})
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerMarkNotificationsRead: failed to mark notifications read", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	unread, err := a.dbQueries.CountUnreadNotifications(r.Context(), userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerMarkNotificationsRead: failed to count unread notifications", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(struct {
+		UnreadCount int64 `json:"unread_count"`
+	}{unread})
+}
+
+// handlerGetNotificationPreferences returns whether the caller wants each
+// type of notification.
+func (a *apiConfig) handlerGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerGetNotificationPreferences")
+	if !ok {
+		return
+	}
+
+	prefs, err := notify.Preferences(r.Context(), a.dbQueries, userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerGetNotificationPreferences: failed to get preferences", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// handlerUpdateNotificationPreferences turns types of notification on or
+// off. Types left out of the request keep their setting. Turning a type off
+// only stops new notifications; ones already received stay.
+func (a *apiConfig) handlerUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerUpdateNotificationPreferences")
+	if !ok {
+		return
+	}
+
+	var payload map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		slog.InfoContext(r.Context(), "handlerUpdateNotificationPreferences: failed to decode request body", "err", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	for typ := range payload {
+		if !slices.Contains(notify.Types, typ) {
+			http.Error(w, fmt.Sprintf("Unknown notification type %q", typ), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var prefs map[string]bool
+	err := a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		for typ, enabled := range payload {
+			err := tx.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{UserID: userId, Type: typ, Enabled: enabled})
+			if err != nil {
+				return err
+			}
+		}
+		var err error
+		prefs, err = notify.Preferences(r.Context(), tx, userId)
+		return err
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerUpdateNotificationPreferences: failed to update preferences", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(prefs)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/pageviews"
+)
+
+const (
+	pageViewsDefaultDays = 30
+	pageViewsMaxDays     = 366
+	pageViewsTopPaths    = 20
+)
+
+type pageViewsResponse struct {
+	From     string                          `json:"from"`
+	To       string                          `json:"to"`
+	Path     string                          `json:"path,omitempty"`
+	Series   []database.GetPageViewsByDayRow `json:"series"`
+	TopPaths []database.GetTopPagesRow       `json:"top_paths,omitempty"`
+}
+
+// handlerPageViews returns daily view counts between the from and to query
+// parameters (YYYY-MM-DD, inclusive, defaulting to the last 30 days),
+// optionally for a single path.
+func (a *apiConfig) handlerPageViews(w http.ResponseWriter, r *http.Request) {
+	to := pageviews.Day(time.Now())
+	from := to.AddDate(0, 0, -(pageViewsDefaultDays - 1))
+
+	var err error
+	if text := r.URL.Query().Get("to"); text != "" {
+		if to, err = time.Parse(time.DateOnly, text); err != nil {
+			slog.InfoContext(r.Context(), "handlerPageViews: invalid to parameter", "err", err)
+			http.Error(w, "Invalid to parameter, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if text := r.URL.Query().Get("from"); text != "" {
+		if from, err = time.Parse(time.DateOnly, text); err != nil {
+			slog.InfoContext(r.Context(), "handlerPageViews: invalid from parameter", "err", err)
+			http.Error(w, "Invalid from parameter, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if from.After(to) || to.Sub(from) > pageViewsMaxDays*24*time.Hour {
+		slog.InfoContext(r.Context(), "handlerPageViews: invalid date range", "from", from, "to", to)
+		http.Error(w, "Invalid date range", http.StatusBadRequest)
+		return
+	}
+	path := r.URL.Query().Get("path")
+
+	series, err := a.dbQueries.GetPageViewsByDay(r.Context(), database.GetPageViewsByDayParams{
+		FromDay: from,
+		ToDay:   to,
+		Path:    sql.NullString{String: path, Valid: path != ""},
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerPageViews: failed to get page views", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	res := pageViewsResponse{
+		From:   from.Format(time.DateOnly),
+		To:     to.Format(time.DateOnly),
+		Path:   path,
+		Series: series,
+	}
+	if path == "" {
+		res.TopPaths, err = a.dbQueries.GetTopPages(r.Context(), database.GetTopPagesParams{
+			FromDay:  from,
+			ToDay:    to,
+			MaxPaths: pageViewsTopPaths,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "handlerPageViews: failed to get top pages", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
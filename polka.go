@@ -1,10 +1,11 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/google/uuid"
@@ -20,12 +21,14 @@ func (a *apiConfig) handlePolkaEvent(w http.ResponseWriter, r *http.Request) {
 
 	apiKey, err := auth.GetAPIKey(r.Header)
 	if err != nil {
-		log.Printf("handlePolkaEvent: failed to get API key: %v", err)
+		slog.WarnContext(r.Context(), "handlePolkaEvent: failed to get API key", "err", err)
+		a.metrics.WebhookEvents.WithLabelValues("polka", "unauthorized").Inc()
 		http.Error(w, "Unauthorized, API key missing.", http.StatusUnauthorized)
 		return
 	}
 	if apiKey != a.pokaApiKey {
-		log.Printf("handlePolkaEvent: unauthorized API key: %s", apiKey)
+		slog.WarnContext(r.Context(), "handlePolkaEvent: invalid API key")
+		a.metrics.WebhookEvents.WithLabelValues("polka", "unauthorized").Inc()
 		http.Error(w, "Unauthorized, invalid API key.", http.StatusUnauthorized)
 		return
 	}
@@ -39,13 +42,15 @@ func (a *apiConfig) handlePolkaEvent(w http.ResponseWriter, r *http.Request) {
 
 	err = json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
-		log.Printf("handlePolkaEvent: failed to decode request body: %v", err)
+		slog.InfoContext(r.Context(), "handlePolkaEvent: failed to decode request body", "err", err)
+		a.metrics.WebhookEvents.WithLabelValues("polka", "bad_request").Inc()
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
 	if payload.Event != "user.upgraded" {
-		log.Printf("handlePolkaEvent: unhandled event: %s", payload.Event)
+		slog.InfoContext(r.Context(), "handlePolkaEvent: unhandled event", "event", payload.Event)
+		a.recordWebhookEvent(r.Context(), "polka", payload.Event, payload.Data.UserID, "ignored")
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
@@ -56,14 +61,33 @@ func (a *apiConfig) handlePolkaEvent(w http.ResponseWriter, r *http.Request) {
 	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("handlePolkaEvent: user not found: %v\n", payload.Data.UserID)
+			slog.WarnContext(r.Context(), "handlePolkaEvent: user not found", "user_id", payload.Data.UserID)
+			a.recordWebhookEvent(r.Context(), "polka", payload.Event, payload.Data.UserID, "user_not_found")
 			http.Error(w, "User not found", http.StatusNotFound)
 		} else {
-			log.Printf("handlePolkaEvent: failed to update user is chirpy red: %v", err)
+			slog.ErrorContext(r.Context(), "handlePolkaEvent: failed to update user is chirpy red", "err", err)
+			a.recordWebhookEvent(r.Context(), "polka", payload.Event, payload.Data.UserID, "error")
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 		return
 	}
 
+	a.recordWebhookEvent(r.Context(), "polka", payload.Event, payload.Data.UserID, "upgraded")
 	w.WriteHeader(http.StatusNoContent)
+}
+
+// recordWebhookEvent keeps an audit trail of authenticated webhook deliveries
+// for the admin dashboard. Failing to record must not fail the webhook.
+func (a *apiConfig) recordWebhookEvent(ctx context.Context, provider, event string, userId uuid.UUID, outcome string) {
+	a.metrics.WebhookEvents.WithLabelValues(provider, outcome).Inc()
+
+	_, err := a.dbQueries.CreateWebhookEvent(ctx, database.CreateWebhookEventParams{
+		Provider: provider,
+		Event:    event,
+		UserID:   uuid.NullUUID{UUID: userId, Valid: userId != uuid.Nil},
+		Outcome:  outcome,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "recordWebhookEvent: failed to save webhook event", "err", err)
+	}
 }
\ No newline at end of file
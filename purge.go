@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// runChirpPurge removes chirps deleted more than purgeAfter ago, and uploads
+// left unattached for longer than mediaTTL, once at start and then every
+// interval, until ctx is cancelled.
+func (a *apiConfig) runChirpPurge(ctx context.Context, interval, purgeAfter, mediaTTL time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := sql.NullTime{Time: time.Now().Add(-purgeAfter), Valid: true}
+		purged, err := a.dbQueries.PurgeDeletedChirps(ctx, cutoff)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "runChirpPurge: failed to purge deleted chirps", "err", err)
+		} else if purged > 0 {
+			slog.InfoContext(ctx, "runChirpPurge: purged deleted chirps", "count", purged, "deleted_before", cutoff.Time)
+		}
+		a.purgeUnattachedMedia(ctx, mediaTTL)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/auth"
+	"github.com/jonvanw/chirpy/internal/ratelimit"
+)
+
+type rateLimitPolicy struct {
+	name     string
+	standard ratelimit.Policy
+	red      ratelimit.Policy // applied to authenticated Chirpy Red users
+}
+
+var (
+	createChirpLimit = rateLimitPolicy{
+		name:     "create_chirp",
+		standard: ratelimit.PerMinute(20, 5),
+		red:      ratelimit.PerMinute(60, 15),
+	}
+	loginLimit = rateLimitPolicy{
+		name:     "login",
+		standard: ratelimit.PerMinute(10, 5),
+		red:      ratelimit.PerMinute(10, 5),
+	}
+	createUserLimit = rateLimitPolicy{
+		name:     "create_user",
+		standard: ratelimit.PerMinute(5, 3),
+		red:      ratelimit.PerMinute(5, 3),
+	}
+	reportChirpLimit = rateLimitPolicy{
+		name:     "report_chirp",
+		standard: ratelimit.PerMinute(10, 5),
+		red:      ratelimit.PerMinute(10, 5),
+	}
+	uploadMediaLimit = rateLimitPolicy{
+		name:     "upload_media",
+		standard: ratelimit.PerMinute(10, 4),
+		red:      ratelimit.PerMinute(30, 8),
+	}
+)
+
+func (a *apiConfig) middlewareRateLimit(policy rateLimitPolicy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, limit := a.rateLimitKey(r, policy)
+
+		res, err := a.rateLimiter.Take(r.Context(), key, limit, time.Now())
+		if err != nil {
+			// fail open: an unavailable limiter store shouldn't take the API down with it
+			slog.ErrorContext(r.Context(), "middlewareRateLimit: failed to take token", "policy", policy.name, "err", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+		if !res.Allowed {
+			slog.WarnContext(r.Context(), "middlewareRateLimit: rate limit exceeded", "policy", policy.name)
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitKey identifies the caller by user ID when the request carries a
+// valid JWT and by client IP otherwise.
+func (a *apiConfig) rateLimitKey(r *http.Request, policy rateLimitPolicy) (string, ratelimit.Policy) {
+	if userId, ok := a.optionalUserId(r); ok {
+		limit := policy.standard
+		user, err := a.dbQueries.GetUserById(r.Context(), userId)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "rateLimitKey: failed to look up user", "user_id", userId, "err", err)
+		} else if user.IsChirpyRed {
+			limit = policy.red
+		}
+		return fmt.Sprintf("%s:user:%s", policy.name, userId), limit
+	}
+	return fmt.Sprintf("%s:ip:%s", policy.name, clientIP(r)), policy.standard
+}
+
+func (a *apiConfig) optionalUserId(r *http.Request) (uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	userId, err := auth.ValidateJWT(token, a.jwtAuthSecret)
+	if err != nil || userId == uuid.Nil {
+		return uuid.Nil, false
+	}
+	return userId, true
+}
+
+// requireUserId is optionalUserId for endpoints that need a signed-in caller;
+// it writes the 401 itself.
+func (a *apiConfig) requireUserId(w http.ResponseWriter, r *http.Request, handler string) (uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		slog.WarnContext(r.Context(), handler+": failed to get bearer token", "err", err)
+		http.Error(w, "Unauthorized, no user token provided.", http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+	userId, err := auth.ValidateJWT(token, a.jwtAuthSecret)
+	if err != nil || userId == uuid.Nil {
+		slog.WarnContext(r.Context(), handler+": failed to validate JWT", "err", err)
+		http.Error(w, "Unauthorized. Invalid user token.", http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+	return userId, true
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
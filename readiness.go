@@ -1,9 +1,180 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	readinessTimeout  = 2 * time.Second
+	readinessCacheTTL = 2 * time.Second
+)
+
+type dependencyStatus struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Version   *int64 `json:"version,omitempty"`
+	Expected  *int64 `json:"expected,omitempty"`
+}
+
+type readinessReport struct {
+	Status    string                      `json:"status"`
+	Checks    map[string]dependencyStatus `json:"checks"`
+	CheckedAt time.Time                   `json:"checked_at"`
+}
+
+func (r readinessReport) ok() bool {
+	return r.Status == "ok"
+}
+
+// readinessChecker caches the last report so frequent probes from several
+// load balancers don't each hit the database.
+type readinessChecker struct {
+	ping          func(ctx context.Context) error
+	schemaVersion func(ctx context.Context) (int64, error)
+	expected      int64 // newest embedded migration
+	mu            sync.Mutex
+	last          readinessReport
+}
+
+func (c *readinessChecker) report(ctx context.Context) readinessReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.last.CheckedAt.IsZero() && time.Since(c.last.CheckedAt) < readinessCacheTTL {
+		return c.last
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	report := readinessReport{
+		Status: "ok",
+		Checks: map[string]dependencyStatus{
+			"database":   c.checkDatabase(ctx),
+			"migrations": c.checkMigrations(ctx),
+		},
+		CheckedAt: time.Now(),
+	}
+	for _, check := range report.Checks {
+		if check.Status != "ok" {
+			report.Status = "unavailable"
+		}
+	}
+
+	c.last = report
+	return report
+}
+
+func (c *readinessChecker) checkDatabase(ctx context.Context) dependencyStatus {
+	start := time.Now()
+	err := c.ping(ctx)
+	status := dependencyStatus{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		status.Status = "unavailable"
+		status.Error = err.Error()
+	}
+	return status
+}
+
+func (c *readinessChecker) checkMigrations(ctx context.Context) dependencyStatus {
+	start := time.Now()
+	expected := c.expected
+	version, err := c.schemaVersion(ctx)
+	status := dependencyStatus{Status: "ok", LatencyMs: time.Since(start).Milliseconds(), Expected: &expected}
+	if err != nil {
+		status.Status = "unavailable"
+		status.Error = err.Error()
+		return status
+	}
+	status.Version = &version
+	if version < expected {
+		status.Status = "unavailable"
+		status.Error = fmt.Sprintf("schema version %d is older than expected version %d", version, expected)
+	}
+	return status
+}
+
+// currentSchemaVersion mirrors how goose reads its version table: walk the
+// history newest first, skipping versions that were later rolled back.
+func currentSchemaVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	skipped := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		var applied bool
+		if err := rows.Scan(&version, &applied); err != nil {
+			return 0, err
+		}
+		if skipped[version] {
+			continue
+		}
+		if applied {
+			return version, nil
+		}
+		skipped[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+func livenessHandler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	w.WriteHeader(status)
+	w.Write([]byte(http.StatusText(status)))
+}
+
+func (a *apiConfig) handlerReadyz(w http.ResponseWriter, r *http.Request) {
+	var report readinessReport
+	if a.draining.Load() {
+		report = readinessReport{Status: "draining", Checks: map[string]dependencyStatus{}, CheckedAt: time.Now()}
+	} else {
+		report = a.readiness.report(r.Context())
+	}
+
+	status := http.StatusOK
+	if !report.ok() {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// drain fails health checks from now on, keeps serving for drainDelay so
+// load balancers notice and stop routing here, and then shuts server down,
+// giving in-flight requests up to timeout to finish.
+func (a *apiConfig) drain(server *http.Server, drainDelay, timeout time.Duration) error {
+	a.draining.Store(true)
+	time.Sleep(drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
+
+func (a *apiConfig) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	if a.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("draining"))
+		return
+	}
 
-func readinessHandler(w http.ResponseWriter, r *http.Request) {
 	status := http.StatusOK
 	w.WriteHeader(status)
 	w.Write([]byte(http.StatusText(status)))
-}
\ No newline at end of file
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/notify"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+var (
+	errChirpUnavailable = errors.New("chirp was taken down")
+	errBlockedByAuthor  = errors.New("blocked by the chirp's author")
+)
+
+// quotedChirpResponse is the chirp a quote points at, shown inside it. A quote
+// whose original has been deleted keeps its quoted_chirp_id but has no
+// quoted_chirp; once the original is purged it reads as a plain chirp.
+type quotedChirpResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+	TakenDown bool      `json:"taken_down,omitempty"`
+}
+
+func newQuotedChirpResponse(chirp database.Chirp) *quotedChirpResponse {
+	res := newChirpResponse(chirp)
+	return &quotedChirpResponse{
+		ID:        res.ID,
+		CreatedAt: res.CreatedAt,
+		UpdatedAt: res.UpdatedAt,
+		Body:      res.Body,
+		UserID:    res.UserID,
+		TakenDown: res.TakenDown,
+	}
+}
+
+// rechirpResponse is who rechirped a feed entry, and when.
+type rechirpResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// checkShareable returns the chirp userId wants to rechirp, quote, reply to
+// or like. Taken down chirps can't be shared, and nor can chirps of someone
+// who has blocked userId.
+func checkShareable(ctx context.Context, tx store.Store, userId, chirpId uuid.UUID) (database.Chirp, error) {
+	chirp, err := tx.GetChirpById(ctx, chirpId)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.TakenDownAt.Valid {
+		return database.Chirp{}, errChirpUnavailable
+	}
+	blocked, err := isBlockedBy(ctx, tx, userId, chirp.UserID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if blocked {
+		return database.Chirp{}, errBlockedByAuthor
+	}
+	return chirp, nil
+}
+
+// withShares fills in how often each chirp has been rechirped, quoted and
+// liked, and the chirp each quote points at.
+func (a *apiConfig) withShares(ctx context.Context, chirps []chirpResponse) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	var ids, quotedIds []uuid.UUID
+	for _, c := range chirps {
+		ids = append(ids, c.ID)
+		if c.QuotedChirpID != nil {
+			quotedIds = append(quotedIds, *c.QuotedChirpID)
+		}
+	}
+
+	rechirps, err := a.dbQueries.CountRechirpsByChirpIds(ctx, ids)
+	if err != nil {
+		return err
+	}
+	quotes, err := a.dbQueries.CountQuotesByChirpIds(ctx, ids)
+	if err != nil {
+		return err
+	}
+	likes, err := a.dbQueries.CountLikesByChirpIds(ctx, ids)
+	if err != nil {
+		return err
+	}
+	rechirpCounts := make(map[uuid.UUID]int64, len(rechirps))
+	for _, r := range rechirps {
+		rechirpCounts[r.ChirpID] = r.RechirpCount
+	}
+	quoteCounts := make(map[uuid.UUID]int64, len(quotes))
+	for _, q := range quotes {
+		quoteCounts[q.QuotedChirpID.UUID] = q.QuoteCount
+	}
+	likeCounts := make(map[uuid.UUID]int64, len(likes))
+	for _, l := range likes {
+		likeCounts[l.ChirpID] = l.LikeCount
+	}
+
+	quoted := make(map[uuid.UUID]database.Chirp)
+	if len(quotedIds) > 0 {
+		originals, err := a.dbQueries.GetChirpsByIds(ctx, quotedIds)
+		if err != nil {
+			return err
+		}
+		for _, c := range originals {
+			quoted[c.ID] = c
+		}
+	}
+
+	for i, c := range chirps {
+		chirps[i].RechirpCount = rechirpCounts[c.ID]
+		chirps[i].QuoteCount = quoteCounts[c.ID]
+		chirps[i].LikeCount = likeCounts[c.ID]
+		if c.QuotedChirpID == nil {
+			continue
+		}
+		if original, ok := quoted[*c.QuotedChirpID]; ok {
+			chirps[i].QuotedChirp = newQuotedChirpResponse(original)
+		}
+	}
+	return nil
+}
+
+// rechirpEntries turns rechirps into feed entries: the original chirp,
+// attributed to whoever rechirped it. loaded are chirps the caller already
+// has; others are fetched. Rechirps by or of hidden users are left out.
+func (a *apiConfig) rechirpEntries(ctx context.Context, rechirps []database.Rechirp, loaded []database.Chirp, hidden []uuid.UUID) ([]chirpResponse, error) {
+	byId := make(map[uuid.UUID]database.Chirp, len(loaded))
+	for _, c := range loaded {
+		byId[c.ID] = c
+	}
+	var missing []uuid.UUID
+	for _, r := range rechirps {
+		if _, ok := byId[r.ChirpID]; !ok && !slices.Contains(missing, r.ChirpID) {
+			missing = append(missing, r.ChirpID)
+		}
+	}
+	if len(missing) > 0 {
+		fetched, err := a.dbQueries.GetChirpsByIds(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range fetched {
+			byId[c.ID] = c
+		}
+	}
+
+	var entries []chirpResponse
+	for _, r := range rechirps {
+		original, ok := byId[r.ChirpID]
+		if !ok || slices.Contains(hidden, r.UserID) || slices.Contains(hidden, original.UserID) {
+			continue
+		}
+		entry := newChirpResponse(original)
+		entry.RechirpedBy = &rechirpResponse{UserID: r.UserID, CreatedAt: r.CreatedAt}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// feedTime is when an entry appeared in the feed: when it was posted, or for
+// a rechirp, when it was rechirped.
+func feedTime(c chirpResponse) time.Time {
+	if c.RechirpedBy != nil {
+		return c.RechirpedBy.CreatedAt
+	}
+	return c.CreatedAt
+}
+
+// chirpAction is something a user does to a chirp at most once, such as
+// rechirping or liking it. Both follow the rules of checkShareable and notify
+// the author.
+type chirpAction struct {
+	name         string // "rechirp"
+	done         string // "rechirped"
+	notification string
+	// create returns sql.ErrNoRows if the caller has already done it
+	create func(ctx context.Context, q store.Store, userId, chirpId uuid.UUID) (any, error)
+	get    func(ctx context.Context, q store.Store, userId, chirpId uuid.UUID) (any, error)
+	undo   func(ctx context.Context, q store.Store, userId, chirpId uuid.UUID) (int64, error)
+}
+
+var rechirpAction = chirpAction{
+	name:         "rechirp",
+	done:         "rechirped",
+	notification: notify.Rechirp,
+	create: func(ctx context.Context, q store.Store, userId, chirpId uuid.UUID) (any, error) {
+		return q.CreateRechirp(ctx, database.CreateRechirpParams{UserID: userId, ChirpID: chirpId})
+	},
+	get: func(ctx context.Context, q store.Store, userId, chirpId uuid.UUID) (any, error) {
+		return q.GetRechirp(ctx, database.GetRechirpParams{UserID: userId, ChirpID: chirpId})
+	},
+	undo: func(ctx context.Context, q store.Store, userId, chirpId uuid.UUID) (int64, error) {
+		return q.DeleteRechirp(ctx, database.DeleteRechirpParams{UserID: userId, ChirpID: chirpId})
+	},
+}
+
+// handlerDoChirpAction rechirps or likes a chirp for the caller. Doing it
+// again returns the existing rechirp or like.
+func (a *apiConfig) handlerDoChirpAction(action chirpAction) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := a.requireUserId(w, r, "handlerDoChirpAction")
+		if !ok {
+			return
+		}
+		id, err := uuid.Parse(r.PathValue("chirpId"))
+		if err != nil {
+			slog.InfoContext(r.Context(), "handlerDoChirpAction: invalid ID parameter", "err", err)
+			http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+			return
+		}
+
+		var res any
+		created := true
+		err = a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+			if _, err := checkShareable(r.Context(), tx, userId, id); err != nil {
+				return err
+			}
+			var err error
+			created = true
+			res, err = action.create(r.Context(), tx, userId, id)
+			if errors.Is(err, sql.ErrNoRows) {
+				created = false
+				res, err = action.get(r.Context(), tx, userId, id)
+				return err
+			}
+			if err != nil {
+				return err
+			}
+			if err := tx.TouchChirpCounts(r.Context(), id); err != nil {
+				return err
+			}
+			return tx.EnqueueNotificationEvent(r.Context(), database.EnqueueNotificationEventParams{
+				Type:    action.notification,
+				ActorID: userId,
+				ChirpID: uuid.NullUUID{UUID: id, Valid: true},
+			})
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				slog.InfoContext(r.Context(), "handlerDoChirpAction: chirp not found", "chirp_id", id)
+				http.Error(w, fmt.Sprintf("Chirp with ID %s not found", id.String()), http.StatusNotFound)
+			case errors.Is(err, errChirpUnavailable):
+				http.Error(w, fmt.Sprintf("Chirps removed by a moderator can't be %s", action.done), http.StatusConflict)
+			case errors.Is(err, errBlockedByAuthor):
+				http.Error(w, fmt.Sprintf("Forbidden: you can't %s this user's chirps", action.name), http.StatusForbidden)
+			default:
+				slog.ErrorContext(r.Context(), "handlerDoChirpAction: failed", "action", action.name, "err", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+			a.notifications.Wake()
+			slog.InfoContext(r.Context(), "handlerDoChirpAction: done", "action", action.name, "chirp_id", id, "user_id", userId)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// handlerUndoChirpAction removes the caller's rechirp or like. It works even
+// once the chirp has been deleted.
+func (a *apiConfig) handlerUndoChirpAction(action chirpAction) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := a.requireUserId(w, r, "handlerUndoChirpAction")
+		if !ok {
+			return
+		}
+		id, err := uuid.Parse(r.PathValue("chirpId"))
+		if err != nil {
+			slog.InfoContext(r.Context(), "handlerUndoChirpAction: invalid ID parameter", "err", err)
+			http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+			return
+		}
+
+		var deleted int64
+		err = a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+			var err error
+			deleted, err = action.undo(r.Context(), tx, userId, id)
+			if err != nil || deleted == 0 {
+				return err
+			}
+			// the row is gone, so this is what moves Last-Modified forward
+			return tx.TouchChirpCounts(r.Context(), id)
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "handlerUndoChirpAction: failed", "action", action.name, "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if deleted == 0 {
+			http.Error(w, fmt.Sprintf("You haven't %s this chirp", action.done), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/notify"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+// errBlockedByUser means the other user has blocked the caller.
+var errBlockedByUser = errors.New("blocked by user")
+
+type relationshipResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// relationship is a one-way link from the caller to another user. Following
+// someone notifies them. Blocks and mutes both hide the other user's chirps
+// from the caller; a block also ends follows either way and stops the blocked
+// user from interacting with the caller (see isBlockedBy).
+type relationship struct {
+	name string
+	// add runs in a transaction once the other user is known to exist
+	add    func(ctx context.Context, q store.Store, from, to uuid.UUID) error
+	remove func(ctx context.Context, q store.Relationships, from, to uuid.UUID) error
+	list   func(ctx context.Context, q store.Relationships, from uuid.UUID) ([]relationshipResponse, error)
+	// notifies means add may have queued a notification
+	notifies bool
+}
+
+var (
+	followRelationship = relationship{
+		name: "follow",
+		add: func(ctx context.Context, q store.Store, from, to uuid.UUID) error {
+			blocked, err := isBlockedBy(ctx, q, from, to)
+			if err != nil {
+				return err
+			}
+			if blocked {
+				return errBlockedByUser
+			}
+			n, err := q.FollowUser(ctx, database.FollowUserParams{FollowerID: from, FollowedID: to})
+			if err != nil || n == 0 {
+				return err
+			}
+			return q.EnqueueNotificationEvent(ctx, database.EnqueueNotificationEventParams{
+				Type:    notify.Follow,
+				ActorID: from,
+				UserID:  uuid.NullUUID{UUID: to, Valid: true},
+			})
+		},
+		remove: func(ctx context.Context, q store.Relationships, from, to uuid.UUID) error {
+			return q.UnfollowUser(ctx, database.UnfollowUserParams{FollowerID: from, FollowedID: to})
+		},
+		list: func(ctx context.Context, q store.Relationships, from uuid.UUID) ([]relationshipResponse, error) {
+			follows, err := q.GetFollowedUsers(ctx, from)
+			res := make([]relationshipResponse, len(follows))
+			for i, f := range follows {
+				res[i] = relationshipResponse{UserID: f.FollowedID, CreatedAt: f.CreatedAt}
+			}
+			return res, err
+		},
+		notifies: true,
+	}
+	blockRelationship = relationship{
+		name: "block",
+		add: func(ctx context.Context, q store.Store, from, to uuid.UUID) error {
+			if err := q.UnfollowUser(ctx, database.UnfollowUserParams{FollowerID: from, FollowedID: to}); err != nil {
+				return err
+			}
+			if err := q.UnfollowUser(ctx, database.UnfollowUserParams{FollowerID: to, FollowedID: from}); err != nil {
+				return err
+			}
+			return q.BlockUser(ctx, database.BlockUserParams{BlockerID: from, BlockedID: to})
+		},
+		remove: func(ctx context.Context, q store.Relationships, from, to uuid.UUID) error {
+			return q.UnblockUser(ctx, database.UnblockUserParams{BlockerID: from, BlockedID: to})
+		},
+		list: func(ctx context.Context, q store.Relationships, from uuid.UUID) ([]relationshipResponse, error) {
+			blocks, err := q.GetBlockedUsers(ctx, from)
+			res := make([]relationshipResponse, len(blocks))
+			for i, b := range blocks {
+				res[i] = relationshipResponse{UserID: b.BlockedID, CreatedAt: b.CreatedAt}
+			}
+			return res, err
+		},
+	}
+	muteRelationship = relationship{
+		name: "mute",
+		add: func(ctx context.Context, q store.Store, from, to uuid.UUID) error {
+			return q.MuteUser(ctx, database.MuteUserParams{MuterID: from, MutedID: to})
+		},
+		remove: func(ctx context.Context, q store.Relationships, from, to uuid.UUID) error {
+			return q.UnmuteUser(ctx, database.UnmuteUserParams{MuterID: from, MutedID: to})
+		},
+		list: func(ctx context.Context, q store.Relationships, from uuid.UUID) ([]relationshipResponse, error) {
+			mutes, err := q.GetMutedUsers(ctx, from)
+			res := make([]relationshipResponse, len(mutes))
+			for i, m := range mutes {
+				res[i] = relationshipResponse{UserID: m.MutedID, CreatedAt: m.CreatedAt}
+			}
+			return res, err
+		},
+	}
+)
+
+// isBlockedBy reports whether owner has blocked actor. Anything that lets
+// actor reach owner, such as replying to, liking or mentioning them, checks it
+// first.
+func isBlockedBy(ctx context.Context, q store.Relationships, actor, owner uuid.UUID) (bool, error) {
+	return q.IsUserBlocked(ctx, database.IsUserBlockedParams{BlockerID: owner, BlockedID: actor})
+}
+
+func (a *apiConfig) handlerListRelationships(rel relationship) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := a.requireUserId(w, r, "handlerListRelationships")
+		if !ok {
+			return
+		}
+
+		res, err := rel.list(r.Context(), a.dbQueries, userId)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "handlerListRelationships: failed to list", "relationship", rel.name, "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// handlerAddRelationship follows, blocks or mutes the user in the path. Doing
+// it twice is not an error.
+func (a *apiConfig) handlerAddRelationship(rel relationship) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := a.requireUserId(w, r, "handlerAddRelationship")
+		if !ok {
+			return
+		}
+		targetId, err := uuid.Parse(r.PathValue("userId"))
+		if err != nil {
+			slog.InfoContext(r.Context(), "handlerAddRelationship: invalid ID parameter", "err", err)
+			http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+			return
+		}
+		if targetId == userId {
+			http.Error(w, fmt.Sprintf("You can't %s yourself", rel.name), http.StatusBadRequest)
+			return
+		}
+
+		err = a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+			if _, err := tx.GetUserById(r.Context(), targetId); err != nil {
+				return err
+			}
+			return rel.add(r.Context(), tx, userId, targetId)
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				http.Error(w, "User not found", http.StatusNotFound)
+			case errors.Is(err, errBlockedByUser):
+				http.Error(w, fmt.Sprintf("Forbidden: you can't %s this user", rel.name), http.StatusForbidden)
+			default:
+				slog.ErrorContext(r.Context(), "handlerAddRelationship: failed to add", "relationship", rel.name, "err", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+		if rel.notifies {
+			a.notifications.Wake()
+		}
+		slog.InfoContext(r.Context(), "handlerAddRelationship: added", "relationship", rel.name, "user_id", userId, "target_id", targetId)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (a *apiConfig) handlerRemoveRelationship(rel relationship) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := a.requireUserId(w, r, "handlerRemoveRelationship")
+		if !ok {
+			return
+		}
+		targetId, err := uuid.Parse(r.PathValue("userId"))
+		if err != nil {
+			slog.InfoContext(r.Context(), "handlerRemoveRelationship: invalid ID parameter", "err", err)
+			http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+			return
+		}
+
+		if err := rel.remove(r.Context(), a.dbQueries, userId, targetId); err != nil {
+			slog.ErrorContext(r.Context(), "handlerRemoveRelationship: failed to remove", "relationship", rel.name, "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
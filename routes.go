@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jonvanw/chirpy/internal/auth"
+	"github.com/jonvanw/chirpy/internal/media"
+)
+
+const appPrefix = "/app/"
+
+// routes registers every endpoint. app serves the files under /app/.
+func (a *apiConfig) routes(app http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.Handle(appPrefix, a.middlewareMetricsInc(http.StripPrefix(appPrefix, app)))
+
+	mux.Handle("GET /admin/{$}", a.middlewareRequirePermission(auth.PermViewAdmin, http.HandlerFunc(a.handlerAdminDashboard)))
+
+	mux.Handle("GET /admin/api/stats", a.middlewareRequirePermission(auth.PermViewAdmin, http.HandlerFunc(a.handlerAdminStats)))
+
+	mux.Handle("GET /admin/api/pageviews", a.middlewareRequirePermission(auth.PermViewAdmin, http.HandlerFunc(a.handlerPageViews)))
+
+	mux.Handle("GET /admin/metrics", a.middlewareRequirePermission(auth.PermViewAdmin, http.HandlerFunc(a.handlerMetrics)))
+
+	// the Prometheus exposition shows per-route traffic and auth failures, so
+	// it needs the same permission as the admin view of it
+	mux.Handle("GET /metrics", a.middlewareRequirePermission(auth.PermViewAdmin, a.metrics.Handler()))
+
+	mux.Handle("POST /admin/reset", a.middlewareRequirePermission(auth.PermResetData, http.HandlerFunc(a.handlerReset)))
+
+	mux.Handle("PUT /admin/users/{userId}/role", a.middlewareRequirePermission(auth.PermManageRoles, http.HandlerFunc(a.handlerSetUserRole)))
+
+	mux.HandleFunc("GET /api/healthz", a.readinessHandler)
+
+	mux.HandleFunc("GET /api/livez", livenessHandler)
+
+	mux.HandleFunc("GET /api/readyz", a.handlerReadyz)
+
+	mux.Handle("POST /api/chirps", a.middlewareRateLimit(createChirpLimit, http.HandlerFunc(a.handleAddChirp)))
+
+	mux.HandleFunc("GET /api/chirps", a.handlerGetChirps)
+
+	mux.HandleFunc("GET /api/chirps/{chirpId}", a.handlerGetChirpById)
+
+	mux.HandleFunc("GET /api/chirps/scheduled", a.handlerListScheduledChirps)
+
+	mux.HandleFunc("PUT /api/chirps/scheduled/{scheduledId}", a.handlerUpdateScheduledChirp)
+
+	mux.HandleFunc("DELETE /api/chirps/scheduled/{scheduledId}", a.handlerCancelScheduledChirp)
+
+	mux.HandleFunc("DELETE /api/chirps/{chirpId}", a.handlerDeleteChirp)
+
+	mux.HandleFunc("POST /api/chirps/{chirpId}/restore", a.handlerRestoreChirp)
+
+	mux.Handle("POST /api/chirps/{chirpId}/rechirp", a.middlewareRateLimit(createChirpLimit, a.handlerDoChirpAction(rechirpAction)))
+
+	// not DELETE /api/chirps/{chirpId}/rechirp, which would overlap with
+	// DELETE /api/chirps/scheduled/{scheduledId}
+	mux.HandleFunc("DELETE /api/rechirps/{chirpId}", a.handlerUndoChirpAction(rechirpAction))
+
+	mux.Handle("POST /api/likes/{chirpId}", a.middlewareRateLimit(createChirpLimit, a.handlerDoChirpAction(likeAction)))
+
+	mux.HandleFunc("DELETE /api/likes/{chirpId}", a.handlerUndoChirpAction(likeAction))
+
+	mux.Handle("POST /admin/chirps/{chirpId}/takedown", a.middlewareRequirePermission(auth.PermDeleteAnyChirp, http.HandlerFunc(a.handlerTakeDownChirp)))
+
+	mux.Handle("DELETE /admin/chirps/{chirpId}/takedown", a.middlewareRequirePermission(auth.PermDeleteAnyChirp, http.HandlerFunc(a.handlerReinstateChirp)))
+
+	mux.Handle("POST /api/chirps/{chirpId}/reports", a.middlewareRateLimit(reportChirpLimit, http.HandlerFunc(a.handlerReportChirp)))
+
+	mux.Handle("GET /admin/moderation/cases", a.middlewareRequirePermission(auth.PermModerate, http.HandlerFunc(a.handlerListModerationCases)))
+
+	mux.Handle("GET /admin/moderation/cases/{caseId}", a.middlewareRequirePermission(auth.PermModerate, http.HandlerFunc(a.handlerGetModerationCase)))
+
+	mux.Handle("POST /admin/moderation/cases/{caseId}/claim", a.middlewareRequirePermission(auth.PermModerate, http.HandlerFunc(a.handlerClaimModerationCase)))
+
+	mux.Handle("POST /admin/moderation/cases/{caseId}/resolve", a.middlewareRequirePermission(auth.PermModerate, http.HandlerFunc(a.handlerResolveModerationCase)))
+
+	mux.Handle("GET /admin/moderation/log", a.middlewareRequirePermission(auth.PermModerate, http.HandlerFunc(a.handlerModerationLog)))
+
+	mux.HandleFunc("POST /api/drafts", a.handlerCreateDraft)
+
+	mux.HandleFunc("GET /api/drafts", a.handlerListDrafts)
+
+	mux.HandleFunc("GET /api/drafts/{draftId}", a.handlerGetDraft)
+
+	mux.HandleFunc("PUT /api/drafts/{draftId}", a.handlerUpdateDraft)
+
+	mux.HandleFunc("DELETE /api/drafts/{draftId}", a.handlerDeleteDraft)
+
+	mux.Handle("POST /api/drafts/{draftId}/publish", a.middlewareRateLimit(createChirpLimit, http.HandlerFunc(a.handlerPublishDraft)))
+
+	mux.HandleFunc("GET /api/notifications", a.handlerListNotifications)
+
+	mux.HandleFunc("POST /api/notifications/read", a.handlerMarkNotificationsRead)
+
+	mux.HandleFunc("GET /api/notifications/preferences", a.handlerGetNotificationPreferences)
+
+	mux.HandleFunc("PUT /api/notifications/preferences", a.handlerUpdateNotificationPreferences)
+
+	mux.Handle("POST "+mediaUploadPath, a.middlewareRateLimit(uploadMediaLimit, http.HandlerFunc(a.handlerUploadMedia)))
+
+	// local storage serves its own files when its base URL is on this server
+	if local, ok := a.mediaStorage.(*media.LocalStorage); ok && strings.HasPrefix(local.URL(""), "/") {
+		prefix := local.URL("")
+		mux.Handle("GET "+prefix, http.StripPrefix(prefix, local.Handler()))
+	}
+
+	mux.Handle("POST /api/users", a.middlewareRateLimit(createUserLimit, http.HandlerFunc(a.handleAddUser)))
+
+	mux.HandleFunc("PUT /api/users", a.handleUpdateUser)
+
+	mux.HandleFunc("PUT /api/users/username", a.handleSetUsername)
+
+	mux.HandleFunc("GET /api/follows", a.handlerListRelationships(followRelationship))
+
+	mux.HandleFunc("PUT /api/follows/{userId}", a.handlerAddRelationship(followRelationship))
+
+	mux.HandleFunc("DELETE /api/follows/{userId}", a.handlerRemoveRelationship(followRelationship))
+
+	mux.HandleFunc("GET /api/blocks", a.handlerListRelationships(blockRelationship))
+
+	mux.HandleFunc("PUT /api/blocks/{userId}", a.handlerAddRelationship(blockRelationship))
+
+	mux.HandleFunc("DELETE /api/blocks/{userId}", a.handlerRemoveRelationship(blockRelationship))
+
+	mux.HandleFunc("GET /api/mutes", a.handlerListRelationships(muteRelationship))
+
+	mux.HandleFunc("PUT /api/mutes/{userId}", a.handlerAddRelationship(muteRelationship))
+
+	mux.HandleFunc("DELETE /api/mutes/{userId}", a.handlerRemoveRelationship(muteRelationship))
+
+	mux.Handle("POST /api/login", a.middlewareRateLimit(loginLimit, http.HandlerFunc(a.handleLogin)))
+
+	mux.HandleFunc("POST /api/refresh", a.handleRefreshAuthToken)
+
+	mux.HandleFunc("POST /api/revoke", a.handleRevokeRefreshToken)
+
+	mux.HandleFunc("POST /api/polka/webhooks", a.handlePolkaEvent)
+
+	return mux
+}
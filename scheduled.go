@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/linkpreview"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+const (
+	maxScheduleAhead = 365 * 24 * time.Hour
+	publishBatchSize = 50
+)
+
+var (
+	errScheduledLimit   = errors.New("too many scheduled chirps")
+	errPublishAtInvalid = errors.New("publish_at must be in the future and within a year")
+)
+
+func checkPublishAt(publishAt time.Time) error {
+	now := time.Now()
+	if !publishAt.After(now) || publishAt.After(now.Add(maxScheduleAhead)) {
+		return errPublishAtInvalid
+	}
+	return nil
+}
+
+// scheduleChirp saves an already validated body to be posted at publishAt.
+// Users without Chirpy Red can only have a few waiting at once.
+func (a *apiConfig) scheduleChirp(w http.ResponseWriter, r *http.Request, userId uuid.UUID, body string, publishAt time.Time) {
+	if err := checkPublishAt(publishAt); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var scheduled database.ScheduledChirp
+	err := a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		user, err := tx.GetUserById(r.Context(), userId)
+		if err != nil {
+			return err
+		}
+		if !user.IsChirpyRed {
+			pending, err := tx.CountScheduledChirpsByUserId(r.Context(), userId)
+			if err != nil {
+				return err
+			}
+			if pending >= int64(a.scheduledLimit) {
+				return errScheduledLimit
+			}
+		}
+		scheduled, err = tx.CreateScheduledChirp(r.Context(), database.CreateScheduledChirpParams{
+			Body:      body,
+			UserID:    userId,
+			PublishAt: publishAt.UTC(),
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errScheduledLimit) {
+			http.Error(w, fmt.Sprintf("You can have at most %d scheduled chirps without Chirpy Red", a.scheduledLimit), http.StatusForbidden)
+			return
+		}
+		slog.ErrorContext(r.Context(), "scheduleChirp: failed to schedule chirp", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "scheduleChirp: chirp scheduled", "scheduled_id", scheduled.ID, "user_id", userId, "publish_at", scheduled.PublishAt)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+// handlerListScheduledChirps lists the caller's chirps still waiting to be
+// published, soonest first.
+func (a *apiConfig) handlerListScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerListScheduledChirps")
+	if !ok {
+		return
+	}
+
+	scheduled, err := a.dbQueries.GetScheduledChirpsByUserId(r.Context(), userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerListScheduledChirps: failed to list scheduled chirps", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if scheduled == nil {
+		scheduled = []database.ScheduledChirp{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+// handlerUpdateScheduledChirp changes the body, the publish time or both of a
+// chirp that hasn't been published yet.
+func (a *apiConfig) handlerUpdateScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerUpdateScheduledChirp")
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(r.PathValue("scheduledId"))
+	if err != nil {
+		slog.InfoContext(r.Context(), "handlerUpdateScheduledChirp: invalid ID parameter", "err", err)
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Body      *string    `json:"body"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		slog.InfoContext(r.Context(), "handlerUpdateScheduledChirp: failed to decode request body", "err", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if payload.Body != nil {
+		cleaned, _, err := ValidateChirp(*payload.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		payload.Body = &cleaned
+	}
+	if payload.PublishAt != nil {
+		if err := checkPublishAt(*payload.PublishAt); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var scheduled database.ScheduledChirp
+	err = a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		current, err := tx.GetScheduledChirp(r.Context(), database.GetScheduledChirpParams{ID: id, UserID: userId})
+		if err != nil {
+			return err
+		}
+		update := database.UpdateScheduledChirpParams{Body: current.Body, PublishAt: current.PublishAt, ID: id, UserID: userId}
+		if payload.Body != nil {
+			update.Body = *payload.Body
+		}
+		if payload.PublishAt != nil {
+			update.PublishAt = payload.PublishAt.UTC()
+		}
+		scheduled, err = tx.UpdateScheduledChirp(r.Context(), update)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Scheduled chirp not found; it may already have been published", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "handlerUpdateScheduledChirp: failed to update scheduled chirp", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+func (a *apiConfig) handlerCancelScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handlerCancelScheduledChirp")
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(r.PathValue("scheduledId"))
+	if err != nil {
+		slog.InfoContext(r.Context(), "handlerCancelScheduledChirp: invalid ID parameter", "err", err)
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := a.dbQueries.DeleteScheduledChirp(r.Context(), database.DeleteScheduledChirpParams{ID: id, UserID: userId})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "handlerCancelScheduledChirp: failed to cancel scheduled chirp", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		http.Error(w, "Scheduled chirp not found; it may already have been published", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runScheduledChirps publishes due chirps once at start and then every
+// interval, until ctx is cancelled. Schedules live in the database, so ones
+// that fell due while the server was down go out when it comes back.
+func (a *apiConfig) runScheduledChirps(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := a.publishDueChirps(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "runScheduledChirps: failed to publish scheduled chirps", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDueChirps turns every chirp scheduled for now or earlier into a
+// chirp. Each batch is taken and posted in one transaction, so a chirp is
+// never posted twice or lost, even with several instances running. now comes
+// from Go rather than the database's NOW(), which publish_at, stored as UTC
+// without a time zone, can't be compared with unless the session is in UTC.
+func (a *apiConfig) publishDueChirps(ctx context.Context, now time.Time) (int, error) {
+	total := 0
+	for {
+		var published []database.Chirp
+		err := a.dbQueries.InTx(ctx, func(tx store.Store) error {
+			published = published[:0]
+			due, err := tx.TakeDueScheduledChirps(ctx, database.TakeDueScheduledChirpsParams{PublishAt: now.UTC(), Limit: publishBatchSize})
+			if err != nil {
+				return err
+			}
+			for _, s := range due {
+				// the body was validated when it was scheduled or edited
+				params := database.CreateChirpParams{Body: s.Body, UserID: s.UserID}
+				chirp, _, err := a.createChirp(ctx, tx, params, linkpreview.FindURLs(s.Body), nil)
+				if err != nil {
+					return err
+				}
+				published = append(published, chirp)
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		for _, chirp := range published {
+			a.chirpCreated(chirp, linkpreview.FindURLs(chirp.Body))
+			slog.InfoContext(ctx, "publishDueChirps: published scheduled chirp", "chirp_id", chirp.ID, "user_id", chirp.UserID)
+		}
+		total += len(published)
+		if len(published) < publishBatchSize {
+			return total, nil
+		}
+	}
+}
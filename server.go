@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/jonvanw/chirpy/internal/config"
+)
+
+func newServer(cfg config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.ListenAddr,
+		Handler:           limitBody(handler, cfg.Server.MaxBodyBytes, cfg.Media.MaxUploadBytes+multipartOverhead),
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+}
+
+// limitBody caps request bodies at maxBytes, except for media uploads which
+// may be up to uploadBytes.
+func limitBody(next http.Handler, maxBytes, uploadBytes int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := maxBytes
+		if r.URL.Path == mediaUploadPath {
+			limit = uploadBytes
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *apiConfig) accessLogUserId(r *http.Request) string {
+	if userId, ok := a.optionalUserId(r); ok {
+		return userId.String()
+	}
+	return ""
+}
@@ -0,0 +1,25 @@
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users;
+
+-- name: CountChirpyRedUsers :one
+SELECT COUNT(*) FROM users
+WHERE is_chirpy_red = TRUE;
+
+-- name: CountActiveSessions :one
+SELECT COUNT(*) FROM refresh_tokens
+WHERE revoked_at IS NULL AND expires_at > NOW();
+
+-- name: CountChirpsPerDay :many
+SELECT date_trunc('day', created_at)::date AS day, COUNT(*) AS chirps
+FROM chirps
+WHERE created_at >= $1 AND deleted_at IS NULL
+GROUP BY day
+ORDER BY day ASC;
+
+-- name: GetTopPosters :many
+SELECT users.id, users.email, COUNT(chirps.id) AS chirp_count
+FROM users
+JOIN chirps ON chirps.user_id = users.id AND chirps.deleted_at IS NULL
+GROUP BY users.id, users.email
+ORDER BY chirp_count DESC
+LIMIT $1;
@@ -1,30 +1,90 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quoted_chirp_id, reply_to_chirp_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
 RETURNING *;
 
 -- name: GetChirpsByCreation :many
-SELECT id, created_at, updated_at, body, user_id
+SELECT *
 FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC;
 
 -- name: GetChirpsByUserId :many
-SELECT id, created_at, updated_at, body, user_id
+SELECT *
 FROM chirps
-WHERE user_id = $1
+WHERE user_id = $1 AND deleted_at IS NULL
 ORDER BY created_at ASC;
 
 -- name: GetChirpById :one
-SELECT id, created_at, updated_at, body, user_id
+SELECT *
 FROM chirps
-WHERE id = $1; 
+WHERE id = $1 AND deleted_at IS NULL;
 
--- name: DeleteChirp :exec
+-- name: GetChirpByIdWithDeleted :one
+SELECT *
+FROM chirps
+WHERE id = $1;
+
+-- name: GetChirpsByIds :many
+SELECT *
+FROM chirps
+WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL;
+
+-- name: CountQuotesByChirpIds :many
+SELECT quoted_chirp_id, COUNT(*) AS quote_count
+FROM chirps
+WHERE quoted_chirp_id = ANY($1::uuid[]) AND deleted_at IS NULL
+GROUP BY quoted_chirp_id;
+
+-- name: DeleteChirp :one
+UPDATE chirps
+SET deleted_at = NOW(), updated_at = NOW(), deleted_by = $2, deletion_reason = $3
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: DeleteChirpByAuthor :one
+UPDATE chirps
+SET deleted_at = NOW(), updated_at = NOW(), deleted_by = user_id
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL, deletion_reason = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NOT NULL
+RETURNING *;
+
+-- name: TakeDownChirp :one
+UPDATE chirps
+SET taken_down_at = NOW(), taken_down_by = $2, takedown_reason = $3, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: ReinstateChirp :one
+UPDATE chirps
+SET taken_down_at = NULL, taken_down_by = NULL, takedown_reason = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE deleted_at < $1;
+
+-- name: GetChirpsVersion :one
+SELECT COUNT(*) FILTER (WHERE deleted_at IS NULL) AS chirp_count,
+    COALESCE(MAX(updated_at), 'epoch')::timestamp AS last_updated,
+    COALESCE(MAX(counts_updated_at), 'epoch')::timestamp AS counts_updated
+FROM chirps;
+
+-- name: TouchChirpCounts :exec
+UPDATE chirps
+SET counts_updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,24 @@
+-- name: CreateLike :one
+INSERT INTO likes (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, chirp_id) DO NOTHING
+RETURNING *;
+
+-- name: GetLike :one
+SELECT *
+FROM likes
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: DeleteLike :execrows
+DELETE FROM likes
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: CountLikesByChirpIds :many
+SELECT chirp_id, COUNT(*) AS like_count
+FROM likes
+WHERE chirp_id = ANY($1::uuid[])
+GROUP BY chirp_id;
+
+-- name: GetLikesVersion :one
+SELECT COUNT(*) AS like_count, COALESCE(MAX(created_at), 'epoch')::timestamp AS last_created
+FROM likes;
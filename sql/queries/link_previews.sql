@@ -0,0 +1,40 @@
+-- name: EnqueueLinkPreview :exec
+INSERT INTO link_previews (url, created_at, updated_at)
+VALUES ($1, NOW(), NOW())
+ON CONFLICT (url) DO NOTHING;
+
+-- name: ClaimLinkPreviews :many
+UPDATE link_previews
+SET next_attempt_at = sqlc.arg(claimed_until)
+WHERE url IN (
+    SELECT url
+    FROM link_previews
+    WHERE status = 'pending' AND next_attempt_at <= sqlc.arg(now)
+    ORDER BY created_at
+    LIMIT sqlc.arg(max_claimed)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteLinkPreview :exec
+UPDATE link_previews
+SET status = 'ready', attempts = attempts + 1, title = $2, description = $3, image_url = $4, updated_at = NOW()
+WHERE url = $1;
+
+-- name: FailLinkPreview :exec
+UPDATE link_previews
+SET attempts = attempts + 1,
+    status = CASE WHEN attempts + 1 >= sqlc.arg(max_attempts)::integer THEN 'failed' ELSE 'pending' END,
+    next_attempt_at = sqlc.arg(next_attempt_at),
+    updated_at = NOW()
+WHERE url = sqlc.arg(url);
+
+-- name: GetLinkPreviewsByUrls :many
+SELECT *
+FROM link_previews
+WHERE url = ANY($1::text[]) AND status = 'ready';
+
+-- name: GetLinkPreviewsVersion :one
+SELECT COALESCE(MAX(updated_at), 'epoch')::timestamp AS last_updated
+FROM link_previews
+WHERE status = 'ready';
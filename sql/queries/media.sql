@@ -0,0 +1,31 @@
+-- name: CreateMediaAttachment :one
+INSERT INTO media_attachments (id, created_at, user_id, content_type, width, height, size_bytes, storage_key, thumbnail_key)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+
+-- name: AttachMedia :one
+UPDATE media_attachments
+SET chirp_id = $1, position = $2
+WHERE id = $3 AND user_id = $4 AND chirp_id IS NULL
+RETURNING *;
+
+-- name: GetMediaByChirpIds :many
+SELECT *
+FROM media_attachments
+WHERE chirp_id = ANY($1::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: DeleteUnattachedMedia :many
+DELETE FROM media_attachments
+WHERE chirp_id IS NULL AND created_at < $1
+RETURNING storage_key, thumbnail_key;
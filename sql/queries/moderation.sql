@@ -0,0 +1,85 @@
+-- name: OpenModerationCase :one
+INSERT INTO moderation_cases (id, created_at, updated_at, chirp_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1
+)
+ON CONFLICT (chirp_id) WHERE status <> 'resolved' DO UPDATE SET updated_at = NOW()
+RETURNING *;
+
+-- name: IncrementCaseReportCount :one
+UPDATE moderation_cases
+SET report_count = report_count + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, case_id, chirp_id, reporter_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetModerationCase :one
+SELECT *
+FROM moderation_cases
+WHERE id = $1;
+
+-- name: ListModerationCases :many
+SELECT moderation_cases.id, moderation_cases.created_at, moderation_cases.updated_at, moderation_cases.chirp_id, moderation_cases.status, moderation_cases.report_count, moderation_cases.claimed_by, moderation_cases.claimed_at, moderation_cases.resolution, moderation_cases.resolved_by, moderation_cases.resolved_at, chirps.user_id AS author_id, chirps.body AS chirp_body
+FROM moderation_cases
+JOIN chirps ON chirps.id = moderation_cases.chirp_id
+WHERE moderation_cases.status = $1
+ORDER BY moderation_cases.report_count DESC, moderation_cases.created_at ASC
+LIMIT $2;
+
+-- name: GetReportByReporter :one
+SELECT *
+FROM reports
+WHERE chirp_id = $1 AND reporter_id = $2;
+
+-- name: GetReportsByCase :many
+SELECT *
+FROM reports
+WHERE case_id = $1
+ORDER BY created_at ASC;
+
+-- name: ClaimModerationCase :one
+UPDATE moderation_cases
+SET status = 'claimed', claimed_by = $2, claimed_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND (status = 'open' OR (status = 'claimed' AND claimed_by = $2))
+RETURNING *;
+
+-- name: ResolveModerationCase :one
+UPDATE moderation_cases
+SET status = 'resolved', resolution = $3, resolved_by = $2, resolved_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'claimed' AND claimed_by = $2
+RETURNING *;
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, moderator_id, case_id, chirp_id, target_user_id, action, note)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetModerationActions :many
+SELECT *
+FROM moderation_actions
+ORDER BY created_at DESC
+LIMIT $1;
@@ -0,0 +1,30 @@
+-- name: IncrementPageViews :exec
+INSERT INTO page_views (path, day, hits)
+VALUES ($1, $2, $3)
+ON CONFLICT (path, day)
+DO UPDATE SET hits = page_views.hits + EXCLUDED.hits;
+
+-- name: GetTotalPageViews :one
+SELECT COALESCE(SUM(hits), 0)::bigint AS total
+FROM page_views;
+
+-- name: GetPageViewsByDay :many
+SELECT day, SUM(hits)::bigint AS hits
+FROM page_views
+WHERE day >= sqlc.arg(from_day)
+    AND day <= sqlc.arg(to_day)
+    AND (sqlc.narg(path)::text IS NULL OR path = sqlc.narg(path))
+GROUP BY day
+ORDER BY day ASC;
+
+-- name: GetTopPages :many
+SELECT path, SUM(hits)::bigint AS hits
+FROM page_views
+WHERE day >= sqlc.arg(from_day)
+    AND day <= sqlc.arg(to_day)
+GROUP BY path
+ORDER BY hits DESC
+LIMIT sqlc.arg(max_paths);
+
+-- name: ResetPageViews :exec
+DELETE FROM page_views;
@@ -0,0 +1,38 @@
+-- name: CreateRechirp :one
+INSERT INTO rechirps (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, chirp_id) DO NOTHING
+RETURNING *;
+
+-- name: GetRechirp :one
+SELECT *
+FROM rechirps
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: DeleteRechirp :execrows
+DELETE FROM rechirps
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetRechirps :many
+SELECT rechirps.user_id, rechirps.chirp_id, rechirps.created_at
+FROM rechirps
+JOIN chirps ON chirps.id = rechirps.chirp_id
+WHERE chirps.deleted_at IS NULL
+ORDER BY rechirps.created_at ASC;
+
+-- name: GetRechirpsByUserId :many
+SELECT rechirps.user_id, rechirps.chirp_id, rechirps.created_at
+FROM rechirps
+JOIN chirps ON chirps.id = rechirps.chirp_id
+WHERE rechirps.user_id = $1 AND chirps.deleted_at IS NULL
+ORDER BY rechirps.created_at ASC;
+
+-- name: CountRechirpsByChirpIds :many
+SELECT chirp_id, COUNT(*) AS rechirp_count
+FROM rechirps
+WHERE chirp_id = ANY($1::uuid[])
+GROUP BY chirp_id;
+
+-- name: GetRechirpsVersion :one
+SELECT COUNT(*) AS rechirp_count, COALESCE(MAX(created_at), 'epoch')::timestamp AS last_created
+FROM rechirps;
@@ -0,0 +1,61 @@
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM user_blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: GetBlockedUsers :many
+SELECT *
+FROM user_blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+
+-- name: IsUserBlocked :one
+SELECT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE blocker_id = $1 AND blocked_id = $2
+);
+
+-- name: MuteUser :exec
+INSERT INTO user_mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :exec
+DELETE FROM user_mutes
+WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: GetMutedUsers :many
+SELECT *
+FROM user_mutes
+WHERE muter_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetHiddenUserIds :many
+SELECT blocked_id FROM user_blocks WHERE blocker_id = $1
+UNION
+SELECT muted_id FROM user_mutes WHERE muter_id = $1;
+
+-- name: FollowUser :execrows
+INSERT INTO user_follows (follower_id, followed_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM user_follows
+WHERE follower_id = $1 AND followed_id = $2;
+
+-- name: GetFollowedUsers :many
+SELECT *
+FROM user_follows
+WHERE follower_id = $1
+ORDER BY created_at DESC;
+
+-- name: IsUserFollowed :one
+SELECT EXISTS (
+    SELECT 1 FROM user_follows
+    WHERE follower_id = $1 AND followed_id = $2
+);
@@ -0,0 +1,50 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, updated_at, body, user_id, publish_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: CountScheduledChirpsByUserId :one
+SELECT COUNT(*)
+FROM scheduled_chirps
+WHERE user_id = $1;
+
+-- name: GetScheduledChirpsByUserId :many
+SELECT *
+FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at ASC;
+
+-- name: GetScheduledChirp :one
+SELECT *
+FROM scheduled_chirps
+WHERE id = $1 AND user_id = $2;
+
+-- name: UpdateScheduledChirp :one
+UPDATE scheduled_chirps
+SET body = $1, publish_at = $2, updated_at = NOW()
+WHERE id = $3 AND user_id = $4
+RETURNING *;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = $1 AND user_id = $2;
+
+-- name: TakeDueScheduledChirps :many
+DELETE FROM scheduled_chirps
+WHERE id IN (
+    SELECT id
+    FROM scheduled_chirps
+    WHERE publish_at <= $1
+        AND user_id NOT IN (SELECT id FROM users WHERE disabled_at IS NOT NULL)
+    ORDER BY publish_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
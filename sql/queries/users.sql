@@ -13,10 +13,25 @@ RETURNING *;
 DELETE FROM users;
 
 -- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, disabled_at, username
 FROM users
 WHERE email = $1;
 
+-- name: GetUserById :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, disabled_at, username
+FROM users
+WHERE id = $1;
+
+-- name: GetUserByUsername :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, disabled_at, username
+FROM users
+WHERE username = $1;
+
+-- name: GetUserIdsByUsernames :many
+SELECT id
+FROM users
+WHERE username = ANY($1::text[]) AND disabled_at IS NULL;
+
 -- name: UpdateUser :one
 UPDATE users
 SET
@@ -31,4 +46,32 @@ UPDATE users
 SET
     is_chirpy_red = $2
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+SET
+    updated_at = NOW(),
+    role = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUsername :one
+UPDATE users
+SET
+    updated_at = NOW(),
+    username = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: CountUsersWithRole :one
+SELECT COUNT(*) FROM users
+WHERE role = $1;
+
+-- name: SetUserDisabled :one
+UPDATE users
+SET
+    updated_at = NOW(),
+    disabled_at = CASE WHEN sqlc.arg(disabled)::boolean THEN NOW() ELSE NULL END
+WHERE id = sqlc.arg(id)
+RETURNING *;
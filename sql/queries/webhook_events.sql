@@ -0,0 +1,17 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, created_at, provider, event, user_id, outcome)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetRecentWebhookEvents :many
+SELECT id, created_at, provider, event, user_id, outcome
+FROM webhook_events
+ORDER BY created_at DESC
+LIMIT $1;
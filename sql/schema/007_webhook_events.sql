@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    provider TEXT NOT NULL,
+    event TEXT NOT NULL,
+    user_id UUID NULL, -- not a foreign key so events for deleted users are kept
+    outcome TEXT NOT NULL
+);
+
+CREATE INDEX webhook_events_created_at_idx ON webhook_events (created_at DESC);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE page_views (
+    path TEXT NOT NULL,
+    day DATE NOT NULL,
+    hits BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (path, day)
+);
+
+CREATE INDEX page_views_day_idx ON page_views (day);
+
+-- +goose Down
+DROP TABLE page_views;
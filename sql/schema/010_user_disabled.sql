@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN disabled_at TIMESTAMP NULL; -- NULL if the account is active
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN disabled_at;
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN deleted_at TIMESTAMP NULL, -- NULL unless deleted, rows are purged later
+ADD COLUMN deleted_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+ADD COLUMN deletion_reason TEXT NULL,
+ADD COLUMN taken_down_at TIMESTAMP NULL, -- still listed, but with a placeholder body
+ADD COLUMN taken_down_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+ADD COLUMN takedown_reason TEXT NULL;
+
+CREATE INDEX chirps_deleted_at_idx ON chirps (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_deleted_at_idx;
+
+ALTER TABLE chirps
+DROP COLUMN takedown_reason,
+DROP COLUMN taken_down_by,
+DROP COLUMN taken_down_at,
+DROP COLUMN deletion_reason,
+DROP COLUMN deleted_by,
+DROP COLUMN deleted_at;
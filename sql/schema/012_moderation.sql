@@ -0,0 +1,50 @@
+-- +goose Up
+CREATE TABLE moderation_cases (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved')),
+    report_count INTEGER NOT NULL DEFAULT 0,
+    claimed_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    claimed_at TIMESTAMP NULL,
+    resolution TEXT NULL CHECK (resolution IN ('remove', 'warn', 'dismiss')),
+    resolved_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP NULL
+);
+
+-- at most one unresolved case per chirp; new reports join it
+CREATE UNIQUE INDEX moderation_cases_unresolved_chirp_idx ON moderation_cases (chirp_id) WHERE status <> 'resolved';
+CREATE INDEX moderation_cases_status_idx ON moderation_cases (status, created_at);
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    case_id UUID NOT NULL REFERENCES moderation_cases(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'misinformation', 'other')),
+    details TEXT NULL,
+    UNIQUE (chirp_id, reporter_id)
+);
+
+CREATE INDEX reports_case_id_idx ON reports (case_id);
+
+-- not foreign keys, so the log outlives purged chirps and deleted accounts
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    moderator_id UUID NULL, -- NULL for automatic actions
+    case_id UUID NULL,
+    chirp_id UUID NULL,
+    target_user_id UUID NULL,
+    action TEXT NOT NULL,
+    note TEXT NULL
+);
+
+CREATE INDEX moderation_actions_created_at_idx ON moderation_actions (created_at DESC);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+DROP TABLE moderation_cases;
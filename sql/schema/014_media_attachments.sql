@@ -0,0 +1,22 @@
+-- +goose Up
+-- uploads start unattached (chirp_id NULL) and are attached when the chirp
+-- is posted; unattached rows are cleaned up along with their files
+CREATE TABLE media_attachments (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NULL REFERENCES chirps(id) ON DELETE SET NULL,
+    position INTEGER NULL,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    storage_key TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    UNIQUE (chirp_id, position)
+);
+
+CREATE INDEX media_attachments_unattached_idx ON media_attachments (created_at) WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP TABLE media_attachments;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- one row per linked page, shared by every chirp linking to it. Pending rows
+-- are the fetch queue, so queued fetches survive a restart.
+CREATE TABLE link_previews (
+    url TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    image_url TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX link_previews_pending_idx ON link_previews (created_at) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE link_previews;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- private chirp bodies a user is still working on. updated_at doubles as the
+-- version clients send back, so edits from two devices can't silently clobber
+-- each other.
+CREATE TABLE drafts (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    body TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX drafts_user_id_idx ON drafts (user_id, updated_at);
+
+-- +goose Down
+DROP TABLE drafts;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- a quote is a chirp of its own that points at another. Purging the original
+-- leaves the quote standing with nothing to show.
+ALTER TABLE chirps
+ADD COLUMN quoted_chirp_id UUID NULL REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE INDEX chirps_quoted_chirp_id_idx ON chirps (quoted_chirp_id) WHERE quoted_chirp_id IS NOT NULL;
+
+-- a rechirp only records who reposted what and when
+CREATE TABLE rechirps (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX rechirps_chirp_id_idx ON rechirps (chirp_id);
+CREATE INDEX rechirps_created_at_idx ON rechirps (created_at);
+
+-- +goose Down
+DROP TABLE rechirps;
+
+DROP INDEX chirps_quoted_chirp_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN quoted_chirp_id;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- a worker claims a pending row by pushing next_attempt_at past the time its
+-- fetch can take, so instances never fetch the same page at once. A failed
+-- fetch pushes it further out, backing off between retries.
+ALTER TABLE link_previews
+ADD COLUMN next_attempt_at TIMESTAMP NOT NULL DEFAULT 'epoch';
+
+-- +goose Down
+ALTER TABLE link_previews
+DROP COLUMN next_attempt_at;
@@ -0,0 +1,57 @@
+-- +goose Up
+CREATE TABLE user_follows (
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followed_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (follower_id, followed_id),
+    CHECK (follower_id <> followed_id)
+);
+
+CREATE INDEX user_follows_followed_id_idx ON user_follows (followed_id);
+
+-- a follow is about a user, not a chirp: its event names the user followed
+-- and its notification has no chirp
+ALTER TABLE notification_events
+ALTER COLUMN chirp_id DROP NOT NULL,
+ADD COLUMN user_id UUID NULL REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE notifications
+ALTER COLUMN chirp_id DROP NOT NULL;
+
+ALTER TABLE notification_events
+DROP CONSTRAINT notification_events_type_check,
+ADD CONSTRAINT notification_events_type_check CHECK (type IN ('rechirp', 'quote', 'follow'));
+
+ALTER TABLE notifications
+DROP CONSTRAINT notifications_type_check,
+ADD CONSTRAINT notifications_type_check CHECK (type IN ('rechirp', 'quote', 'follow'));
+
+ALTER TABLE notification_preferences
+DROP CONSTRAINT notification_preferences_type_check,
+ADD CONSTRAINT notification_preferences_type_check CHECK (type IN ('rechirp', 'quote', 'follow'));
+
+-- +goose Down
+DELETE FROM notification_events WHERE type = 'follow';
+DELETE FROM notifications WHERE type = 'follow';
+DELETE FROM notification_preferences WHERE type = 'follow';
+
+ALTER TABLE notification_preferences
+DROP CONSTRAINT notification_preferences_type_check,
+ADD CONSTRAINT notification_preferences_type_check CHECK (type IN ('rechirp', 'quote'));
+
+ALTER TABLE notifications
+DROP CONSTRAINT notifications_type_check,
+ADD CONSTRAINT notifications_type_check CHECK (type IN ('rechirp', 'quote'));
+
+ALTER TABLE notification_events
+DROP CONSTRAINT notification_events_type_check,
+ADD CONSTRAINT notification_events_type_check CHECK (type IN ('rechirp', 'quote'));
+
+ALTER TABLE notifications
+ALTER COLUMN chirp_id SET NOT NULL;
+
+ALTER TABLE notification_events
+DROP COLUMN user_id,
+ALTER COLUMN chirp_id SET NOT NULL;
+
+DROP TABLE user_follows;
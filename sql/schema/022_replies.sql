@@ -0,0 +1,41 @@
+-- +goose Up
+-- a reply is a chirp that answers another. Purging the parent leaves the
+-- reply standing on its own.
+ALTER TABLE chirps
+ADD COLUMN reply_to_chirp_id UUID NULL REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE INDEX chirps_reply_to_chirp_id_idx ON chirps (reply_to_chirp_id) WHERE reply_to_chirp_id IS NOT NULL;
+
+ALTER TABLE notification_events
+DROP CONSTRAINT notification_events_type_check,
+ADD CONSTRAINT notification_events_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'follow'));
+
+ALTER TABLE notifications
+DROP CONSTRAINT notifications_type_check,
+ADD CONSTRAINT notifications_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'follow'));
+
+ALTER TABLE notification_preferences
+DROP CONSTRAINT notification_preferences_type_check,
+ADD CONSTRAINT notification_preferences_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'follow'));
+
+-- +goose Down
+DELETE FROM notification_events WHERE type = 'reply';
+DELETE FROM notifications WHERE type = 'reply';
+DELETE FROM notification_preferences WHERE type = 'reply';
+
+ALTER TABLE notification_preferences
+DROP CONSTRAINT notification_preferences_type_check,
+ADD CONSTRAINT notification_preferences_type_check CHECK (type IN ('rechirp', 'quote', 'follow'));
+
+ALTER TABLE notifications
+DROP CONSTRAINT notifications_type_check,
+ADD CONSTRAINT notifications_type_check CHECK (type IN ('rechirp', 'quote', 'follow'));
+
+ALTER TABLE notification_events
+DROP CONSTRAINT notification_events_type_check,
+ADD CONSTRAINT notification_events_type_check CHECK (type IN ('rechirp', 'quote', 'follow'));
+
+DROP INDEX chirps_reply_to_chirp_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN reply_to_chirp_id;
@@ -0,0 +1,41 @@
+-- +goose Up
+CREATE TABLE likes (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX likes_chirp_id_idx ON likes (chirp_id);
+CREATE INDEX likes_created_at_idx ON likes (created_at);
+
+ALTER TABLE notification_events
+DROP CONSTRAINT notification_events_type_check,
+ADD CONSTRAINT notification_events_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'like', 'follow'));
+
+ALTER TABLE notifications
+DROP CONSTRAINT notifications_type_check,
+ADD CONSTRAINT notifications_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'like', 'follow'));
+
+ALTER TABLE notification_preferences
+DROP CONSTRAINT notification_preferences_type_check,
+ADD CONSTRAINT notification_preferences_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'like', 'follow'));
+
+-- +goose Down
+DELETE FROM notification_events WHERE type = 'like';
+DELETE FROM notifications WHERE type = 'like';
+DELETE FROM notification_preferences WHERE type = 'like';
+
+ALTER TABLE notification_preferences
+DROP CONSTRAINT notification_preferences_type_check,
+ADD CONSTRAINT notification_preferences_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'follow'));
+
+ALTER TABLE notifications
+DROP CONSTRAINT notifications_type_check,
+ADD CONSTRAINT notifications_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'follow'));
+
+ALTER TABLE notification_events
+DROP CONSTRAINT notification_events_type_check,
+ADD CONSTRAINT notification_events_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'follow'));
+
+DROP TABLE likes;
@@ -0,0 +1,37 @@
+-- +goose Up
+-- the handle others @mention a user by. Stored lowercase, so uniqueness is
+-- case-insensitive.
+ALTER TABLE users
+ADD COLUMN username TEXT NULL UNIQUE CHECK (username ~ '^[a-z0-9_]{1,15}$');
+
+ALTER TABLE notification_events
+DROP CONSTRAINT notification_events_type_check,
+ADD CONSTRAINT notification_events_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'like', 'follow', 'mention'));
+
+ALTER TABLE notifications
+DROP CONSTRAINT notifications_type_check,
+ADD CONSTRAINT notifications_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'like', 'follow', 'mention'));
+
+ALTER TABLE notification_preferences
+DROP CONSTRAINT notification_preferences_type_check,
+ADD CONSTRAINT notification_preferences_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'like', 'follow', 'mention'));
+
+-- +goose Down
+DELETE FROM notification_events WHERE type = 'mention';
+DELETE FROM notifications WHERE type = 'mention';
+DELETE FROM notification_preferences WHERE type = 'mention';
+
+ALTER TABLE notification_preferences
+DROP CONSTRAINT notification_preferences_type_check,
+ADD CONSTRAINT notification_preferences_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'like', 'follow'));
+
+ALTER TABLE notifications
+DROP CONSTRAINT notifications_type_check,
+ADD CONSTRAINT notifications_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'like', 'follow'));
+
+ALTER TABLE notification_events
+DROP CONSTRAINT notification_events_type_check,
+ADD CONSTRAINT notification_events_type_check CHECK (type IN ('rechirp', 'quote', 'reply', 'like', 'follow'));
+
+ALTER TABLE users
+DROP COLUMN username;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- when a rechirp or like of the chirp was last added or undone. Undoing one
+-- removes its row, so this is the only trace left for Last-Modified to use.
+ALTER TABLE chirps
+ADD COLUMN counts_updated_at TIMESTAMP NULL;
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN counts_updated_at;
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+// handlerTakeDownChirp hides a chirp's body behind a placeholder without
+// deleting it, so the author can't restore it and the original stays on
+// record for review.
+func (a *apiConfig) handlerTakeDownChirp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		slog.InfoContext(r.Context(), "handlerTakeDownChirp: invalid ID parameter", "err", err)
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		slog.InfoContext(r.Context(), "handlerTakeDownChirp: failed to decode request body", "err", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	moderatorId, _ := a.optionalUserId(r) // already checked by middlewareRequirePermission
+	var chirp database.Chirp
+	err = a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		var err error
+		chirp, err = tx.TakeDownChirp(r.Context(), database.TakeDownChirpParams{
+			ID:             id,
+			TakenDownBy:    uuid.NullUUID{UUID: moderatorId, Valid: true},
+			TakedownReason: sql.NullString{String: payload.Reason, Valid: payload.Reason != ""},
+		})
+		if err != nil {
+			return err
+		}
+		return logModerationAction(r.Context(), tx, moderatorId, uuid.Nil, chirp, actionTakeDown, payload.Reason)
+	})
+	if err != nil {
+		a.chirpUpdateFailed(w, r, "handlerTakeDownChirp", id, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "handlerTakeDownChirp: chirp taken down", "chirp_id", id, "moderator_id", moderatorId, "author_id", chirp.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newChirpResponse(chirp))
+}
+
+func (a *apiConfig) handlerReinstateChirp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		slog.InfoContext(r.Context(), "handlerReinstateChirp: invalid ID parameter", "err", err)
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+		return
+	}
+
+	moderatorId, _ := a.optionalUserId(r) // already checked by middlewareRequirePermission
+	var chirp database.Chirp
+	err = a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		var err error
+		if chirp, err = tx.ReinstateChirp(r.Context(), id); err != nil {
+			return err
+		}
+		return logModerationAction(r.Context(), tx, moderatorId, uuid.Nil, chirp, actionReinstate, "")
+	})
+	if err != nil {
+		a.chirpUpdateFailed(w, r, "handlerReinstateChirp", id, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "handlerReinstateChirp: chirp reinstated", "chirp_id", id, "moderator_id", moderatorId)
+
+	res := []chirpResponse{newChirpResponse(chirp)}
+	if err := a.withAttachments(r.Context(), res); err != nil {
+		slog.ErrorContext(r.Context(), "handlerReinstateChirp: failed to get attachments", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res[0])
+}
+
+func (a *apiConfig) chirpUpdateFailed(w http.ResponseWriter, r *http.Request, handler string, id uuid.UUID, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.InfoContext(r.Context(), handler+": chirp not found", "chirp_id", id)
+		http.Error(w, fmt.Sprintf("Chirp with ID %s not found", id.String()), http.StatusNotFound)
+		return
+	}
+	slog.ErrorContext(r.Context(), handler+": failed to update chirp", "err", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
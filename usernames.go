@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/notify"
+	"github.com/jonvanw/chirpy/internal/store"
+)
+
+var errUsernameTaken = errors.New("username taken")
+
+// handleSetUsername sets the caller's handle, which others @mention them by.
+// Handles are matched case-insensitively, so they're stored lowercase. An
+// empty username clears it.
+func (a *apiConfig) handleSetUsername(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.requireUserId(w, r, "handleSetUsername")
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		slog.InfoContext(r.Context(), "handleSetUsername: failed to decode request body", "err", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	username := sql.NullString{String: strings.ToLower(payload.Username), Valid: payload.Username != ""}
+	if username.Valid && !notify.ValidUsername(username.String) {
+		http.Error(w, "Usernames are 1 to 15 letters, digits or underscores", http.StatusBadRequest)
+		return
+	}
+
+	var userRaw database.User
+	err := a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		if username.Valid {
+			owner, err := tx.GetUserByUsername(r.Context(), username)
+			if err == nil && owner.ID != userId {
+				return errUsernameTaken
+			}
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+		var err error
+		userRaw, err = tx.SetUsername(r.Context(), database.SetUsernameParams{ID: userId, Username: username})
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errUsernameTaken):
+			http.Error(w, "Username is taken", http.StatusConflict)
+		case errors.Is(err, sql.ErrNoRows):
+			slog.WarnContext(r.Context(), "handleSetUsername: unknown user", "user_id", userId)
+			http.Error(w, "Unauthorized: unknown user", http.StatusUnauthorized)
+		default:
+			slog.ErrorContext(r.Context(), "handleSetUsername: failed to set username", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	user := userInfoResponse{
+		ID:          userRaw.ID,
+		CreatedAt:   userRaw.CreatedAt,
+		UpdatedAt:   userRaw.UpdatedAt,
+		Email:       userRaw.Email,
+		Username:    userRaw.Username.String,
+		IsChirpyRed: userRaw.IsChirpyRed,
+		Role:        userRaw.Role,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
@@ -4,16 +4,17 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jonvanw/chirpy/internal/auth"
 	"github.com/jonvanw/chirpy/internal/database"
+	"github.com/jonvanw/chirpy/internal/store"
 )
 
-const jwtDuration = time.Hour
+var errAccountDisabled = errors.New("account disabled")
 
 type userInfoRequest struct {
 	Email    string `json:"email"`
@@ -25,9 +26,11 @@ type userInfoResponse struct {
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 	Email          string    `json:"email"`
+	Username       string    `json:"username,omitempty"`
 	Token          string    `json:"token,omitempty"`
 	RefreshToken   string    `json:"refresh_token,omitempty"`
 	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	Role           string    `json:"role"`
 }
 
 func (a *apiConfig) handleAddUser(w http.ResponseWriter, r *http.Request) {
@@ -39,20 +42,20 @@ func (a *apiConfig) handleAddUser(w http.ResponseWriter, r *http.Request) {
 	var payload userInfoRequest
 	err := json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
-		log.Printf("handleAddUser: failed to decode request body: %v", err)
+		slog.InfoContext(r.Context(), "handleAddUser: failed to decode request body", "err", err)
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
 	args, err := payload.ToInsertDbArgs()
 	if err != nil {
-		log.Printf("handleAddUser: failed to convert to db args: %v", err)
+		slog.ErrorContext(r.Context(), "handleAddUser: failed to convert to db args", "err", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 	userRaw, err := a.dbQueries.CreateUser(r.Context(), args)
 	if err != nil {
-		log.Printf("handleAddUser: failed to create user: %v", err)
+		slog.ErrorContext(r.Context(), "handleAddUser: failed to create user", "err", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -62,7 +65,9 @@ func (a *apiConfig) handleAddUser(w http.ResponseWriter, r *http.Request) {
 		CreatedAt: userRaw.CreatedAt,
 		UpdatedAt: userRaw.UpdatedAt,
 		Email:     userRaw.Email,
+		Username:  userRaw.Username.String,
 		IsChirpyRed: userRaw.IsChirpyRed,
+		Role: userRaw.Role,
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -79,17 +84,19 @@ func (a *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 	var payload userInfoRequest
 	err := json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
-		log.Printf("handleLogin: failed to decode request body: %v", err)
+		slog.InfoContext(r.Context(), "handleLogin: failed to decode request body", "err", err)
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
 	userRaw, err := a.dbQueries.GetUserByEmail(r.Context(), payload.Email)
 	if err != nil {
-		log.Printf("handleLogin: failed to get user by email: %v", err)
 		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "handleLogin: unknown user", "email", payload.Email)
+			a.metrics.LoginFailures.WithLabelValues("unknown_user").Inc()
 			http.Error(w, "User not found", http.StatusNotFound)
 		} else {
+			slog.ErrorContext(r.Context(), "handleLogin: failed to get user by email", "err", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 		return
@@ -97,40 +104,64 @@ func (a *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	authorized, err := auth.CheckPasswordHash(payload.Password, userRaw.HashedPassword)
 	if err != nil  {
-		log.Printf("handleLogin: failed to check password hash: %v", err)
+		slog.ErrorContext(r.Context(), "handleLogin: failed to check password hash", "err", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 	if !authorized {
-		log.Printf("handleLogin: unauthorized login attempt for email: %s", payload.Email)
+		slog.WarnContext(r.Context(), "handleLogin: unauthorized login attempt", "email", payload.Email)
+		a.metrics.LoginFailures.WithLabelValues("bad_password").Inc()
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	jwt, err := auth.MakeJWT(
-		userRaw.ID,
-		a.jwtAuthSecret,
-		jwtDuration,
-	)
+	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		log.Printf("handleLogin: failed to create JWT: %v", err)
+		slog.ErrorContext(r.Context(), "handleLogin: failed to create refresh token", "err", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	refreshToken, err := auth.MakeRefreshToken()
+	// re-read the user in the same transaction as the insert, so an account
+	// disabled while the password was being checked can't get a new session
+	err = a.dbQueries.InTx(r.Context(), func(tx store.Store) error {
+		current, err := tx.GetUserById(r.Context(), userRaw.ID)
+		if err != nil {
+			return err
+		}
+		if current.DisabledAt.Valid {
+			return errAccountDisabled
+		}
+		_, err = tx.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+			Token: refreshToken,
+			UserID: current.ID,
+			ExpiresAt: time.Now().Add(a.refreshTokenTTL),
+		})
+		if err != nil {
+			return err
+		}
+		userRaw = current
+		return nil
+	})
 	if err != nil {
-		log.Printf("handleLogin: failed to create refresh token: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		if errors.Is(err, errAccountDisabled) {
+			slog.WarnContext(r.Context(), "handleLogin: login attempt on disabled account", "user_id", userRaw.ID)
+			a.metrics.LoginFailures.WithLabelValues("disabled").Inc()
+			http.Error(w, "Forbidden, account disabled", http.StatusForbidden)
+		} else {
+			slog.ErrorContext(r.Context(), "handleLogin: failed to save refresh token", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	_,  err = a.dbQueries.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		Token: refreshToken,
-		UserID: userRaw.ID,
-		ExpiresAt: time.Now().Add(60 * 24 * time.Hour), // 60 days
-	})
+	jwt, err := auth.MakeJWT(
+		userRaw.ID,
+		userRaw.Role,
+		a.jwtAuthSecret,
+		a.jwtDuration,
+	)
 	if err != nil {
-		log.Printf("handleLogin: failed to save refresh token: %v", err)
+		slog.ErrorContext(r.Context(), "handleLogin: failed to create JWT", "err", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -140,9 +171,11 @@ func (a *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 		CreatedAt: userRaw.CreatedAt,
 		UpdatedAt: userRaw.UpdatedAt,
 		Email:     userRaw.Email,
+		Username:  userRaw.Username.String,
 		Token:     jwt,
 		RefreshToken: refreshToken,
 		IsChirpyRed: userRaw.IsChirpyRed,
+		Role: userRaw.Role,
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -158,38 +191,58 @@ func (a *apiConfig) handleRefreshAuthToken(w http.ResponseWriter, r *http.Reques
 
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		log.Printf("handleRefreshAuthToken: failed to get bearer token: %v", err)
+		slog.WarnContext(r.Context(), "handleRefreshAuthToken: failed to get bearer token", "err", err)
 		http.Error(w, "Unauthorized, no user token provided.", http.StatusUnauthorized)
 		return
 	}
 	refreshTokenRecord, err := a.dbQueries.GetRefreshToken(r.Context(), refreshToken)
 	if err != nil {
-		log.Printf("handleRefreshAuthToken: failed to get refresh token from db: %v", err)
 		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "handleRefreshAuthToken: unknown refresh token")
 			http.Error(w, "Unauthorized, invalid refresh token.", http.StatusUnauthorized)
 		} else {
+			slog.ErrorContext(r.Context(), "handleRefreshAuthToken: failed to get refresh token from db", "err", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 		return
 	}
 	if refreshTokenRecord.RevokedAt.Valid {
-		log.Printf("handleRefreshAuthToken: refresh token revoked for user: %s", refreshTokenRecord.UserID)
+		slog.WarnContext(r.Context(), "handleRefreshAuthToken: refresh token revoked", "user_id", refreshTokenRecord.UserID)
 		http.Error(w, "Unauthorized, refresh token revoked.", http.StatusUnauthorized)
 		return
 	}
 	if refreshTokenRecord.ExpiresAt.Before(time.Now()) {
-		log.Printf("handleRefreshAuthToken: refresh token expired for user: %s", refreshTokenRecord.UserID)
+		slog.InfoContext(r.Context(), "handleRefreshAuthToken: refresh token expired", "user_id", refreshTokenRecord.UserID)
 		http.Error(w, "Unauthorized, refresh token expired.", http.StatusUnauthorized)
 		return
 	}
 
+	// look the user up again so role changes apply from the next refresh
+	userRaw, err := a.dbQueries.GetUserById(r.Context(), refreshTokenRecord.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "handleRefreshAuthToken: unknown user", "user_id", refreshTokenRecord.UserID)
+			http.Error(w, "Unauthorized, unknown user.", http.StatusUnauthorized)
+		} else {
+			slog.ErrorContext(r.Context(), "handleRefreshAuthToken: failed to get user", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	if userRaw.DisabledAt.Valid {
+		slog.WarnContext(r.Context(), "handleRefreshAuthToken: account disabled", "user_id", userRaw.ID)
+		http.Error(w, "Unauthorized, account disabled.", http.StatusUnauthorized)
+		return
+	}
+
 	jwt, err := auth.MakeJWT(
-		refreshTokenRecord.UserID,
+		userRaw.ID,
+		userRaw.Role,
 		a.jwtAuthSecret,
-		jwtDuration,
+		a.jwtDuration,
 	)
 	if err != nil {
-		log.Printf("handleRefreshAuthToken: failed to create JWT: %v", err)
+		slog.ErrorContext(r.Context(), "handleRefreshAuthToken: failed to create JWT", "err", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -213,17 +266,18 @@ func (a *apiConfig) handleRevokeRefreshToken(w http.ResponseWriter, r *http.Requ
 
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		log.Printf("handleRevokeRefreshToken: failed to get bearer token: %v", err)
+		slog.WarnContext(r.Context(), "handleRevokeRefreshToken: failed to get bearer token", "err", err)
 		http.Error(w, "Unauthorized, no refresh token provided.", http.StatusUnauthorized)
 		return
 	}
 
 	refreshTokenRecord, err := a.dbQueries.GetRefreshToken(r.Context(), refreshToken)
 	if err != nil {
-		log.Printf("handleRevokeRefreshToken: failed to get refresh token from db: %v", err)
 		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "handleRevokeRefreshToken: unknown refresh token")
 			http.Error(w, "Unauthorized, invalid refresh token.", http.StatusUnauthorized)
 		} else {
+			slog.ErrorContext(r.Context(), "handleRevokeRefreshToken: failed to get refresh token from db", "err", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 		return
@@ -234,7 +288,7 @@ func (a *apiConfig) handleRevokeRefreshToken(w http.ResponseWriter, r *http.Requ
 	}
 
 	if err = a.dbQueries.RevokeRefreshToken(r.Context(), refreshToken); err != nil {
-		log.Printf("handleRevokeRefreshToken: failed to revoke refresh token: %v", err)
+		slog.ErrorContext(r.Context(), "handleRevokeRefreshToken: failed to revoke refresh token", "err", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -250,14 +304,14 @@ func (a *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		log.Printf("handleAddChirp: failed to get bearer token: %v", err)
+		slog.WarnContext(r.Context(), "handleUpdateUser: failed to get bearer token", "err", err)
 		http.Error(w, "Unauthorized, no user token provided.", http.StatusUnauthorized)
 		return
 	}
 
 	userId, err := auth.ValidateJWT(token, a.jwtAuthSecret)
 	if err != nil || userId == uuid.Nil {
-		log.Printf("handleAddChirp: failed to validate JWT: %v", err)
+		slog.WarnContext(r.Context(), "handleUpdateUser: failed to validate JWT", "err", err)
 		http.Error(w, "Unauthorized. Invalid user token.", http.StatusUnauthorized)
 		return
 	}
@@ -265,23 +319,24 @@ func (a *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	var payload userInfoRequest
 	err = json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
-		log.Printf("handleUpdateUser: failed to decode request body: %v", err)
+		slog.InfoContext(r.Context(), "handleUpdateUser: failed to decode request body", "err", err)
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
 	args, err := payload.ToUpdateDbArgs(userId)
 	if err != nil {
-		log.Printf("handleUpdateUser: failed to convert to db args: %v", err)
+		slog.ErrorContext(r.Context(), "handleUpdateUser: failed to convert to db args", "err", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 	userRaw, err := a.dbQueries.UpdateUser(r.Context(), args)
 	if err != nil {
-		log.Printf("handleUpdateUser: failed to update user: %v", err)
 		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "handleUpdateUser: unknown user", "user_id", userId)
 			http.Error(w, "Unauthorized: unknown user", http.StatusUnauthorized)
 		} else {
+			slog.ErrorContext(r.Context(), "handleUpdateUser: failed to update user", "err", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 		return
@@ -292,7 +347,9 @@ func (a *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		CreatedAt: userRaw.CreatedAt,
 		UpdatedAt: userRaw.UpdatedAt,
 		Email:     userRaw.Email,
+		Username:  userRaw.Username.String,
 		IsChirpyRed: userRaw.IsChirpyRed,
+		Role: userRaw.Role,
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -4,16 +4,19 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+
+	"github.com/jonvanw/chirpy/internal/linkpreview"
 )
 
 
-func ValidateChirp(body string) (string, error) {
+// ValidateChirp returns the body to store and the links found in it.
+func ValidateChirp(body string) (string, []string, error) {
 	if len(body) > 140  {
-		return "", fmt.Errorf("Chirp is too long")
+		return "", nil, fmt.Errorf("Chirp is too long")
 	}
 
 	cleanedBody := CleanChirp(body)
-	return cleanedBody, nil
+	return cleanedBody, linkpreview.FindURLs(cleanedBody), nil
 }
 
 func CleanChirp(text string) string {
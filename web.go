@@ -0,0 +1,30 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Only the public site is embedded; the rest of the repository (go.mod, .env,
+// sql/, sources) must never be reachable under /app/.
+//
+//go:embed index.html assets
+var embeddedWeb embed.FS
+
+// appFS returns the files to serve under /app/: dir on disk when set, so the
+// frontend can be redeployed without a rebuild, otherwise the embedded copy.
+func appFS(dir string) (fs.FS, error) {
+	if dir == "" {
+		return embeddedWeb, nil
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return os.DirFS(dir), nil
+}